@@ -0,0 +1,221 @@
+// Package backup saves the configuration of a Nessus server into an archive
+// and restores it onto another server, e.g. to rebuild a scanner after a
+// failure.
+//
+// An archive holds the folders, scanners, policies (as .nessus policy files),
+// scan definitions, users, groups, permissions and plugin rules of a server.
+// Scan results are not part of it. Restoring maps the IDs of the archive to
+// the ones of the target server.
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+// FormatVersion is the version of the archives written by this package.
+// Archives of a newer version are refused.
+const FormatVersion = 1
+
+// Manifest describes an archive.
+type Manifest struct {
+	Version       int       `json:"version"`
+	Created       time.Time `json:"created"`
+	ServerVersion string    `json:"server_version"`
+	ServerUUID    string    `json:"server_uuid"`
+	// Skipped lists the parts the server could not provide, e.g. groups on
+	// servers without group support.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Policy is a backed up policy.
+type Policy struct {
+	nessie.Policy
+	Permissions []nessie.Permission `json:"permissions"`
+	// File is the .nessus policy file, stored as policies/<id>.nessus.
+	File []byte `json:"-"`
+}
+
+// Scan is a backed up scan definition. Its settings hold the IDs of the
+// source server.
+type Scan struct {
+	ID int64 `json:"id"`
+	// Template is the UUID of the template of the scan, the one of its policy
+	// when known. Restore falls back to the basic network scan.
+	Template    string                     `json:"template,omitempty"`
+	Settings    nessie.ScanSettingsRequest `json:"settings"`
+	Permissions []nessie.Permission        `json:"permissions"`
+}
+
+// Archive is the configuration of a server.
+type Archive struct {
+	Manifest    Manifest
+	Folders     []nessie.Folder
+	Scanners    []nessie.Scanner
+	Policies    []Policy
+	Scans       []Scan
+	Users       []nessie.User
+	Groups      []nessie.Group
+	PluginRules []nessie.Rule
+}
+
+// Files of an archive.
+const (
+	manifestFile    = "manifest.json"
+	foldersFile     = "folders.json"
+	scannersFile    = "scanners.json"
+	policiesFile    = "policies.json"
+	policiesDir     = "policies"
+	scansFile       = "scans.json"
+	usersFile       = "users.json"
+	groupsFile      = "groups.json"
+	pluginRulesFile = "plugin_rules.json"
+)
+
+func policyFile(id int64) string {
+	return path.Join(policiesDir, fmt.Sprintf("%d.nessus", id))
+}
+
+// Write writes the archive as a zip file.
+func (a *Archive) Write(w io.Writer) error {
+	z := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{manifestFile, &a.Manifest},
+		{foldersFile, a.Folders},
+		{scannersFile, a.Scanners},
+		{policiesFile, a.Policies},
+		{scansFile, a.Scans},
+		{usersFile, a.Users},
+		{groupsFile, a.Groups},
+		{pluginRulesFile, a.PluginRules},
+	}
+	for _, f := range files {
+		j, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeZipFile(z, f.name, j); err != nil {
+			return err
+		}
+	}
+	for _, p := range a.Policies {
+		if err := writeZipFile(z, policyFile(p.ID), p.File); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func writeZipFile(z *zip.Writer, name string, data []byte) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Save writes the archive to a file.
+func (a *Archive) Save(filePath string) error {
+	var b bytes.Buffer
+	if err := a.Write(&b); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, b.Bytes(), 0600)
+}
+
+// Read reads an archive written by Write.
+func Read(r io.ReaderAt, size int64) (*Archive, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range z.File {
+		files[f.Name] = f
+	}
+	read := func(name string, v interface{}) error {
+		data, err := readZipFile(files, name)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("cannot decode %s: %v", name, err)
+		}
+		return nil
+	}
+
+	a := &Archive{}
+	if err := read(manifestFile, &a.Manifest); err != nil {
+		return nil, err
+	}
+	if a.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("archive version %d is newer than the supported version %d", a.Manifest.Version, FormatVersion)
+	}
+	for name, v := range map[string]interface{}{
+		foldersFile:     &a.Folders,
+		scannersFile:    &a.Scanners,
+		policiesFile:    &a.Policies,
+		scansFile:       &a.Scans,
+		usersFile:       &a.Users,
+		groupsFile:      &a.Groups,
+		pluginRulesFile: &a.PluginRules,
+	} {
+		if err := read(name, v); err != nil {
+			return nil, err
+		}
+	}
+	for i := range a.Policies {
+		data, err := readZipFile(files, policyFile(a.Policies[i].ID))
+		if err != nil {
+			return nil, err
+		}
+		a.Policies[i].File = data
+	}
+	return a, nil
+}
+
+func readZipFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// Open reads an archive file.
+func Open(filePath string) (*Archive, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(f, info.Size())
+}
+
+// sortByID sorts objects by ID so that archives of the same state are equal.
+func sortByID[T any](objects []T, id func(T) int64) {
+	sort.Slice(objects, func(i, j int) bool { return id(objects[i]) < id(objects[j]) })
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/internal/transfer"
+)
+
+// Nessus is the part of the client used to back up and restore a server.
+type Nessus interface {
+	nessie.AdminService
+	nessie.UserService
+	nessie.PluginRuleService
+	nessie.PolicyService
+	nessie.ScanService
+	nessie.FolderService
+}
+
+// Backup reads the configuration of the server of n, which must be logged in.
+// Scans in the trash are left out.
+func Backup(n Nessus) (*Archive, error) {
+	props, err := n.ServerProperties()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get server properties: %v", err)
+	}
+	a := &Archive{Manifest: Manifest{
+		Version:       FormatVersion,
+		Created:       time.Now().UTC().Truncate(time.Second),
+		ServerVersion: props.ServerVersion,
+		ServerUUID:    props.ServerUUID,
+	}}
+
+	if a.Folders, err = n.Folders(); err != nil {
+		return nil, fmt.Errorf("cannot list folders: %v", err)
+	}
+	sortByID(a.Folders, func(f nessie.Folder) int64 { return f.ID })
+	if a.Scanners, err = n.Scanners(); err != nil {
+		return nil, fmt.Errorf("cannot list scanners: %v", err)
+	}
+	sortByID(a.Scanners, func(s nessie.Scanner) int64 { return s.ID })
+	if a.Users, err = n.ListUsers(); err != nil {
+		return nil, fmt.Errorf("cannot list users: %v", err)
+	}
+	sortByID(a.Users, func(u nessie.User) int64 { return int64(u.ID) })
+	// Groups are not available on every edition of nessus.
+	if a.Groups, err = n.ListGroups(); err != nil {
+		a.Manifest.Skipped = append(a.Manifest.Skipped, fmt.Sprintf("groups: %v", err))
+	}
+	sortByID(a.Groups, func(g nessie.Group) int64 { return g.ID })
+	if a.PluginRules, err = n.PluginRules(); err != nil {
+		return nil, fmt.Errorf("cannot list plugin rules: %v", err)
+	}
+	sortByID(a.PluginRules, func(r nessie.Rule) int64 { return r.ID })
+
+	policies, err := n.Policies()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list policies: %v", err)
+	}
+	templates := map[int64]string{}
+	for _, p := range policies {
+		file, err := n.ExportPolicy(p.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export policy %q: %v", p.Name, err)
+		}
+		perms, err := n.Permissions("policy", p.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get permissions of policy %q: %v", p.Name, err)
+		}
+		a.Policies = append(a.Policies, Policy{Policy: p, Permissions: perms, File: file})
+		templates[p.ID] = p.TemplateUUID
+	}
+	sortByID(a.Policies, func(p Policy) int64 { return p.ID })
+
+	list, err := n.Scans()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list scans: %v", err)
+	}
+	trash := int64(-1)
+	for _, f := range a.Folders {
+		if f.Type == "trash" {
+			trash = f.ID
+		}
+	}
+	for _, sc := range list.Scans {
+		if sc.FolderID == trash {
+			continue
+		}
+		details, err := n.ScanDetails(sc.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get details of scan %q: %v", sc.Name, err)
+		}
+		perms, err := n.Permissions("scan", sc.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get permissions of scan %q: %v", sc.Name, err)
+		}
+		a.Scans = append(a.Scans, Scan{
+			ID:          sc.ID,
+			Template:    templates[int64(sc.PolicyID)],
+			Settings:    transfer.ScanSettings(&sc, details.Info.Targets),
+			Permissions: perms,
+		})
+	}
+	sortByID(a.Scans, func(s Scan) int64 { return s.ID })
+	return a, nil
+}
@@ -0,0 +1,215 @@
+package backup
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/nessietest"
+)
+
+// populate creates a folder, a user, a group, a policy, a scan shared with the
+// user and the group, and a plugin rule.
+func populate(t *testing.T, n nessie.Nessus) {
+	t.Helper()
+	if err := n.CreateFolder("prod"); err != nil {
+		t.Fatal(err)
+	}
+	folders, err := n.Folders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prod int64
+	for _, f := range folders {
+		if f.Name == "prod" {
+			prod = f.ID
+		}
+	}
+	alice, err := n.CreateUser("alice", "secret", nessie.UserTypeLocal, "32", "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := n.CreateGroup("ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := n.CreatePolicy(nessie.CreatePolicyRequest{
+		UUID:     nessietest.BasicTemplateUUID,
+		Settings: nessie.PolicySettings{Name: "fast", Description: "Fast discovery"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := n.CreateScan(nessie.NewScanRequest{
+		UUID: nessietest.BasicTemplateUUID,
+		Settings: nessie.ScanSettingsRequest{
+			Name:        "weekly",
+			FolderID:    prod,
+			PolicyID:    policy.PolicyID,
+			TextTargets: "10.0.0.1,10.0.0.2",
+			Enabled:     true,
+			Launch:      "WEEKLY",
+			RRules:      "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO",
+			StartTime:   "20210104T020000",
+			TimeZone:    "Europe/Paris",
+			Emails:      "sec@example.com",
+			FilterType:  nessie.FilterTypeOr,
+			Filters:     []nessie.NotificationFilter{nessie.SeverityFilter(nessie.NotificationOpEqual, 4)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acls := []nessie.Permission{
+		{Type: "user", ID: int64(alice.ID), Name: "alice", Permissions: 64},
+		{Type: "group", ID: ops.ID, Name: "ops", Permissions: 16},
+	}
+	if err := n.SetPermissions("scan", sc.ID, acls); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.CreatePluginRule(nessie.Rule{PluginID: 19506, Type: "recast_info", Host: "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	src := nessietest.NewServer()
+	defer src.Close()
+	n := src.Client(t)
+	populate(t, n)
+
+	a, err := Backup(n)
+	if err != nil {
+		t.Fatalf("cannot back up: %v", err)
+	}
+	if len(a.Policies) != 1 || len(a.Policies[0].File) == 0 || len(a.Scans) != 1 || len(a.Users) != 2 || len(a.Groups) != 1 || len(a.PluginRules) != 1 {
+		t.Fatalf("wrong archive %+v", a)
+	}
+	if sc := a.Scans[0]; sc.Template != nessietest.BasicTemplateUUID || sc.Settings.TextTargets != "10.0.0.1,10.0.0.2" || sc.Settings.Launch != "WEEKLY" {
+		t.Errorf("wrong backed up scan %+v", sc)
+	}
+
+	// The archive survives a round trip through its zip file.
+	var b bytes.Buffer
+	if err := a.Write(&b); err != nil {
+		t.Fatalf("cannot write archive: %v", err)
+	}
+	read, err := Read(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("cannot read archive: %v", err)
+	}
+	if read.Manifest.Version != FormatVersion || !bytes.Equal(read.Policies[0].File, a.Policies[0].File) || !reflect.DeepEqual(read.Scans, a.Scans) {
+		t.Errorf("wrong read archive %+v", read)
+	}
+
+	// The target has a folder of its own, so IDs differ from the source.
+	dst := nessietest.NewServer()
+	defer dst.Close()
+	target := dst.Client(t)
+	if err := target.CreateFolder("other"); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRestorer(target)
+	r.Passwords = map[string]string{"alice": "changeme"}
+	report, err := r.Restore(read)
+	if err != nil {
+		t.Fatalf("cannot restore: %v", err)
+	}
+	if len(report.Failures) != 0 {
+		t.Errorf("unexpected failures %v", report.Failures)
+	}
+	want := map[string]int{"folder": 1, "user": 1, "group": 1, "policy": 1, "scan": 1, "plugin rule": 1}
+	for kind, count := range want {
+		if report.Created[kind] != count {
+			t.Errorf("created %d %s, want %d", report.Created[kind], kind, count)
+		}
+	}
+
+	scans, err := target.Scans()
+	if err != nil || len(scans.Scans) != 1 {
+		t.Fatalf("wrong scans %+v, err=%v", scans, err)
+	}
+	weekly := scans.Scans[0]
+	srcScan := a.Scans[0]
+	if weekly.ID != report.Mapping.Scans[srcScan.ID] ||
+		weekly.FolderID != report.Mapping.Folders[srcScan.Settings.FolderID] ||
+		int64(weekly.PolicyID) != report.Mapping.Policies[srcScan.Settings.PolicyID] ||
+		weekly.RRules != srcScan.Settings.RRules || weekly.Enabled != 1 ||
+		weekly.FilterType != nessie.FilterTypeOr || len(weekly.NotificationFilters) != 1 {
+		t.Errorf("wrong restored scan %+v, mapping %+v", weekly, report.Mapping)
+	}
+	if weekly.FolderID == srcScan.Settings.FolderID {
+		t.Errorf("folder of the scan was not remapped")
+	}
+	acls, err := target.Permissions("scan", weekly.ID)
+	if err != nil || len(acls) != 2 {
+		t.Fatalf("wrong permissions %+v, err=%v", acls, err)
+	}
+	users, err := target.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if u.Username == "alice" && acls[0].ID != int64(u.ID) {
+			t.Errorf("permission of alice has ID %d, want %d", acls[0].ID, u.ID)
+		}
+	}
+	if policies, err := target.Policies(); err != nil || len(policies) != 1 || policies[0].Desc != "Fast discovery" {
+		t.Errorf("wrong policies %+v, err=%v", policies, err)
+	}
+
+	// Restoring again only maps the existing objects.
+	report, err = r.Restore(read)
+	if err != nil {
+		t.Fatalf("cannot restore again: %v", err)
+	}
+	if len(report.Created) != 0 || len(report.Failures) != 0 || report.Mapping.Scans[srcScan.ID] != weekly.ID {
+		t.Errorf("second restore should change nothing, got %+v", report)
+	}
+}
+
+func TestRestoreWithoutPassword(t *testing.T) {
+	src := nessietest.NewServer()
+	defer src.Close()
+	n := src.Client(t)
+	populate(t, n)
+	a, err := Backup(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := nessietest.NewServer()
+	defer dst.Close()
+	report, err := NewRestorer(dst.Client(t)).Restore(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// alice is not created, so the scan is restored without her permission.
+	var failures []string
+	for _, f := range report.Failures {
+		failures = append(failures, f.Error())
+	}
+	want := []string{`user "alice": no password`, `permissions "weekly": user "alice" is not on the target`}
+	if strings.Join(failures, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong failures %q, want %q", failures, want)
+	}
+	if report.Created["scan"] != 1 {
+		t.Errorf("scan should be restored, report %+v", report)
+	}
+}
+
+func TestReadNewerVersion(t *testing.T) {
+	a := &Archive{Manifest: Manifest{Version: FormatVersion + 1}}
+	var b bytes.Buffer
+	if err := a.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(bytes.NewReader(b.Bytes()), int64(b.Len())); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("newer archive should be refused, got %v", err)
+	}
+	if _, err := NewRestorer(nil).Restore(a); err == nil {
+		t.Error("restoring a newer archive should fail")
+	}
+}
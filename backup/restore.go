@@ -0,0 +1,371 @@
+package backup
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/internal/transfer"
+)
+
+// Mapping maps the IDs of the objects of an archive to the IDs of the same
+// objects on the target server.
+type Mapping struct {
+	Folders  map[int64]int64
+	Scanners map[int64]int64
+	Users    map[int64]int64
+	Groups   map[int64]int64
+	Policies map[int64]int64
+	Scans    map[int64]int64
+}
+
+// Failure is an object of the archive that could not be restored.
+type Failure struct {
+	// Kind is the kind of the object, e.g. "policy" or "user".
+	Kind string
+	Name string
+	Err  error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s %q: %v", f.Kind, f.Name, f.Err)
+}
+
+// Report is the outcome of a restore.
+type Report struct {
+	Mapping Mapping
+	// Created counts the objects created by kind. Objects already on the
+	// target, matched by name, are mapped but left as they are.
+	Created map[string]int
+	// Failures are the objects that could not be restored, the others are.
+	Failures []Failure
+}
+
+func (r *Report) fail(kind, name string, err error) {
+	r.Failures = append(r.Failures, Failure{Kind: kind, Name: name, Err: err})
+}
+
+// Restorer replays archives onto a server.
+type Restorer struct {
+	// Passwords are the passwords of the users to create, by username.
+	// Archives hold no passwords, so users missing from Passwords are not
+	// created and are reported as failures.
+	Passwords map[string]string
+	// ScannerIDs maps the scanners of the archive to scanners of the target.
+	// Other scanners are mapped by name, or to the default scanner.
+	ScannerIDs map[int64]int64
+
+	nessus Nessus
+}
+
+// NewRestorer returns a restorer onto the server of n, which must be logged in.
+func NewRestorer(n Nessus) *Restorer {
+	return &Restorer{nessus: n}
+}
+
+// restore is the state of a single restore.
+type restore struct {
+	*Restorer
+	archive *Archive
+	report  *Report
+	// users and groups are the IDs of the target by name, to remap
+	// permissions.
+	users  map[string]int64
+	groups map[string]int64
+}
+
+// Restore creates the objects of the archive missing from the target, in
+// dependency order: folders, users, groups, policies, scans and plugin rules.
+// An error is returned when the target cannot be read, failures of single
+// objects are in the report.
+func (r *Restorer) Restore(a *Archive) (*Report, error) {
+	if a.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("archive version %d is newer than the supported version %d", a.Manifest.Version, FormatVersion)
+	}
+	st := &restore{
+		Restorer: r,
+		archive:  a,
+		report: &Report{
+			Mapping: Mapping{
+				Folders:  map[int64]int64{},
+				Scanners: map[int64]int64{},
+				Users:    map[int64]int64{},
+				Groups:   map[int64]int64{},
+				Policies: map[int64]int64{},
+				Scans:    map[int64]int64{},
+			},
+			Created: map[string]int{},
+		},
+		users:  map[string]int64{},
+		groups: map[string]int64{},
+	}
+	for _, step := range []func() error{
+		st.scanners, st.folders, st.usersStep, st.groupsStep, st.policies, st.scans, st.pluginRules,
+	} {
+		if err := step(); err != nil {
+			return st.report, err
+		}
+	}
+	return st.report, nil
+}
+
+func (st *restore) scanners() error {
+	target, err := st.nessus.Scanners()
+	if err != nil {
+		return fmt.Errorf("cannot list scanners: %v", err)
+	}
+	byName := map[string]int64{}
+	var local int64
+	for _, s := range target {
+		byName[s.Name] = s.ID
+		if s.Type == "local" && local == 0 {
+			local = s.ID
+		}
+	}
+	for _, s := range st.archive.Scanners {
+		switch id, ok := st.ScannerIDs[s.ID]; {
+		case ok:
+			st.report.Mapping.Scanners[s.ID] = id
+		case byName[s.Name] != 0:
+			st.report.Mapping.Scanners[s.ID] = byName[s.Name]
+		case local != 0:
+			st.report.Mapping.Scanners[s.ID] = local
+		}
+	}
+	return nil
+}
+
+func (st *restore) folders() error {
+	target, err := st.nessus.Folders()
+	if err != nil {
+		return fmt.Errorf("cannot list folders: %v", err)
+	}
+	for _, f := range st.archive.Folders {
+		if id, ok := transfer.MatchFolder(target, f); ok {
+			st.report.Mapping.Folders[f.ID] = id
+			continue
+		}
+		id, err := nessie.CreateFolderID(st.nessus, f.Name)
+		if err != nil {
+			st.report.fail("folder", f.Name, err)
+			continue
+		}
+		st.report.Created["folder"]++
+		st.report.Mapping.Folders[f.ID] = id
+	}
+	return nil
+}
+
+func (st *restore) usersStep() error {
+	target, err := st.nessus.ListUsers()
+	if err != nil {
+		return fmt.Errorf("cannot list users: %v", err)
+	}
+	for _, u := range target {
+		st.users[u.Username] = int64(u.ID)
+	}
+	for _, u := range st.archive.Users {
+		if id, ok := st.users[u.Username]; ok {
+			st.report.Mapping.Users[int64(u.ID)] = id
+			continue
+		}
+		password, ok := st.Passwords[u.Username]
+		if !ok {
+			st.report.fail("user", u.Username, fmt.Errorf("no password"))
+			continue
+		}
+		created, err := st.nessus.CreateUser(u.Username, password, u.Type, strconv.Itoa(u.Permissions), u.Name, u.Email)
+		if err != nil {
+			st.report.fail("user", u.Username, err)
+			continue
+		}
+		st.report.Created["user"]++
+		st.users[u.Username] = int64(created.ID)
+		st.report.Mapping.Users[int64(u.ID)] = int64(created.ID)
+	}
+	return nil
+}
+
+func (st *restore) groupsStep() error {
+	if len(st.archive.Groups) == 0 {
+		return nil
+	}
+	target, err := st.nessus.ListGroups()
+	if err != nil {
+		// Groups are not available on every edition of nessus.
+		for _, g := range st.archive.Groups {
+			st.report.fail("group", g.Name, err)
+		}
+		return nil
+	}
+	for _, g := range target {
+		st.groups[g.Name] = g.ID
+	}
+	for _, g := range st.archive.Groups {
+		if id, ok := st.groups[g.Name]; ok {
+			st.report.Mapping.Groups[g.ID] = id
+			continue
+		}
+		created, err := st.nessus.CreateGroup(g.Name)
+		if err != nil {
+			st.report.fail("group", g.Name, err)
+			continue
+		}
+		st.report.Created["group"]++
+		st.groups[g.Name] = created.ID
+		st.report.Mapping.Groups[g.ID] = created.ID
+	}
+	return nil
+}
+
+func (st *restore) policies() error {
+	target, err := st.nessus.Policies()
+	if err != nil {
+		return fmt.Errorf("cannot list policies: %v", err)
+	}
+	byName := map[string]int64{}
+	for _, p := range target {
+		byName[p.Name] = p.ID
+	}
+	for _, p := range st.archive.Policies {
+		if id, ok := byName[p.Name]; ok {
+			st.report.Mapping.Policies[p.ID] = id
+			continue
+		}
+		created, err := st.nessus.ImportPolicy(fmt.Sprintf("policy-%d.nessus", p.ID), p.File)
+		if err != nil {
+			st.report.fail("policy", p.Name, err)
+			continue
+		}
+		st.report.Created["policy"]++
+		st.report.Mapping.Policies[p.ID] = created.ID
+		st.setPermissions("policy", created.ID, p.Name, p.Permissions)
+	}
+	return nil
+}
+
+func (st *restore) scans() error {
+	target, err := st.nessus.Scans()
+	if err != nil {
+		return fmt.Errorf("cannot list scans: %v", err)
+	}
+	trash, main := int64(-1), int64(0)
+	for _, f := range target.Folders {
+		switch f.Type {
+		case "trash":
+			trash = f.ID
+		case "main":
+			main = f.ID
+		}
+	}
+	byName := map[string]int64{}
+	for _, sc := range target.Scans {
+		if sc.FolderID != trash {
+			byName[sc.Name] = sc.ID
+		}
+	}
+	var basic string
+	m := &st.report.Mapping
+	for _, sc := range st.archive.Scans {
+		name := sc.Settings.Name
+		if id, ok := byName[name]; ok {
+			m.Scans[sc.ID] = id
+			continue
+		}
+		settings := sc.Settings
+		if id, ok := m.Folders[settings.FolderID]; ok {
+			settings.FolderID = id
+		} else {
+			settings.FolderID = main
+		}
+		if settings.PolicyID != 0 {
+			id, ok := m.Policies[settings.PolicyID]
+			if !ok {
+				st.report.fail("scan", name, fmt.Errorf("policy %d was not restored", settings.PolicyID))
+				continue
+			}
+			settings.PolicyID = id
+		}
+		// Unknown scanners fall back to the default one.
+		settings.ScannerID = m.Scanners[settings.ScannerID]
+
+		template := sc.Template
+		if template == "" {
+			if basic == "" {
+				if basic, err = transfer.BasicTemplate(st.nessus); err != nil {
+					st.report.fail("scan", name, err)
+					continue
+				}
+			}
+			template = basic
+		}
+		created, err := st.nessus.CreateScan(nessie.NewScanRequest{UUID: template, Settings: settings})
+		if err != nil {
+			st.report.fail("scan", name, err)
+			continue
+		}
+		st.report.Created["scan"]++
+		m.Scans[sc.ID] = created.ID
+		st.setPermissions("scan", created.ID, name, sc.Permissions)
+	}
+	return nil
+}
+
+func (st *restore) pluginRules() error {
+	if len(st.archive.PluginRules) == 0 {
+		return nil
+	}
+	target, err := st.nessus.PluginRules()
+	if err != nil {
+		return fmt.Errorf("cannot list plugin rules: %v", err)
+	}
+	key := func(r nessie.Rule) string {
+		return fmt.Sprintf("%d/%s/%s/%s", r.PluginID, r.Type, r.Host, r.Date)
+	}
+	existing := map[string]bool{}
+	for _, r := range target {
+		existing[key(r)] = true
+	}
+	for _, r := range st.archive.PluginRules {
+		if existing[key(r)] {
+			continue
+		}
+		if err := st.nessus.CreatePluginRule(r); err != nil {
+			st.report.fail("plugin rule", key(r), err)
+			continue
+		}
+		st.report.Created["plugin rule"]++
+	}
+	return nil
+}
+
+// setPermissions restores the permissions of a created object, with the IDs
+// of the users and groups of the target. The owner keeps its permissions.
+func (st *restore) setPermissions(objectType string, id int64, name string, perms []nessie.Permission) {
+	var acls []nessie.Permission
+	for _, p := range perms {
+		if p.Owner == 1 {
+			continue
+		}
+		switch p.Type {
+		case "user", "group":
+			ids := st.users
+			if p.Type == "group" {
+				ids = st.groups
+			}
+			targetID, ok := ids[p.Name]
+			if !ok {
+				st.report.fail("permissions", name, fmt.Errorf("%s %q is not on the target", p.Type, p.Name))
+				continue
+			}
+			p.ID = targetID
+		}
+		acls = append(acls, p)
+	}
+	if len(acls) == 0 {
+		return
+	}
+	if err := st.nessus.SetPermissions(objectType, id, acls); err != nil {
+		st.report.fail("permissions", name, err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+// collector periodically collects metrics from nessus and serves the last collection.
+type collector struct {
+	nessus nessie.Nessus
+	// login is called to open a new session when a collection fails, it may be nil.
+	login func() error
+	// maxScans is the maximum number of scans with their own scan label,
+	// the most recently modified scans are kept.
+	maxScans int
+
+	mu      sync.Mutex
+	metrics []byte
+	// collections counts the collections by outcome.
+	collections map[bool]int
+}
+
+func newCollector(n nessie.Nessus, login func() error, maxScans int) *collector {
+	return &collector{nessus: n, login: login, maxScans: maxScans, collections: map[bool]int{}}
+}
+
+// run collects the metrics every interval until stop is closed.
+func (c *collector) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.update()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update collects the metrics and keeps them for the next scrapes.
+func (c *collector) update() {
+	start := time.Now()
+	s := newMetricSet()
+	err := c.collect(s)
+	if err != nil && c.login != nil {
+		log.Println("collection failed, logging in again:", err)
+		if err = c.login(); err == nil {
+			s = newMetricSet()
+			err = c.collect(s)
+		}
+	}
+	up := 1.0
+	if err != nil {
+		log.Println("collection failed:", err)
+		up = 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collections[err == nil]++
+	s.gauge("nessus_up", "Whether the last collection from nessus succeeded.", up)
+	s.gauge("nessus_collect_duration_seconds", "Duration of the last collection.", time.Since(start).Seconds())
+	s.gauge("nessus_last_collect_timestamp_seconds", "When the last collection ended.", float64(time.Now().Unix()))
+	s.counter("nessus_collections_total", "Collections from nessus by outcome.", float64(c.collections[true]), "result", "success")
+	s.counter("nessus_collections_total", "Collections from nessus by outcome.", float64(c.collections[false]), "result", "failure")
+	var buf bytes.Buffer
+	s.WriteTo(&buf)
+	c.metrics = buf.Bytes()
+}
+
+func (c *collector) collect(s *metricSet) error {
+	status, err := c.nessus.ServerStatus()
+	if err != nil {
+		return err
+	}
+	ready := 0.0
+	if status.Status == nessie.ServerStatusReady {
+		ready = 1
+	}
+	s.gauge("nessus_server_ready", "Whether nessus is ready to scan.", ready)
+	s.gauge("nessus_server_status", "Current status of nessus.", 1, "status", status.Status)
+	s.gauge("nessus_server_progress", "Progress of the current server operation (e.g. plugin loading).", float64(status.Progress))
+	if ready == 0 {
+		// Nothing else answers while nessus is loading.
+		return nil
+	}
+
+	props, err := c.nessus.ServerProperties()
+	if err != nil {
+		return err
+	}
+	s.gauge("nessus_server_info", "Version information of nessus.", 1,
+		"server_version", props.ServerVersion, "ui_version", props.NessusUIVersion, "type", props.NessusType, "feed", props.Feed)
+	s.gauge("nessus_license_expiration_timestamp_seconds", "When the nessus license expires.", float64(props.Expiration.Epoch()))
+	s.gauge("nessus_plugin_set_info", "Loaded plugin set.", 1, "plugin_set", props.LoadedPluginSet)
+	if published, err := props.PluginSetTime(); err == nil {
+		s.gauge("nessus_plugin_set_timestamp_seconds", "When the loaded plugin set was published.", float64(published.Unix()))
+	}
+
+	scanners, err := c.nessus.Scanners()
+	if err != nil {
+		return err
+	}
+	for _, scanner := range scanners {
+		s.gauge("nessus_scanner_status", "Status of the scanners linked to nessus.", 1, "scanner", scanner.Name, "status", scanner.Status)
+		s.gauge("nessus_scanner_scans", "Number of scans running on the scanner.", float64(scanner.ScanCount), "scanner", scanner.Name)
+	}
+
+	scans, err := c.nessus.Scans()
+	if err != nil {
+		return err
+	}
+	byStatus := map[string]int{}
+	for _, scan := range scans.Scans {
+		byStatus[strings.ToLower(scan.Status)]++
+	}
+	var statuses []string
+	for status := range byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		s.gauge("nessus_scans", "Number of scans by status.", float64(byStatus[status]), "status", status)
+	}
+	c.collectScans(s, scans.Scans)
+	return nil
+}
+
+// collectScans adds the severity totals of the most recently modified scans.
+// Scans whose details cannot be fetched are skipped and counted.
+func (c *collector) collectScans(s *metricSet, scans []nessie.Scan) {
+	scans = append([]nessie.Scan(nil), scans...)
+	sort.Slice(scans, func(i, j int) bool { return scans[i].LastModificationDate.After(scans[j].LastModificationDate.Time) })
+	var dropped int
+	if len(scans) > c.maxScans {
+		dropped = len(scans) - c.maxScans
+		scans = scans[:c.maxScans]
+	}
+	// Always set so that the series does not disappear when nothing is dropped.
+	s.gauge("nessus_scans_dropped", "Scans without severity metrics because of the label cardinality limit.", float64(dropped))
+	var errors int
+	for _, scan := range scans {
+		details, err := c.nessus.ScanDetails(scan.ID)
+		if err != nil {
+			log.Printf("cannot get details of scan %q (%d): %v", scan.Name, scan.ID, err)
+			errors++
+			continue
+		}
+		// Scan names are not unique, the ID tells the series apart.
+		id := strconv.FormatInt(scan.ID, 10)
+		counts := details.SeverityCounts()
+		for _, sev := range nessie.Severities() {
+			s.gauge("nessus_scan_vulnerabilities", "Findings of the last run of the scan by severity.", float64(counts.Get(sev)), "scan", scan.Name, "scan_id", id, "severity", sev.String())
+		}
+		s.gauge("nessus_scan_hosts", "Hosts of the last run of the scan.", float64(len(details.Hosts)), "scan", scan.Name, "scan_id", id)
+		s.gauge("nessus_scan_last_modification_timestamp_seconds", "When the scan was last modified.", float64(scan.LastModificationDate.Epoch()), "scan", scan.Name, "scan_id", id)
+	}
+	s.gauge("nessus_scan_errors", "Scans whose details could not be fetched during the last collection.", float64(errors))
+}
+
+// ServeHTTP serves the metrics of the last collection.
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	metrics := c.metrics
+	c.mu.Unlock()
+	if metrics == nil {
+		http.Error(w, "no collection yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(metrics)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+)
+
+func fakeNessus(t *testing.T, status string) *httptest.Server {
+	responses := map[string]interface{}{
+		"/server/status":     &nessie.ServerStatus{Status: status},
+		"/server/properties": &nessie.ServerProperties{ServerVersion: "8.13.1", LoadedPluginSet: "202101021504", Expiration: nessie.UnixTimestamp(1700000000)},
+		"/scanners":          map[string]interface{}{"scanners": []nessie.Scanner{{Name: "Local Scanner", Status: "on", ScanCount: 1}}},
+		"/scans": &nessie.ListScansResponse{Scans: []nessie.Scan{
+			{ID: 1, Name: "weekly", Status: "completed", LastModificationDate: nessie.UnixTimestamp(300)},
+			{ID: 2, Name: "daily \"dmz\"", Status: "running", LastModificationDate: nessie.UnixTimestamp(200)},
+			{ID: 3, Name: "old", Status: "completed", LastModificationDate: nessie.UnixTimestamp(100)},
+			{ID: 4, Name: "weekly", Status: "completed", LastModificationDate: nessie.UnixTimestamp(250)},
+			// The details of broken cannot be fetched.
+			{ID: 5, Name: "broken", Status: "completed", LastModificationDate: nessie.UnixTimestamp(150)},
+		}},
+		"/scans/1": &nessie.ScanDetailsResp{Hosts: []nessie.Host{{Critical: 1, High: 2, Info: 10}, {High: 1}}},
+		"/scans/2": &nessie.ScanDetailsResp{Hosts: []nessie.Host{{Medium: 3}}},
+		"/scans/4": &nessie.ScanDetailsResp{Hosts: []nessie.Host{{Low: 2}}},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/server/status" && status != nessie.ServerStatusReady {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Errorf("cannot serialize response: %v", err)
+		}
+	}))
+}
+
+func scrape(t *testing.T, c *collector) string {
+	server := httptest.NewServer(c)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("cannot scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("cannot read metrics: %v", err)
+	}
+	return string(body)
+}
+
+func TestCollector(t *testing.T) {
+	server := fakeNessus(t, nessie.ServerStatusReady)
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	c := newCollector(n, nil, 4)
+	c.update()
+	metrics := scrape(t, c)
+
+	for _, want := range []string{
+		"# TYPE nessus_up gauge\nnessus_up 1\n",
+		"nessus_server_ready 1\n",
+		`nessus_server_info{server_version="8.13.1",ui_version="",type="",feed=""} 1`,
+		"nessus_license_expiration_timestamp_seconds 1.7e+09\n",
+		"nessus_plugin_set_timestamp_seconds 1.60959984e+09\n",
+		`nessus_scanner_status{scanner="Local Scanner",status="on"} 1`,
+		`nessus_scans{status="completed"} 4`,
+		`nessus_scans{status="running"} 1`,
+		`nessus_scan_vulnerabilities{scan="weekly",scan_id="1",severity="critical"} 1`,
+		`nessus_scan_vulnerabilities{scan="weekly",scan_id="1",severity="high"} 3`,
+		`nessus_scan_vulnerabilities{scan="weekly",scan_id="4",severity="low"} 2`,
+		`nessus_scan_vulnerabilities{scan="daily \"dmz\"",scan_id="2",severity="medium"} 3`,
+		"nessus_scans_dropped 1\n",
+		"nessus_scan_errors 1\n",
+		"nessus_up 1\n",
+		`nessus_collections_total{result="success"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("missing %q in metrics:\n%s", want, metrics)
+		}
+	}
+	// The least recently modified scan is dropped by the cardinality limit.
+	if strings.Contains(metrics, `scan="old"`) {
+		t.Errorf("scan over the limit should not be exported:\n%s", metrics)
+	}
+
+	c = newCollector(n, nil, 10)
+	c.update()
+	if metrics := scrape(t, c); !strings.Contains(metrics, "nessus_scans_dropped 0\n") {
+		t.Errorf("dropped scans should be exported when none are dropped:\n%s", metrics)
+	}
+}
+
+func TestCollectorLoading(t *testing.T) {
+	server := fakeNessus(t, nessie.ServerStatusLoading)
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	c := newCollector(n, nil, 10)
+	resp := httptest.NewRecorder()
+	c.ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("metrics should not be served before the first collection, got=%d", resp.Code)
+	}
+	c.update()
+	metrics := scrape(t, c)
+	if !strings.Contains(metrics, "nessus_up 1\n") || !strings.Contains(metrics, "nessus_server_ready 0\n") ||
+		!strings.Contains(metrics, `nessus_server_status{status="loading"} 1`) || strings.Contains(metrics, "nessus_scans") {
+		t.Errorf("wrong metrics while loading:\n%s", metrics)
+	}
+
+	server.Close()
+	var logins int
+	c.login = func() error { logins++; return nil }
+	c.update()
+	metrics = scrape(t, c)
+	if !strings.Contains(metrics, "nessus_up 0\n") || !strings.Contains(metrics, `nessus_collections_total{result="failure"} 1`) || logins != 1 {
+		t.Errorf("wrong metrics after a failure (logins=%d):\n%s", logins, metrics)
+	}
+}
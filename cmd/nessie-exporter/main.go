@@ -0,0 +1,99 @@
+// Package main implements a Prometheus exporter for the health and vulnerability counts of a Nessus server.
+//
+// Metrics are collected every -interval in the background and served on /metrics,
+// scrapes never hit nessus directly.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+var apiURL, username, password, accessKey, secretKey, fingerprints, listenAddr string
+var interval time.Duration
+var maxScans int
+
+func init() {
+	flag.StringVar(&apiURL, "api_url", "", "")
+	flag.StringVar(&username, "username", "", "Username to login with, in production read that from a file, do not set from the command line or it will end up in your history.")
+	flag.StringVar(&password, "password", "", "Password that matches the provided username, in production read that from a file, do not set from the command line or it will end up in your history.")
+	flag.StringVar(&accessKey, "access_key", "", "API access key, replaces username and password.")
+	flag.StringVar(&secretKey, "secret_key", "", "API secret key matching the access key.")
+	flag.StringVar(&fingerprints, "fingerprints", "", "Comma-separated list of SPKI Fingerprints for the Nessus server using SHA-256 encoded in base64.")
+	flag.StringVar(&listenAddr, "listen", ":9651", "Address to serve the metrics on.")
+	flag.DurationVar(&interval, "interval", time.Minute, "Interval between two collections from nessus.")
+	flag.IntVar(&maxScans, "max_scans", 50, "Maximum number of scans with per-scan metrics, the most recently modified scans are kept.")
+}
+
+// checkFlags rejects the flag values the collector cannot run with.
+func checkFlags() error {
+	if maxScans < 0 {
+		return fmt.Errorf("-max_scans must not be negative, got %d", maxScans)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("-interval must be positive, got %v", interval)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := checkFlags(); err != nil {
+		log.Fatal(err)
+	}
+	var err error
+	var nessus nessie.Nessus
+	switch {
+	case accessKey != "":
+		nessus, err = nessie.NewInsecureNessusWithAPICredentials(apiURL, accessKey, secretKey)
+	case len(fingerprints) > 0:
+		nessus, err = nessie.NewFingerprintedNessus(apiURL, strings.Split(fingerprints, ","))
+	default:
+		nessus, err = nessie.NewInsecureNessus(apiURL)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var login func() error
+	if accessKey == "" {
+		login = func() error { return nessus.Login(username, password) }
+		if err := login(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	c := newCollector(nessus, login, maxScans)
+	stop := make(chan struct{})
+	go c.run(interval, stop)
+
+	// The session is closed when the exporter is stopped.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	http.Handle("/metrics", c)
+	server := &http.Server{Addr: listenAddr}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	log.Println("Serving metrics on", listenAddr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	close(stop)
+	if accessKey == "" {
+		if err := nessus.Logout(); err != nil {
+			log.Println("cannot logout:", err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckFlags(t *testing.T) {
+	defer func(m int, i time.Duration) { maxScans, interval = m, i }(maxScans, interval)
+	var tests = []struct {
+		maxScans  int
+		interval  time.Duration
+		wantError bool
+	}{
+		{50, time.Minute, false},
+		{0, time.Minute, false},
+		{-1, time.Minute, true},
+		{50, 0, true},
+	}
+	for _, tt := range tests {
+		maxScans, interval = tt.maxScans, tt.interval
+		err := checkFlags()
+		if tt.wantError && err == nil {
+			t.Errorf("got no error, expected one (%+v)", tt)
+		}
+		if !tt.wantError && err != nil {
+			t.Errorf("got error checking flags: %v (%+v)", err, tt)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metricSet is a set of metrics rendered in the Prometheus text exposition format.
+type metricSet struct {
+	metrics []*metric
+	byName  map[string]*metric
+}
+
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{byName: map[string]*metric{}}
+}
+
+// gauge adds a gauge sample, labels are given as name/value pairs.
+func (s *metricSet) gauge(name, help string, value float64, labels ...string) {
+	s.add(name, help, "gauge", value, labels)
+}
+
+// counter adds a counter sample, labels are given as name/value pairs.
+func (s *metricSet) counter(name, help string, value float64, labels ...string) {
+	s.add(name, help, "counter", value, labels)
+}
+
+func (s *metricSet) add(name, help, typ string, value float64, labels []string) {
+	m, ok := s.byName[name]
+	if !ok {
+		m = &metric{name: name, help: help, typ: typ}
+		s.byName[name] = m
+		s.metrics = append(s.metrics, m)
+	}
+	m.samples = append(m.samples, sample{labels: formatLabels(labels), value: value})
+}
+
+// WriteTo writes the metrics sorted by name, in the Prometheus text format.
+func (s *metricSet) WriteTo(w io.Writer) (int64, error) {
+	metrics := append([]*metric(nil), s.metrics...)
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", m.name, m.typ)
+		for _, smp := range m.samples {
+			fmt.Fprintf(&b, "%s%s %s\n", m.name, smp.labels, strconv.FormatFloat(smp.value, 'g', -1, 64))
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabel(labels[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/JerusJ/nessie/backup"
+)
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	var srv server
+	srv.flags(fs, "")
+	out := fs.String("out", "", "Archive file to write.")
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("missing -out")
+	}
+	nessus, logout, err := srv.connect()
+	if err != nil {
+		return err
+	}
+	defer logout()
+
+	a, err := backup.Backup(nessus)
+	if err != nil {
+		return err
+	}
+	if err := a.Save(*out); err != nil {
+		return err
+	}
+	for _, s := range a.Manifest.Skipped {
+		fmt.Fprintf(os.Stderr, "Skipped %s\n", s)
+	}
+	fmt.Fprintf(os.Stdout, "Backed up %d folders, %d policies, %d scans, %d users, %d groups and %d plugin rules to %s.\n",
+		len(a.Folders), len(a.Policies), len(a.Scans), len(a.Users), len(a.Groups), len(a.PluginRules), *out)
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	var srv server
+	srv.flags(fs, "")
+	in := fs.String("in", "", "Archive file to restore.")
+	passwords := fs.String("passwords", "", "JSON file of the passwords of the users to create, by username. Users without password are not created.")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("missing -in")
+	}
+	a, err := backup.Open(*in)
+	if err != nil {
+		return err
+	}
+	nessus, logout, err := srv.connect()
+	if err != nil {
+		return err
+	}
+	defer logout()
+
+	r := backup.NewRestorer(nessus)
+	if *passwords != "" {
+		data, err := ioutil.ReadFile(*passwords)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &r.Passwords); err != nil {
+			return fmt.Errorf("%s: %v", *passwords, err)
+		}
+	}
+	report, err := r.Restore(a)
+	if err != nil {
+		return err
+	}
+	kinds := make([]string, 0, len(report.Created))
+	for kind := range report.Created {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(os.Stdout, "Created %d %s(s).\n", report.Created[kind], kind)
+	}
+	for _, f := range report.Failures {
+		fmt.Fprintf(os.Stderr, "Failed to restore %v\n", f)
+	}
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d objects could not be restored", len(report.Failures))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package main implements nessie, a command line tool managing a Nessus server
+// through subcommands:
+//
+//	nessie reconcile -spec scans.yaml [-dry_run] [-prune]
+//	nessie backup -out nessus.zip
+//	nessie restore -in nessus.zip [-passwords passwords.json]
+//	nessie migrate -src_api_url ... -dst_api_url ... -state state.json [-folders prod,lab]
+//
+// Run a subcommand with -h for its flags.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// command is a subcommand, run with the arguments following its name.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"backup":    {"Save the configuration of a server to an archive.", runBackup},
+	"migrate":   {"Copy the policies, scans and scan history of folders to another server.", runMigrate},
+	"reconcile": {"Bring the folders, policies and scans of a server to a declarative spec.", runReconcile},
+	"restore":   {"Restore an archive onto a server.", runRestore},
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: nessie <command> [flags]\n\nCommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+// server holds the flags connecting to a server, shared by the subcommands.
+type server struct {
+	apiURL, username, password, accessKey, secretKey, fingerprints string
+}
+
+// flags registers the connection flags on fs, prefixed by prefix so that a
+// subcommand can connect to two servers.
+func (s *server) flags(fs *flag.FlagSet, prefix string) {
+	fs.StringVar(&s.apiURL, prefix+"api_url", "", "URL of the nessus API.")
+	fs.StringVar(&s.username, prefix+"username", "", "Username to login with, in production read that from a file, do not set from the command line or it will end up in your history.")
+	fs.StringVar(&s.password, prefix+"password", "", "Password that matches the provided username, in production read that from a file, do not set from the command line or it will end up in your history.")
+	fs.StringVar(&s.accessKey, prefix+"access_key", "", "API access key, replaces username and password.")
+	fs.StringVar(&s.secretKey, prefix+"secret_key", "", "API secret key matching the access key.")
+	fs.StringVar(&s.fingerprints, prefix+"fingerprints", "", "Comma-separated list of SPKI Fingerprints for the Nessus server using SHA-256 encoded in base64.")
+}
+
+// connect returns a logged in client and a function to logout.
+func (s *server) connect() (nessie.Nessus, func(), error) {
+	if s.apiURL == "" {
+		return nil, nil, fmt.Errorf("missing API URL")
+	}
+	var err error
+	var nessus nessie.Nessus
+	switch {
+	case s.accessKey != "":
+		nessus, err = nessie.NewInsecureNessusWithAPICredentials(s.apiURL, s.accessKey, s.secretKey)
+	case len(s.fingerprints) > 0:
+		nessus, err = nessie.NewFingerprintedNessus(s.apiURL, strings.Split(s.fingerprints, ","))
+	default:
+		nessus, err = nessie.NewInsecureNessus(s.apiURL)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.accessKey != "" {
+		return nessus, func() {}, nil
+	}
+	if err := nessus.Login(s.username, s.password); err != nil {
+		return nil, nil, err
+	}
+	return nessus, func() { nessus.Logout() }, nil
+}
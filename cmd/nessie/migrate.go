@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/JerusJ/nessie/migrate"
+)
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var src, dst server
+	src.flags(fs, "src_")
+	dst.flags(fs, "dst_")
+	folders := fs.String("folders", "", "Comma separated names of the folders to migrate, all but the trash when empty.")
+	state := fs.String("state", "", "File keeping the progress of the migration, to resume it when run again. Use one file per source server.")
+	fs.Parse(args)
+
+	if *state == "" {
+		return fmt.Errorf("missing -state")
+	}
+	srcNessus, srcLogout, err := src.connect()
+	if err != nil {
+		return fmt.Errorf("source: %v", err)
+	}
+	defer srcLogout()
+	dstNessus, dstLogout, err := dst.connect()
+	if err != nil {
+		return fmt.Errorf("target: %v", err)
+	}
+	defer dstLogout()
+
+	m := migrate.NewMigrator(srcNessus, dstNessus)
+	m.StateFile = *state
+	if *folders != "" {
+		m.Folders = strings.Split(*folders, ",")
+	}
+	// The state is saved after every object, so an interrupted migration
+	// resumes where it stopped.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := m.Migrate(ctx)
+	if report != nil {
+		kinds := make([]string, 0, len(report.Migrated))
+		for kind := range report.Migrated {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(os.Stdout, "Migrated %d %s(s).\n", report.Migrated[kind], kind)
+		}
+		for _, f := range report.Failures {
+			fmt.Fprintf(os.Stderr, "Failed to migrate %v\n", f)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d objects could not be migrated, run again to retry them", len(report.Failures))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/JerusJ/nessie/reconcile"
+)
+
+func runReconcile(args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	var srv server
+	srv.flags(fs, "")
+	specPath := fs.String("spec", "", "YAML or JSON file declaring the folders, policies and scans.")
+	dryRun := fs.Bool("dry_run", false, "Print the plan without applying it.")
+	prune := fs.Bool("prune", false, "Delete the custom folders, policies and scans missing from the spec.")
+	fs.Parse(args)
+
+	if *specPath == "" {
+		return fmt.Errorf("missing -spec")
+	}
+	spec, err := reconcile.LoadSpec(*specPath)
+	if err != nil {
+		return err
+	}
+	nessus, logout, err := srv.connect()
+	if err != nil {
+		return err
+	}
+	defer logout()
+
+	r := reconcile.NewReconciler(nessus)
+	r.Prune = *prune
+	plan, err := r.Plan(spec)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, plan)
+	if *dryRun || plan.Empty() {
+		return nil
+	}
+	if err := r.Apply(plan); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, "Applied.")
+	return nil
+}
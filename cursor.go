@@ -0,0 +1,39 @@
+package nessie
+
+import "context"
+
+// ScanCursor lists the scans modified since its previous call, so that a
+// server with thousands of scans can be polled cheaply.
+type ScanCursor struct {
+	nessus ScanService
+	ts     Timestamp
+}
+
+// NewScanCursor returns a cursor whose first call lists every scan.
+func NewScanCursor(n ScanService) *ScanCursor {
+	return &ScanCursor{nessus: n}
+}
+
+// Next lists the scans modified since the previous successful call. Scans
+// modified in the same second as the previous call are listed again.
+func (c *ScanCursor) Next(ctx context.Context) (*ListScansResponse, error) {
+	resp, err := c.nessus.ScansSince(ctx, c.ts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Timestamp.After(c.ts.Time) {
+		c.ts = resp.Timestamp
+	}
+	return resp, nil
+}
+
+// Timestamp returns the server timestamp of the previous successful call,
+// zero before the first one.
+func (c *ScanCursor) Timestamp() Timestamp {
+	return c.ts
+}
+
+// Reset makes the next call list every scan again.
+func (c *ScanCursor) Reset() {
+	c.ts = Timestamp{}
+}
@@ -0,0 +1,410 @@
+// Package cyclonedx writes nessus findings as a CycloneDX 1.4 BOM listing the
+// scanned hosts, the software detected on them and their vulnerabilities.
+package cyclonedx
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/report"
+)
+
+const (
+	BOMFormat   = "CycloneDX"
+	SpecVersion = "1.4"
+)
+
+// Component types.
+const (
+	ComponentDevice          = "device"
+	ComponentOperatingSystem = "operating-system"
+	ComponentApplication     = "application"
+)
+
+// BOM is a CycloneDX bill of materials.
+type BOM struct {
+	BOMFormat       string          `json:"bomFormat"`
+	SpecVersion     string          `json:"specVersion"`
+	SerialNumber    string          `json:"serialNumber,omitempty"`
+	Version         int             `json:"version"`
+	Metadata        Metadata        `json:"metadata"`
+	Components      []Component     `json:"components"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+type Metadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     []Tool `json:"tools"`
+}
+
+type Tool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Component is a scanned host or a piece of software detected on it.
+type Component struct {
+	BOMRef     string     `json:"bom-ref"`
+	Type       string     `json:"type"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	Publisher  string     `json:"publisher,omitempty"`
+	CPE        string     `json:"cpe,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Vulnerability is a CVE, or a nessus plugin finding without CVE.
+type Vulnerability struct {
+	BOMRef         string     `json:"bom-ref"`
+	ID             string     `json:"id"`
+	Source         *Source    `json:"source,omitempty"`
+	Ratings        []Rating   `json:"ratings,omitempty"`
+	Description    string     `json:"description,omitempty"`
+	Recommendation string     `json:"recommendation,omitempty"`
+	Advisories     []Advisory `json:"advisories,omitempty"`
+	Affects        []Affect   `json:"affects"`
+	Properties     []Property `json:"properties,omitempty"`
+}
+
+type Source struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type Rating struct {
+	Source   *Source `json:"source,omitempty"`
+	Score    float64 `json:"score,omitempty"`
+	Severity string  `json:"severity"`
+	Method   string  `json:"method,omitempty"`
+}
+
+type Advisory struct {
+	URL string `json:"url"`
+}
+
+// Affect references the host or software component a vulnerability was found on.
+type Affect struct {
+	Ref      string          `json:"ref"`
+	Versions []AffectVersion `json:"versions,omitempty"`
+}
+
+type AffectVersion struct {
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// Options tune the generated BOM.
+type Options struct {
+	// ToolVersion is the version of nessus, e.g. ServerProperties.ServerVersion.
+	ToolVersion string
+	// Timestamp of the BOM, it defaults to the time the BOM is built.
+	Timestamp time.Time
+	// MinSeverity drops the less severe findings, nessie.SeverityLow drops the informational ones.
+	MinSeverity nessie.Severity
+}
+
+// Builder accumulates hosts and findings into a BOM.
+type Builder struct {
+	opts       Options
+	components []Component
+	// refs are the bom-refs of the components, which must be unique.
+	refs      map[string]bool
+	hosts     map[string]bool
+	vulns     map[string]*Vulnerability
+	vulnOrder []string
+}
+
+// NewBuilder returns an empty BOM builder.
+func NewBuilder(opts Options) *Builder {
+	return &Builder{
+		opts:  opts,
+		refs:  map[string]bool{},
+		hosts: map[string]bool{},
+		vulns: map[string]*Vulnerability{},
+	}
+}
+
+// AddHost adds a host component and the operating system and software
+// detected on it (from the operating-system and cpe-* host properties).
+// Adding a host twice is a no-op, hosts must be added before their findings
+// for their properties to be recorded.
+func (b *Builder) AddHost(name string, props report.HostProperties) {
+	ref := hostRef(name)
+	if b.hosts[name] {
+		return
+	}
+	b.hosts[name] = true
+	host := Component{BOMRef: ref, Type: ComponentDevice, Name: name}
+	for _, key := range []string{"host-ip", "host-fqdn", "netbios-name", "mac-address"} {
+		if v := props[key]; v != "" {
+			host.Properties = append(host.Properties, Property{Name: "nessus:" + key, Value: v})
+		}
+	}
+	b.addComponent(host)
+	if os := props.OS(); os != "" {
+		b.addComponent(Component{BOMRef: ref + "/os", Type: ComponentOperatingSystem, Name: os})
+	}
+	var cpeKeys []string
+	for key := range props {
+		if strings.HasPrefix(key, "cpe") {
+			cpeKeys = append(cpeKeys, key)
+		}
+	}
+	sort.Strings(cpeKeys)
+	for _, key := range cpeKeys {
+		if c, ok := cpeComponent(ref, props[key]); ok {
+			b.addComponent(c)
+		}
+	}
+}
+
+// addComponent adds a component unless one with the same bom-ref exists, e.g.
+// a CPE reported by both the cpe and cpe-0 host properties.
+func (b *Builder) addComponent(c Component) {
+	if b.refs[c.BOMRef] {
+		return
+	}
+	b.refs[c.BOMRef] = true
+	b.components = append(b.components, c)
+}
+
+// softwareRef returns the bom-ref of the software of the host a finding
+// reports with the given version: the CPE component of the host matching a
+// CPE of the plugin and the version, or else a component named after the
+// plugin and identified by its ID and the version.
+func (b *Builder) softwareRef(f *report.Finding, version string) string {
+	prefix := hostRef(f.Host) + "/"
+	for _, cpe := range f.CPEs {
+		want := prefix + strings.TrimSpace(cpe) + ":" + version
+		if b.refs[want] {
+			return want
+		}
+	}
+	ref := fmt.Sprintf("%snessus:%d:%s", prefix, f.PluginID, version)
+	b.addComponent(Component{BOMRef: ref, Type: ComponentApplication, Name: f.PluginName, Version: version})
+	return ref
+}
+
+// AddFinding adds the vulnerabilities of a finding, affecting the host it was
+// found on, or the software of the host when plugins report its version.
+func (b *Builder) AddFinding(f *report.Finding) {
+	if f.Severity < b.opts.MinSeverity {
+		return
+	}
+	b.AddHost(f.Host, nil)
+	affect := Affect{Ref: hostRef(f.Host)}
+	if v := installedVersion(f.PluginOutput); v != "" {
+		affect.Ref = b.softwareRef(f, v)
+		affect.Versions = []AffectVersion{{Version: v, Status: "affected"}}
+	}
+	ids := f.CVEs
+	if len(ids) == 0 {
+		ids = []string{fmt.Sprintf("NESSUS-%d", f.PluginID)}
+	}
+	for _, id := range ids {
+		v, ok := b.vulns[id]
+		if !ok {
+			v = newVulnerability(id, f)
+			b.vulns[id] = v
+			b.vulnOrder = append(b.vulnOrder, id)
+		}
+		addPluginProperty(v, f.PluginID)
+		if !hasAffect(v, affect.Ref) {
+			v.Affects = append(v.Affects, affect)
+		}
+	}
+}
+
+// BOM returns the bill of materials of everything added so far.
+func (b *Builder) BOM() *BOM {
+	ts := b.opts.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	bom := &BOM{
+		BOMFormat:    BOMFormat,
+		SpecVersion:  SpecVersion,
+		SerialNumber: serialNumber(),
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: ts.UTC().Format(time.RFC3339),
+			Tools:     []Tool{{Vendor: "Tenable", Name: "Nessus", Version: b.opts.ToolVersion}},
+		},
+		Components:      append([]Component{}, b.components...),
+		Vulnerabilities: []Vulnerability{},
+	}
+	for _, id := range b.vulnOrder {
+		bom.Vulnerabilities = append(bom.Vulnerabilities, *b.vulns[id])
+	}
+	return bom
+}
+
+// Write writes the BOM as indented JSON.
+func (bom *BOM) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
+
+// FromReport builds a BOM from a parsed .nessus report, including the host properties.
+func FromReport(r *report.Reader, opts Options) (*BOM, error) {
+	b := NewBuilder(opts)
+	err := r.Walk(func(host *report.ReportHost, item *report.ReportItem) error {
+		b.AddHost(host.Name, host.Properties)
+		if item != nil {
+			b.AddFinding(item.Finding(host))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.BOM(), nil
+}
+
+// FromScan builds a BOM from a scan fetched through the API, see report.ScanFindings.
+func FromScan(details *nessie.ScanDetailsResp, hosts map[int64]*nessie.HostDetailsResp, plugins map[int64]*nessie.PluginDetails, opts Options) *BOM {
+	b := NewBuilder(opts)
+	for _, host := range details.Hosts {
+		hostDetails, ok := hosts[host.HostID]
+		if !ok {
+			continue
+		}
+		props := report.HostProperties{
+			"host-ip":      hostDetails.Info.HostIP,
+			"host-fqdn":    hostDetails.Info.HostFQDN,
+			"netbios-name": hostDetails.Info.NetBIOSName,
+			"mac-address":  hostDetails.Info.MACAddress,
+		}
+		if os := hostDetails.Info.OperatingSystem; len(os) > 0 {
+			// Candidates are newline separated, as in .nessus reports.
+			props["operating-system"] = strings.Join(os, "\n")
+		}
+		b.AddHost(host.Hostname, props)
+	}
+	for _, f := range report.ScanFindings(details, hosts, plugins) {
+		b.AddFinding(f)
+	}
+	return b.BOM()
+}
+
+func hostRef(name string) string {
+	return "host:" + name
+}
+
+// cpeComponent turns a CPE 2.2 URI (cpe:/a:apache:http_server:2.4.41) into a component.
+func cpeComponent(hostRef, cpe string) (Component, bool) {
+	// Nessus sometimes appends a human readable name: "cpe:/a:x:y:1.0 -> X Y".
+	cpe = strings.TrimSpace(strings.SplitN(cpe, " ", 2)[0])
+	parts := strings.Split(strings.TrimPrefix(cpe, "cpe:/"), ":")
+	if !strings.HasPrefix(cpe, "cpe:/") || len(parts) < 3 {
+		return Component{}, false
+	}
+	c := Component{
+		BOMRef:    hostRef + "/" + cpe,
+		Type:      ComponentApplication,
+		Publisher: parts[1],
+		Name:      parts[2],
+		CPE:       cpe,
+	}
+	if parts[0] == "o" {
+		c.Type = ComponentOperatingSystem
+	}
+	if len(parts) > 3 {
+		c.Version = parts[3]
+	}
+	return c, true
+}
+
+var installedVersionRegex = regexp.MustCompile(`(?m)^\s*Installed version\s*:\s*(\S+)`)
+
+// installedVersion extracts the version plugins report as "Installed version : x".
+func installedVersion(output string) string {
+	m := installedVersionRegex.FindStringSubmatch(output)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+func newVulnerability(id string, f *report.Finding) *Vulnerability {
+	v := &Vulnerability{
+		BOMRef:         "vuln:" + id,
+		ID:             id,
+		Source:         &Source{Name: "Nessus", URL: fmt.Sprintf("https://www.tenable.com/plugins/nessus/%d", f.PluginID)},
+		Description:    f.Description,
+		Recommendation: f.Solution,
+	}
+	if f.Description == "" {
+		v.Description = f.Synopsis
+	}
+	if strings.HasPrefix(id, "CVE-") {
+		v.Source = &Source{Name: "NVD", URL: "https://nvd.nist.gov/vuln/detail/" + id}
+	}
+	nessusSource := &Source{Name: "Nessus"}
+	switch {
+	case f.CVSS3BaseScore > 0:
+		v.Ratings = append(v.Ratings, Rating{Source: nessusSource, Score: f.CVSS3BaseScore, Severity: severity(f.Severity), Method: "CVSSv3"})
+	case f.CVSSBaseScore > 0:
+		v.Ratings = append(v.Ratings, Rating{Source: nessusSource, Score: f.CVSSBaseScore, Severity: severity(f.Severity), Method: "CVSSv2"})
+	default:
+		v.Ratings = append(v.Ratings, Rating{Source: nessusSource, Severity: severity(f.Severity), Method: "other"})
+	}
+	for _, url := range f.SeeAlso {
+		v.Advisories = append(v.Advisories, Advisory{URL: url})
+	}
+	return v
+}
+
+// severity returns the CycloneDX severity of a nessus severity, which have
+// the same names.
+func severity(s nessie.Severity) string {
+	if !s.Valid() {
+		return "unknown"
+	}
+	return s.String()
+}
+
+func addPluginProperty(v *Vulnerability, pluginID int64) {
+	value := strconv.FormatInt(pluginID, 10)
+	for _, p := range v.Properties {
+		if p.Name == "nessus:plugin_id" && p.Value == value {
+			return
+		}
+	}
+	v.Properties = append(v.Properties, Property{Name: "nessus:plugin_id", Value: value})
+}
+
+func hasAffect(v *Vulnerability, ref string) bool {
+	for _, a := range v.Affects {
+		if a.Ref == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// serialNumber returns a random RFC 4122 version 4 UUID URN.
+func serialNumber() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return ""
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,133 @@
+package cyclonedx
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/report"
+)
+
+func TestFromReport(t *testing.T) {
+	f, err := os.Open("../report/testdata/sample.nessus")
+	if err != nil {
+		t.Fatalf("cannot open report: %v", err)
+	}
+	defer f.Close()
+
+	ts := time.Date(2021, 1, 11, 12, 0, 0, 0, time.UTC)
+	bom, err := FromReport(report.NewReader(f), Options{ToolVersion: "8.13.1", Timestamp: ts, MinSeverity: nessie.SeverityLow})
+	if err != nil {
+		t.Fatalf("cannot build bom: %v", err)
+	}
+	if bom.BOMFormat != BOMFormat || bom.SpecVersion != SpecVersion || bom.Metadata.Timestamp != "2021-01-11T12:00:00Z" || len(bom.SerialNumber) != 45 {
+		t.Errorf("wrong bom header, got=%+v", bom)
+	}
+
+	components := map[string]Component{}
+	for _, c := range bom.Components {
+		components[c.BOMRef] = c
+	}
+	// 3 hosts, 2 operating systems and 2 CPEs.
+	if len(components) != 7 {
+		t.Errorf("wrong number of components, got=%d: %+v", len(components), bom.Components)
+	}
+	apache, ok := components["host:192.0.2.10/cpe:/a:apache:http_server:2.4.41"]
+	if !ok || apache.Type != ComponentApplication || apache.Name != "http_server" || apache.Version != "2.4.41" || apache.Publisher != "apache" {
+		t.Errorf("wrong software component, got=%+v", apache)
+	}
+	if os := components["host:192.0.2.11/os"]; os.Type != ComponentOperatingSystem || os.Name != "Microsoft Windows Server 2019" {
+		t.Errorf("wrong os component, got=%+v", os)
+	}
+
+	vulns := map[string]Vulnerability{}
+	for _, v := range bom.Vulnerabilities {
+		vulns[v.ID] = v
+	}
+	// Apache has two CVEs, SSH and RDP one each, the informational finding is dropped.
+	if len(vulns) != 4 {
+		t.Fatalf("wrong number of vulnerabilities, got=%d: %+v", len(vulns), bom.Vulnerabilities)
+	}
+	v := vulns["CVE-2020-9490"]
+	if v.Source.Name != "NVD" || len(v.Ratings) != 1 || v.Ratings[0].Score != 9.8 || v.Ratings[0].Severity != "critical" || v.Ratings[0].Method != "CVSSv3" {
+		t.Errorf("wrong vulnerability, got=%+v", v)
+	}
+	if len(v.Affects) != 1 || v.Affects[0].Ref != "host:192.0.2.10/cpe:/a:apache:http_server:2.4.41" || len(v.Affects[0].Versions) != 1 || v.Affects[0].Versions[0].Version != "2.4.41" {
+		t.Errorf("wrong affects, got=%+v", v.Affects)
+	}
+	if len(v.Advisories) != 2 || v.Recommendation == "" {
+		t.Errorf("wrong advisories or recommendation, got=%+v", v)
+	}
+
+	var buf bytes.Buffer
+	if err := bom.Write(&buf); err != nil {
+		t.Fatalf("cannot write bom: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Error("bom is not valid json")
+	}
+}
+
+func TestFromScan(t *testing.T) {
+	details := &nessie.ScanDetailsResp{Hosts: []nessie.Host{{HostID: 2, Hostname: "web"}, {HostID: 3, Hostname: "db"}}}
+	web := &nessie.HostDetailsResp{Vulnerabilities: []nessie.HostVulnerability{{PluginID: 70658, Severity: 2}}}
+	web.Info.OperatingSystem = nessie.FlexList[string]{"Linux Kernel 5.4"}
+	db := &nessie.HostDetailsResp{Vulnerabilities: []nessie.HostVulnerability{{PluginID: 70658, Severity: 2}, {PluginID: 18405, Severity: 2}}}
+	plugins := map[int64]*nessie.PluginDetails{70658: {Attrs: []nessie.PluginAttr{{Name: "cve", Val: "CVE-2008-5161"}}}}
+
+	bom := FromScan(details, map[int64]*nessie.HostDetailsResp{2: web, 3: db}, plugins, Options{})
+	if len(bom.Components) != 3 {
+		t.Errorf("wrong components, got=%+v", bom.Components)
+	}
+	if len(bom.Vulnerabilities) != 2 {
+		t.Fatalf("wrong vulnerabilities, got=%+v", bom.Vulnerabilities)
+	}
+	if v := bom.Vulnerabilities[0]; v.ID != "CVE-2008-5161" || len(v.Affects) != 2 {
+		t.Errorf("vulnerability should affect both hosts, got=%+v", v)
+	}
+	if v := bom.Vulnerabilities[1]; v.ID != "NESSUS-18405" || v.Source.Name != "Nessus" || v.Ratings[0].Severity != "medium" {
+		t.Errorf("wrong vulnerability without cve, got=%+v", v)
+	}
+}
+
+func TestBuilderRefs(t *testing.T) {
+	b := NewBuilder(Options{})
+	b.AddHost("web", report.HostProperties{
+		"operating-system": "Linux Kernel 5.4",
+		"cpe":              "cpe:/o:linux:linux_kernel:5.4",
+		"cpe-0":            "cpe:/o:linux:linux_kernel:5.4",
+		"cpe-1":            "cpe:/a:openbsd:openssh:8.2 -> OpenBSD OpenSSH",
+	})
+	b.AddFinding(&report.Finding{Host: "web", PluginID: 10, Severity: 2, CVEs: []string{"CVE-1"}, CPEs: []string{"cpe:/a:openbsd:openssh"}, PluginOutput: "Installed version : 8.2"})
+	b.AddFinding(&report.Finding{Host: "web", PluginID: 20, PluginName: "PHP", Severity: 2, CVEs: []string{"CVE-2"}, PluginOutput: "Installed version : 1.0"})
+	b.AddFinding(&report.Finding{Host: "web", PluginID: 20, PluginName: "PHP", Severity: 2, CVEs: []string{"CVE-3"}, PluginOutput: "Installed version : 7.4.3"})
+	b.AddFinding(&report.Finding{Host: "web", PluginID: 30, PluginName: "Tomcat", Severity: 2, CVEs: []string{"CVE-4"}, CPEs: []string{"cpe:/a:apache:tomcat"}, PluginOutput: "Installed version : 1.0"})
+	bom := b.BOM()
+
+	refs := map[string]Component{}
+	for _, c := range bom.Components {
+		if _, dup := refs[c.BOMRef]; dup {
+			t.Errorf("duplicate bom-ref %q", c.BOMRef)
+		}
+		refs[c.BOMRef] = c
+	}
+	if len(bom.Components) != 7 {
+		t.Errorf("wrong components, got=%+v", bom.Components)
+	}
+	for _, c := range bom.Components {
+		if c.BOMRef == "host:web" && c.Version != "" {
+			t.Errorf("host should have no version, got=%+v", c)
+		}
+	}
+	// The version is on the CPE component of the software, software sharing
+	// a version and a plugin reporting several versions are distinct components.
+	for i, want := range []string{"host:web/cpe:/a:openbsd:openssh:8.2", "host:web/nessus:20:1.0", "host:web/nessus:20:7.4.3", "host:web/nessus:30:1.0"} {
+		a := bom.Vulnerabilities[i].Affects[0]
+		if a.Ref != want || refs[a.Ref].Version != a.Versions[0].Version {
+			t.Errorf("vulnerability %d should affect %s, got=%+v (component %+v)", i, want, a, refs[a.Ref])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package nessie
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Chapters of the PDF and HTML exports.
+const (
+	ChapterVulnHostsSummary = "vuln_hosts_summary"
+	ChapterVulnByHost       = "vuln_by_host"
+	ChapterVulnByPlugin     = "vuln_by_plugin"
+	ChapterRemediations     = "remediations"
+	ChapterCompliance       = "compliance"
+	ChapterComplianceExec   = "compliance_exec"
+)
+
+// Columns of the CSV export.
+const (
+	CSVColumnPluginID           = "id"
+	CSVColumnCVE                = "cve"
+	CSVColumnCVSS               = "cvss"
+	CSVColumnCVSS3              = "cvss3_base_score"
+	CSVColumnRisk               = "risk"
+	CSVColumnHostname           = "hostname"
+	CSVColumnProtocol           = "protocol"
+	CSVColumnPort               = "port"
+	CSVColumnPluginName         = "plugin_name"
+	CSVColumnSynopsis           = "synopsis"
+	CSVColumnDescription        = "description"
+	CSVColumnSolution           = "solution"
+	CSVColumnSeeAlso            = "see_also"
+	CSVColumnPluginOutput       = "plugin_output"
+	CSVColumnRiskFactor         = "risk_factor"
+	CSVColumnReferences         = "references"
+	CSVColumnPluginInformation  = "plugin_information"
+	CSVColumnExploitableWith    = "exploitable_with"
+	CSVColumnSTIGSeverity       = "stig_severity"
+	CSVColumnCVSSTemporalScore  = "cvss_temporal_score"
+	CSVColumnCVSS3TemporalScore = "cvss3_temporal_score"
+)
+
+// ExportOptions are the parameters of a scan export.
+type ExportOptions struct {
+	// Format is one of ExportNessus, ExportPDF, ExportHTML, ExportCSV or ExportDB.
+	Format string
+	// TemplateID is the report template to use for PDF and HTML exports.
+	TemplateID int64
+	// Chapters to include in PDF and HTML exports, at least one is required for those formats.
+	Chapters []string
+	// Filters restrict the exported hosts and findings, not supported by the DB export.
+	Filters *FilterSet
+	// HistoryID selects a previous run of the scan, 0 exports the latest one.
+	HistoryID int64
+	// Password encrypts the DB export, it is required for that format.
+	Password string
+	// CSVColumns selects the columns of the CSV export, empty means the nessus defaults.
+	CSVColumns []string
+}
+
+// Validate checks the options are valid for the requested format.
+func (o ExportOptions) Validate() error {
+	switch o.Format {
+	case ExportNessus, ExportPDF, ExportHTML, ExportCSV, ExportDB:
+	default:
+		return fmt.Errorf("unsupported export format %q", o.Format)
+	}
+	isReport := o.Format == ExportPDF || o.Format == ExportHTML
+	if isReport && len(o.Chapters) == 0 {
+		return fmt.Errorf("%s export requires at least one chapter", o.Format)
+	}
+	if !isReport && len(o.Chapters) > 0 {
+		return fmt.Errorf("chapters are only supported by %s and %s exports", ExportPDF, ExportHTML)
+	}
+	if o.Format == ExportDB && o.Password == "" {
+		return fmt.Errorf("%s export requires a password", ExportDB)
+	}
+	if o.Format != ExportDB && o.Password != "" {
+		return fmt.Errorf("password is only supported by %s exports", ExportDB)
+	}
+	if o.Format == ExportDB && o.Filters != nil && len(o.Filters.Rules) > 0 {
+		return fmt.Errorf("filters are not supported by %s exports", ExportDB)
+	}
+	if o.Format != ExportCSV && len(o.CSVColumns) > 0 {
+		return fmt.Errorf("columns are only supported by %s exports", ExportCSV)
+	}
+	if o.HistoryID < 0 {
+		return fmt.Errorf("invalid history id %d", o.HistoryID)
+	}
+	return nil
+}
+
+func (o ExportOptions) request() exportScanRequest {
+	req := exportScanRequest{
+		Format:     o.Format,
+		TemplateID: o.TemplateID,
+		Chapters:   strings.Join(o.Chapters, ";"),
+		Password:   o.Password,
+		Filters:    o.Filters,
+	}
+	if len(o.CSVColumns) > 0 {
+		req.ReportContents = &exportReportContents{CSVColumns: map[string]bool{}}
+		for _, c := range o.CSVColumns {
+			req.ReportContents.CSVColumns[c] = true
+		}
+	}
+	return req
+}
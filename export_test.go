@@ -0,0 +1,90 @@
+package nessie
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportOptionsValidate(t *testing.T) {
+	var tests = []struct {
+		opts      ExportOptions
+		wantError bool
+	}{
+		{ExportOptions{Format: ExportNessus}, false},
+		{ExportOptions{Format: ExportNessus, HistoryID: 12, Filters: NewFilterSet(FilterTypeAnd).Add("severity", "eq", "4")}, false},
+		{ExportOptions{Format: ExportPDF, Chapters: []string{ChapterVulnHostsSummary, ChapterVulnByPlugin}}, false},
+		{ExportOptions{Format: ExportCSV, CSVColumns: []string{CSVColumnPluginID, CSVColumnCVE}}, false},
+		{ExportOptions{Format: ExportDB, Password: "s3cret"}, false},
+		// Unknown format.
+		{ExportOptions{Format: "docx"}, true},
+		// Reports need chapters.
+		{ExportOptions{Format: ExportHTML}, true},
+		// Only reports have chapters.
+		{ExportOptions{Format: ExportCSV, Chapters: []string{ChapterVulnByHost}}, true},
+		// DB exports must be encrypted.
+		{ExportOptions{Format: ExportDB}, true},
+		// Only DB exports are encrypted.
+		{ExportOptions{Format: ExportNessus, Password: "s3cret"}, true},
+		// DB exports cannot be filtered.
+		{ExportOptions{Format: ExportDB, Password: "s3cret", Filters: NewFilterSet(FilterTypeAnd).Add("severity", "eq", "4")}, true},
+		// Only CSV exports have columns.
+		{ExportOptions{Format: ExportNessus, CSVColumns: []string{CSVColumnCVE}}, true},
+		{ExportOptions{Format: ExportNessus, HistoryID: -1}, true},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.wantError && err == nil {
+			t.Errorf("got no error, expected one (%+v)", tt.opts)
+		}
+		if !tt.wantError && err != nil {
+			t.Errorf("got error validating options: %v (%+v)", err, tt.opts)
+		}
+	}
+}
+
+func TestExportScanWithOptions(t *testing.T) {
+	var gotQuery string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scans/42/export" {
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("could not decode request body: %v", err)
+		}
+		w.Write([]byte(`{"file": 7}`))
+	}))
+	defer server.Close()
+	n, err := NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+
+	opts := ExportOptions{
+		Format:     ExportCSV,
+		HistoryID:  12,
+		Filters:    NewFilterSet(FilterTypeAnd).Add("severity", "eq", "Critical"),
+		CSVColumns: []string{CSVColumnPluginID, CSVColumnHostname},
+	}
+	id, err := n.ExportScanWithOptions(42, opts)
+	if err != nil {
+		t.Fatalf("got error exporting scan: %v", err)
+	}
+	if id != 7 {
+		t.Errorf("wrong export id, got=%d, want=7", id)
+	}
+	if want := "history_id=12"; gotQuery != want {
+		t.Errorf("wrong query, got=%s, want=%s", gotQuery, want)
+	}
+	j, _ := json.Marshal(gotBody)
+	if got, want := string(j), `{"filter.0.filter":"severity","filter.0.quality":"eq","filter.0.value":"Critical","filter.search_type":"and","format":"csv","reportContents":{"csvColumns":{"hostname":true,"id":true}},"template_id":0}`; got != want {
+		t.Errorf("wrong body, got=%s, want=%s", got, want)
+	}
+
+	if _, err := n.ExportScanWithOptions(42, ExportOptions{Format: ExportDB}); err == nil {
+		t.Error("got no error for an invalid export")
+	}
+}
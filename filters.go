@@ -0,0 +1,164 @@
+package nessie
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FilterRule is a single condition of a FilterSet.
+type FilterRule struct {
+	// Field is the name of an advertised Filter, e.g. "plugin_id" or "severity".
+	Field string
+	// Operator is one of the advertised Filter.Operators, e.g. "eq" or "match".
+	Operator string
+	Value    string
+}
+
+// FilterSet is a list of conditions used to filter scan details and exports.
+// It serializes to the filter.<idx>.filter/quality/value parameters expected by nessus.
+// Scan listings are not filtered: GET /scans only takes folder_id and last_modification_date.
+type FilterSet struct {
+	// SearchType is FilterTypeAnd or FilterTypeOr, it defaults to FilterTypeAnd.
+	SearchType string
+	Rules      []FilterRule
+}
+
+// NewFilterSet returns an empty filter set combining its rules with searchType.
+func NewFilterSet(searchType string) *FilterSet {
+	return &FilterSet{SearchType: searchType}
+}
+
+// Add appends a rule to the filter set and returns it to allow chaining.
+func (fs *FilterSet) Add(field, operator, value string) *FilterSet {
+	fs.Rules = append(fs.Rules, FilterRule{Field: field, Operator: operator, Value: value})
+	return fs
+}
+
+// Params returns the filter set as the flat key/value pairs used in query strings and export requests.
+func (fs *FilterSet) Params() map[string]string {
+	params := map[string]string{}
+	if fs == nil || len(fs.Rules) == 0 {
+		return params
+	}
+	for i, rule := range fs.Rules {
+		prefix := "filter." + strconv.Itoa(i) + "."
+		params[prefix+"filter"] = rule.Field
+		params[prefix+"quality"] = rule.Operator
+		params[prefix+"value"] = rule.Value
+	}
+	params["filter.search_type"] = fs.searchType()
+	return params
+}
+
+// Query returns the filter set encoded as a query string, without the leading '?'.
+// Rules are encoded in index order, filter.2 before filter.10.
+func (fs *FilterSet) Query() string {
+	if fs == nil || len(fs.Rules) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, rule := range fs.Rules {
+		prefix := "filter." + strconv.Itoa(i) + "."
+		b.WriteString(prefix + "filter=" + url.QueryEscape(rule.Field))
+		b.WriteString("&" + prefix + "quality=" + url.QueryEscape(rule.Operator))
+		b.WriteString("&" + prefix + "value=" + url.QueryEscape(rule.Value) + "&")
+	}
+	b.WriteString("filter.search_type=" + url.QueryEscape(fs.searchType()))
+	return b.String()
+}
+
+// MarshalJSON serializes the filter set as the flat object expected in export requests.
+func (fs *FilterSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fs.Params())
+}
+
+// NotificationFilters converts the rules of the filter set to scan notification filters.
+func (fs *FilterSet) NotificationFilters() []NotificationFilter {
+	var filters []NotificationFilter
+	if fs == nil {
+		return filters
+	}
+	for _, rule := range fs.Rules {
+		filters = append(filters, NotificationFilter{Field: rule.Field, Operator: rule.Operator, Value: rule.Value})
+	}
+	return filters
+}
+
+// Validate checks every rule against the filters advertised by nessus, as returned in ScanDetailsResp.Filters.
+// A nil filter set is valid.
+func (fs *FilterSet) Validate(available []Filter) error {
+	if fs == nil {
+		return nil
+	}
+	switch fs.SearchType {
+	case "", FilterTypeAnd, FilterTypeOr:
+	default:
+		return fmt.Errorf("invalid filter search type %q", fs.SearchType)
+	}
+	byName := make(map[string]Filter, len(available))
+	for _, f := range available {
+		byName[f.Name] = f
+	}
+	for _, rule := range fs.Rules {
+		f, ok := byName[rule.Field]
+		if !ok {
+			return fmt.Errorf("unknown filter %q", rule.Field)
+		}
+		if !containsString(f.Operators, rule.Operator) {
+			return fmt.Errorf("filter %q does not support operator %q (supported: %v)", rule.Field, rule.Operator, f.Operators)
+		}
+		for _, c := range f.Controls {
+			if _, err := regexp.Compile(c.Regex); err != nil {
+				return fmt.Errorf("invalid regex %q for filter %q: %v", c.Regex, rule.Field, err)
+			}
+		}
+		if !f.accepts(rule.Value) {
+			return fmt.Errorf("invalid value %q for filter %q", rule.Value, rule.Field)
+		}
+	}
+	return nil
+}
+
+func (fs *FilterSet) searchType() string {
+	if fs.SearchType == "" {
+		return FilterTypeAnd
+	}
+	return fs.SearchType
+}
+
+// accepts returns whether any of the filter controls accepts the value.
+// Controls without options nor a regex accept anything, an invalid regex accepts nothing.
+func (f Filter) accepts(value string) bool {
+	if len(f.Controls) == 0 {
+		return true
+	}
+	for _, c := range f.Controls {
+		if len(c.Ooptions) > 0 {
+			if containsString(c.Ooptions, value) {
+				return true
+			}
+			continue
+		}
+		if c.Regex == "" {
+			return true
+		}
+		re, err := regexp.Compile(c.Regex)
+		if err == nil && re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
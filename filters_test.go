@@ -0,0 +1,110 @@
+package nessie
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFilterSetSerialization(t *testing.T) {
+	fs := NewFilterSet(FilterTypeOr).Add("severity", "eq", "4").Add("plugin_name", "match", "ssl cert")
+
+	if got, want := fs.Query(), "filter.0.filter=severity&filter.0.quality=eq&filter.0.value=4&filter.1.filter=plugin_name&filter.1.quality=match&filter.1.value=ssl+cert&filter.search_type=or"; got != want {
+		t.Errorf("wrong query, got=%s, want=%s", got, want)
+	}
+
+	j, err := json.Marshal(fs)
+	if err != nil {
+		t.Fatalf("cannot serialize filter set: %v", err)
+	}
+	if got, want := string(j), `{"filter.0.filter":"severity","filter.0.quality":"eq","filter.0.value":"4","filter.1.filter":"plugin_name","filter.1.quality":"match","filter.1.value":"ssl cert","filter.search_type":"or"}`; got != want {
+		t.Errorf("wrong json, got=%s, want=%s", got, want)
+	}
+
+	var empty *FilterSet
+	if got := empty.Query(); got != "" {
+		t.Errorf("nil filter set should have an empty query, got=%s", got)
+	}
+	if got := empty.NotificationFilters(); got != nil {
+		t.Errorf("nil filter set should have no notification filters, got=%+v", got)
+	}
+	if got := NewFilterSet("").Query(); got != "" {
+		t.Errorf("empty filter set should have an empty query, got=%s", got)
+	}
+
+	many := NewFilterSet(FilterTypeAnd)
+	for i := 0; i < 11; i++ {
+		many.Add("plugin_id", "eq", strconv.Itoa(i))
+	}
+	q := many.Query()
+	if i2, i10 := strings.Index(q, "filter.2.filter"), strings.Index(q, "filter.10.filter"); i2 < 0 || i10 < 0 || i2 > i10 {
+		t.Errorf("rules should be in index order, got=%s", q)
+	}
+	if _, err := url.ParseQuery(q); err != nil {
+		t.Errorf("invalid query %s: %v", q, err)
+	}
+}
+
+func TestFilterSetValidate(t *testing.T) {
+	available := []Filter{
+		{Name: "plugin_id", Operators: []string{"eq", "neq", "match", "nmatch"}, Controls: []FilterControls{{Type: "entry", Regex: "^[0-9, ]+$"}}},
+		{Name: "severity", Operators: []string{"eq", "neq"}, Controls: []FilterControls{{Type: "dropdown", Ooptions: []string{"None", "Low", "Medium", "High", "Critical"}}}},
+		{Name: "hostname", Operators: []string{"eq", "match"}, Controls: []FilterControls{{Type: "entry"}}},
+		{Name: "cpe", Operators: []string{"eq"}, Controls: []FilterControls{{Type: "entry", Regex: "^(cpe"}}},
+	}
+	var tests = []struct {
+		fs        *FilterSet
+		wantError bool
+	}{
+		{nil, false},
+		{NewFilterSet(FilterTypeAnd), false},
+		{NewFilterSet(FilterTypeAnd).Add("plugin_id", "eq", "19506"), false},
+		{NewFilterSet(FilterTypeOr).Add("severity", "neq", "Low").Add("hostname", "match", "web"), false},
+		// Unknown search type.
+		{NewFilterSet("xor").Add("plugin_id", "eq", "19506"), true},
+		// Unknown filter.
+		{NewFilterSet(FilterTypeAnd).Add("port", "eq", "443"), true},
+		// Unsupported operator.
+		{NewFilterSet(FilterTypeAnd).Add("severity", "gt", "Low"), true},
+		// Value not matching the control regex.
+		{NewFilterSet(FilterTypeAnd).Add("plugin_id", "eq", "abc"), true},
+		// Value not in the control options.
+		{NewFilterSet(FilterTypeAnd).Add("severity", "eq", "4"), true},
+		// Control with an invalid regex.
+		{NewFilterSet(FilterTypeAnd).Add("cpe", "eq", "cpe:/a:openbsd:openssh"), true},
+	}
+	for _, tt := range tests {
+		err := tt.fs.Validate(available)
+		if tt.wantError && err == nil {
+			t.Errorf("got no error, expected one (%+v)", tt.fs)
+		}
+		if !tt.wantError && err != nil {
+			t.Errorf("got error validating filters: %v (%+v)", err, tt.fs)
+		}
+	}
+}
+
+func TestScanDetailsFiltered(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/scans/42" {
+			gotQuery = r.URL.RawQuery
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	n, err := NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	if _, err := n.ScanDetailsFiltered(42, NewFilterSet(FilterTypeAnd).Add("severity", "eq", "Critical")); err != nil {
+		t.Fatalf("got error getting scan details: %v", err)
+	}
+	if want := "filter.0.filter=severity&filter.0.quality=eq&filter.0.value=Critical&filter.search_type=and"; gotQuery != want {
+		t.Errorf("wrong query, got=%s, want=%s", gotQuery, want)
+	}
+}
@@ -0,0 +1,333 @@
+package nessie
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Member is a server of a fleet.
+type Member struct {
+	// Name identifies the server in the results, it is unique in a fleet.
+	Name string
+	// Labels describe the server, e.g. {"region": "eu-west"}.
+	Labels map[string]string
+	Nessus Nessus
+}
+
+// Fleet queries several standalone servers at once. The results of the servers
+// answering are returned along with a *FleetError listing the others, so that a
+// server down does not hide the rest of the fleet.
+type Fleet struct {
+	members []Member
+	// concurrency is the maximum number of servers queried at once.
+	concurrency int
+}
+
+// NewFleet returns a fleet querying at most concurrency servers at once, all of
+// them when concurrency is 0 or less. The clients must be logged in.
+func NewFleet(concurrency int, members ...Member) (*Fleet, error) {
+	names := map[string]bool{}
+	for _, m := range members {
+		if m.Name == "" {
+			return nil, fmt.Errorf("fleet member without name")
+		}
+		if names[m.Name] {
+			return nil, fmt.Errorf("duplicate fleet member %q", m.Name)
+		}
+		names[m.Name] = true
+		if m.Nessus == nil {
+			return nil, fmt.Errorf("fleet member %q has no client", m.Name)
+		}
+	}
+	if concurrency <= 0 {
+		concurrency = len(members)
+	}
+	return &Fleet{members: members, concurrency: concurrency}, nil
+}
+
+// Members returns the servers of the fleet.
+func (f *Fleet) Members() []Member {
+	return f.members
+}
+
+// Member returns the server of the fleet with the given name.
+func (f *Fleet) Member(name string) (Member, bool) {
+	for _, m := range f.members {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// Select returns the fleet of the servers having all the given labels.
+func (f *Fleet) Select(labels map[string]string) *Fleet {
+	sub := &Fleet{concurrency: f.concurrency}
+	for _, m := range f.members {
+		matches := true
+		for k, v := range labels {
+			if m.Labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			sub.members = append(sub.members, m)
+		}
+	}
+	return sub
+}
+
+// ServerError is the failure of a server of a fleet.
+type ServerError struct {
+	Server string
+	Err    error
+}
+
+func (e ServerError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Server, e.Err)
+}
+
+func (e ServerError) Unwrap() error {
+	return e.Err
+}
+
+// FleetError lists the servers of a fleet that failed a query.
+type FleetError struct {
+	// Errors are in the order of the members of the fleet.
+	Errors []ServerError
+}
+
+func (e *FleetError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d servers failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Failed returns whether the server failed the query.
+func (e *FleetError) Failed(server string) bool {
+	for _, err := range e.Errors {
+		if err.Server == server {
+			return true
+		}
+	}
+	return false
+}
+
+// each calls query for every member, at most f.concurrency at once. Members
+// not started when ctx is done fail with its error.
+func (f *Fleet) each(ctx context.Context, query func(i int, m Member) error) error {
+	errs := make([]error, len(f.members))
+	sem := make(chan struct{}, f.concurrency)
+	var wg sync.WaitGroup
+	for i, m := range f.members {
+		wg.Add(1)
+		go func(i int, m Member) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = query(i, m)
+		}(i, m)
+	}
+	wg.Wait()
+
+	var fe FleetError
+	for i, err := range errs {
+		if err != nil {
+			fe.Errors = append(fe.Errors, ServerError{Server: f.members[i].Name, Err: err})
+		}
+	}
+	if len(fe.Errors) > 0 {
+		return &fe
+	}
+	return nil
+}
+
+// FleetScan is a scan of a server of a fleet.
+type FleetScan struct {
+	Server string
+	Scan
+}
+
+// Scans lists the scans of the fleet, by server in the order of the members.
+func (f *Fleet) Scans(ctx context.Context) ([]FleetScan, error) {
+	results := make([][]Scan, len(f.members))
+	err := f.each(ctx, func(i int, m Member) error {
+		list, err := m.Nessus.Scans()
+		if err != nil {
+			return err
+		}
+		results[i] = list.Scans
+		return nil
+	})
+	var scans []FleetScan
+	for i, list := range results {
+		for _, sc := range list {
+			scans = append(scans, FleetScan{Server: f.members[i].Name, Scan: sc})
+		}
+	}
+	return scans, err
+}
+
+// FleetScanner is a scanner of a server of a fleet.
+type FleetScanner struct {
+	Server string
+	Scanner
+}
+
+// Scanners lists the scanners of the fleet, by server in the order of the
+// members.
+func (f *Fleet) Scanners(ctx context.Context) ([]FleetScanner, error) {
+	results := make([][]Scanner, len(f.members))
+	err := f.each(ctx, func(i int, m Member) error {
+		list, err := m.Nessus.Scanners()
+		if err != nil {
+			return err
+		}
+		results[i] = list
+		return nil
+	})
+	var scanners []FleetScanner
+	for i, list := range results {
+		for _, s := range list {
+			scanners = append(scanners, FleetScanner{Server: f.members[i].Name, Scanner: s})
+		}
+	}
+	return scanners, err
+}
+
+// ServerStatus returns the status of the servers of the fleet answering, by
+// name.
+func (f *Fleet) ServerStatus(ctx context.Context) (map[string]*ServerStatus, error) {
+	results := make([]*ServerStatus, len(f.members))
+	err := f.each(ctx, func(i int, m Member) error {
+		status, err := m.Nessus.ServerStatus()
+		if err != nil {
+			return err
+		}
+		results[i] = status
+		return nil
+	})
+	statuses := map[string]*ServerStatus{}
+	for i, status := range results {
+		if status != nil {
+			statuses[f.members[i].Name] = status
+		}
+	}
+	return statuses, err
+}
+
+// FleetVulnerability is a plugin with findings on servers of a fleet.
+type FleetVulnerability struct {
+	PluginID   int64
+	PluginName string
+	Severity   Severity
+	// Count is the number of findings over the fleet.
+	Count int64
+	// Servers are the names of the servers with findings, sorted.
+	Servers []string
+}
+
+// FleetSummary summarizes the vulnerabilities found by the scans of a fleet.
+type FleetSummary struct {
+	// Servers are the severity counts of the hosts of each server answering.
+	Servers map[string]SeverityCounts
+	// Total is the sum of the counts of the servers.
+	Total SeverityCounts
+	// Vulnerabilities are sorted by decreasing severity, then count.
+	Vulnerabilities []FleetVulnerability
+}
+
+// VulnerabilitySummary summarizes the results of the scans of the fleet whose
+// last run completed, or which were imported. A host scanned by several scans
+// is counted for each of them. The scans of a server are read one at a time.
+func (f *Fleet) VulnerabilitySummary(ctx context.Context) (*FleetSummary, error) {
+	results := make([][]*ScanDetailsResp, len(f.members))
+	err := f.each(ctx, func(i int, m Member) error {
+		list, err := m.Nessus.Scans()
+		if err != nil {
+			return err
+		}
+		trash := int64(-1)
+		for _, folder := range list.Folders {
+			if folder.Type == "trash" {
+				trash = folder.ID
+			}
+		}
+		// Failed servers keep nil details.
+		details := []*ScanDetailsResp{}
+		for _, sc := range list.Scans {
+			if sc.FolderID == trash || (sc.Status != "completed" && sc.Status != "imported") {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			d, err := m.Nessus.ScanDetails(sc.ID)
+			if err != nil {
+				return fmt.Errorf("cannot get details of scan %q: %v", sc.Name, err)
+			}
+			details = append(details, d)
+		}
+		results[i] = details
+		return nil
+	})
+
+	summary := &FleetSummary{Servers: map[string]SeverityCounts{}}
+	vulns := map[int64]*FleetVulnerability{}
+	for i, details := range results {
+		if details == nil {
+			continue
+		}
+		server := f.members[i].Name
+		var counts SeverityCounts
+		for _, d := range details {
+			c := d.SeverityCounts()
+			for _, s := range Severities() {
+				counts.Add(s, c.Get(s))
+				summary.Total.Add(s, c.Get(s))
+			}
+			for _, v := range d.Vulnerabilities {
+				fv, ok := vulns[v.PluginID]
+				if !ok {
+					fv = &FleetVulnerability{PluginID: v.PluginID, PluginName: v.PluginName, Severity: v.Severity}
+					vulns[v.PluginID] = fv
+				}
+				fv.Count += v.Count
+				if n := len(fv.Servers); n == 0 || fv.Servers[n-1] != server {
+					fv.Servers = append(fv.Servers, server)
+				}
+			}
+		}
+		summary.Servers[server] = counts
+	}
+	for _, fv := range vulns {
+		sort.Strings(fv.Servers)
+		summary.Vulnerabilities = append(summary.Vulnerabilities, *fv)
+	}
+	sort.Slice(summary.Vulnerabilities, func(i, j int) bool {
+		a, b := summary.Vulnerabilities[i], summary.Vulnerabilities[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.PluginID < b.PluginID
+	})
+	return summary, err
+}
@@ -0,0 +1,198 @@
+package nessie
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fleetServer implements the part of Nessus used by fleets, other methods
+// panic.
+type fleetServer struct {
+	Nessus
+	scans   []Scan
+	details map[int64]*ScanDetailsResp
+	err     error
+	// inflight and peak count the concurrent calls of all the servers.
+	inflight, peak *int32
+}
+
+func (s *fleetServer) enter() func() {
+	if s.inflight == nil {
+		return func() {}
+	}
+	n := atomic.AddInt32(s.inflight, 1)
+	for {
+		peak := atomic.LoadInt32(s.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(s.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return func() { atomic.AddInt32(s.inflight, -1) }
+}
+
+func (s *fleetServer) Scans() (*ListScansResponse, error) {
+	defer s.enter()()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &ListScansResponse{Folders: []Folder{{ID: 2, Type: "trash"}, {ID: 3, Type: "main"}}, Scans: s.scans}, nil
+}
+
+func (s *fleetServer) ScanDetails(scanID int64) (*ScanDetailsResp, error) {
+	return s.details[scanID], nil
+}
+
+func (s *fleetServer) Scanners() ([]Scanner, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []Scanner{{ID: 1, Name: "Local Scanner", Type: "local"}}, nil
+}
+
+func (s *fleetServer) ServerStatus() (*ServerStatus, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &ServerStatus{Status: ServerStatusReady, Progress: 100}, nil
+}
+
+func details(hosts []Host, vulns ...Vulnerability) *ScanDetailsResp {
+	d := &ScanDetailsResp{Hosts: hosts}
+	d.Vulnerabilities = vulns
+	return d
+}
+
+func testFleet(t *testing.T) *Fleet {
+	t.Helper()
+	eu := &fleetServer{
+		scans: []Scan{
+			{ID: 1, Name: "weekly", Status: "completed", FolderID: 3},
+			{ID: 2, Name: "running", Status: "running", FolderID: 3},
+			{ID: 3, Name: "deleted", Status: "completed", FolderID: 2},
+		},
+		details: map[int64]*ScanDetailsResp{
+			1: details([]Host{{Hostname: "a", Critical: 1, High: 2}},
+				Vulnerability{PluginID: 10, PluginName: "OpenSSL", Severity: SeverityCritical, Count: 1},
+				Vulnerability{PluginID: 20, PluginName: "TLS", Severity: SeverityHigh, Count: 2}),
+		},
+	}
+	us := &fleetServer{
+		scans: []Scan{{ID: 7, Name: "daily", Status: "imported", FolderID: 3}},
+		details: map[int64]*ScanDetailsResp{
+			7: details([]Host{{Hostname: "b", Critical: 2, Low: 1}},
+				Vulnerability{PluginID: 10, PluginName: "OpenSSL", Severity: SeverityCritical, Count: 2},
+				Vulnerability{PluginID: 30, PluginName: "Banner", Severity: SeverityLow, Count: 1}),
+		},
+	}
+	down := &fleetServer{err: errors.New("connection refused")}
+	f, err := NewFleet(2,
+		Member{Name: "eu-1", Labels: map[string]string{"region": "eu"}, Nessus: eu},
+		Member{Name: "us-1", Labels: map[string]string{"region": "us"}, Nessus: us},
+		Member{Name: "us-2", Labels: map[string]string{"region": "us"}, Nessus: down},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestNewFleet(t *testing.T) {
+	s := &fleetServer{}
+	if _, err := NewFleet(0, Member{Name: "a", Nessus: s}, Member{Name: "a", Nessus: s}); err == nil {
+		t.Error("duplicate members should be refused")
+	}
+	if _, err := NewFleet(0, Member{Nessus: s}); err == nil {
+		t.Error("members without name should be refused")
+	}
+	if _, err := NewFleet(0, Member{Name: "a"}); err == nil {
+		t.Error("members without client should be refused")
+	}
+}
+
+func TestFleetErrorIsolation(t *testing.T) {
+	f := testFleet(t)
+	ctx := context.Background()
+
+	scans, err := f.Scans(ctx)
+	var fe *FleetError
+	if !errors.As(err, &fe) || len(fe.Errors) != 1 || !fe.Failed("us-2") || fe.Failed("eu-1") {
+		t.Fatalf("us-2 should fail alone, got %v", err)
+	}
+	if len(scans) != 4 || scans[0].Server != "eu-1" || scans[3].Server != "us-1" || scans[3].Name != "daily" {
+		t.Errorf("wrong scans %+v", scans)
+	}
+
+	statuses, err := f.ServerStatus(ctx)
+	if err == nil || len(statuses) != 2 || statuses["us-1"].Status != ServerStatusReady || statuses["us-2"] != nil {
+		t.Errorf("wrong statuses %+v, err=%v", statuses, err)
+	}
+	scanners, err := f.Scanners(ctx)
+	if err == nil || len(scanners) != 2 || scanners[1].Server != "us-1" {
+		t.Errorf("wrong scanners %+v, err=%v", scanners, err)
+	}
+
+	// Selecting the healthy servers gives no error.
+	eu := f.Select(map[string]string{"region": "eu"})
+	if len(eu.Members()) != 1 {
+		t.Fatalf("wrong selection %+v", eu.Members())
+	}
+	if scans, err := eu.Scans(ctx); err != nil || len(scans) != 3 {
+		t.Errorf("wrong scans of eu %+v, err=%v", scans, err)
+	}
+}
+
+func TestFleetVulnerabilitySummary(t *testing.T) {
+	summary, err := testFleet(t).VulnerabilitySummary(context.Background())
+	if err == nil {
+		t.Error("us-2 should fail")
+	}
+	want := map[string]SeverityCounts{
+		"eu-1": {Critical: 1, High: 2},
+		"us-1": {Critical: 2, Low: 1},
+	}
+	if !reflect.DeepEqual(summary.Servers, want) {
+		t.Errorf("wrong counts by server %+v", summary.Servers)
+	}
+	if summary.Total != (SeverityCounts{Critical: 3, High: 2, Low: 1}) {
+		t.Errorf("wrong total %+v", summary.Total)
+	}
+	wantVulns := []FleetVulnerability{
+		{PluginID: 10, PluginName: "OpenSSL", Severity: SeverityCritical, Count: 3, Servers: []string{"eu-1", "us-1"}},
+		{PluginID: 20, PluginName: "TLS", Severity: SeverityHigh, Count: 2, Servers: []string{"eu-1"}},
+		{PluginID: 30, PluginName: "Banner", Severity: SeverityLow, Count: 1, Servers: []string{"us-1"}},
+	}
+	if !reflect.DeepEqual(summary.Vulnerabilities, wantVulns) {
+		t.Errorf("wrong vulnerabilities %+v", summary.Vulnerabilities)
+	}
+}
+
+func TestFleetConcurrency(t *testing.T) {
+	var inflight, peak int32
+	var members []Member
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		members = append(members, Member{Name: name, Nessus: &fleetServer{inflight: &inflight, peak: &peak}})
+	}
+	f, err := NewFleet(2, members...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Scans(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if peak != 2 {
+		t.Errorf("%d servers were queried at once, want 2", peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.Scans(ctx)
+	var fe *FleetError
+	if !errors.As(err, &fe) || len(fe.Errors) != 5 || !errors.Is(fe.Errors[0], context.Canceled) {
+		t.Errorf("canceled queries should fail every server, got %v", err)
+	}
+}
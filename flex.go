@@ -0,0 +1,236 @@
+package nessie
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lenient types for the fields that Nessus encodes differently depending on
+// its version or on the state of the resource.
+
+var jsonNull = []byte("null")
+
+// FlexInt64 is an integer that Nessus sends either as a number or as a
+// string. Empty strings and null decode to 0.
+type FlexInt64 int64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *FlexInt64) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, jsonNull) {
+		*i = 0
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			*i = 0
+			return nil
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		// Some versions send integers as floats, e.g. 3.0.
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil || f != float64(int64(f)) {
+			return fmt.Errorf("cannot parse %s as an integer", data)
+		}
+		n = int64(f)
+	}
+	*i = FlexInt64(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, as a number.
+func (i FlexInt64) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(i), 10), nil
+}
+
+// flexTimeLayouts are the layouts of the dates Nessus sends as strings.
+var flexTimeLayouts = []string{
+	// host_start and host_end, e.g. "Tue Jan 12 10:00:00 2021".
+	time.ANSIC,
+	// Scan schedules, e.g. "20210112T100000".
+	"20060102T150405",
+	time.RFC3339,
+}
+
+// FlexTime is a time that Nessus sends as epoch seconds, either as a number
+// or as a string, or as a formatted date. Empty strings, 0 and null decode to
+// the zero time. Dates without zone are in UTC.
+type FlexTime struct {
+	time.Time
+}
+
+// ParseFlexTime parses epoch seconds or a date in one of the formats used by
+// Nessus. An empty string is the zero time.
+func ParseFlexTime(s string) (FlexTime, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return FlexTime{}, nil
+	}
+	if epoch, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return flexTimeFromEpoch(epoch), nil
+	}
+	for _, layout := range flexTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return FlexTime{t}, nil
+		}
+	}
+	return FlexTime{}, fmt.Errorf("cannot parse %q as a time", s)
+}
+
+func flexTimeFromEpoch(epoch int64) FlexTime {
+	if epoch == 0 {
+		return FlexTime{}
+	}
+	return FlexTime{time.Unix(epoch, 0).UTC()}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *FlexTime) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, jsonNull) {
+		*t = FlexTime{}
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := ParseFlexTime(s)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+	var epoch FlexInt64
+	if err := epoch.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("cannot parse %s as a time", data)
+	}
+	*t = flexTimeFromEpoch(int64(epoch))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, as epoch seconds. The zero time is 0.
+func (t FlexTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("0"), nil
+	}
+	return strconv.AppendInt(nil, t.Unix(), 10), nil
+}
+
+// ScanStartTime is the starttime of a scan schedule, a wall clock time in
+// StartTimeLayout (e.g. "20210112T100000") in the TimeZone of the scan. Nessus
+// sends null or an empty string for scans without a schedule, which decode to
+// "", and some versions send epoch seconds, which decode to their UTC wall
+// clock. It encodes as a string in StartTimeLayout.
+type ScanStartTime string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *ScanStartTime) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, jsonNull) {
+		*t = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*t = ScanStartTime(strings.TrimSpace(s))
+		return nil
+	}
+	var epoch FlexInt64
+	if err := epoch.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("cannot parse %s as a start time", data)
+	}
+	*t = ""
+	if epoch != 0 {
+		*t = ScanStartTime(time.Unix(int64(epoch), 0).UTC().Format(StartTimeLayout))
+	}
+	return nil
+}
+
+// IsZero reports whether the scan has no start time.
+func (t ScanStartTime) IsZero() bool {
+	return t == ""
+}
+
+// In returns the start time in timeZone, the TimeZone of the scan, UTC when
+// empty. An empty start time is the zero time.
+func (t ScanStartTime) In(timeZone string) (time.Time, error) {
+	if t.IsZero() {
+		return time.Time{}, nil
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time zone: %v", err)
+	}
+	start, err := time.ParseInLocation(StartTimeLayout, string(t), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q as a start time", string(t))
+	}
+	return start, nil
+}
+
+// FlexList is a list that Nessus sends either as an array, as a single
+// element, as an object wrapping the array in its only field (e.g.
+// {"note": [...]}), or as an empty string or null when empty.
+type FlexList[T any] []T
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *FlexList[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, jsonNull) || bytes.Equal(data, []byte(`""`)) {
+		*l = nil
+		return nil
+	}
+	if len(data) > 0 && data[0] == '[' {
+		var list []T
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+	if len(data) > 0 && data[0] == '{' {
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(data, &wrapper); err == nil && len(wrapper) == 1 {
+			for _, inner := range wrapper {
+				if inner = bytes.TrimSpace(inner); len(inner) > 0 && inner[0] == '[' {
+					var list []T
+					if err := json.Unmarshal(inner, &list); err == nil {
+						*l = list
+						return nil
+					}
+				}
+			}
+		}
+	}
+	var single T
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*l = FlexList[T]{single}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, as an array. An empty list is [].
+func (l FlexList[T]) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]T(l))
+}
@@ -0,0 +1,244 @@
+package nessie
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestFlexInt64(t *testing.T) {
+	var tests = []struct {
+		in      string
+		want    FlexInt64
+		wantErr bool
+	}{
+		{`3`, 3, false},
+		{`"3"`, 3, false},
+		{`" 42 "`, 42, false},
+		{`""`, 0, false},
+		{`null`, 0, false},
+		{`3.0`, 3, false},
+		{`3.5`, 0, true},
+		{`"three"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var got FlexInt64
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("unmarshal %s: got=%d err=%v, want=%d wantErr=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFlexTime(t *testing.T) {
+	want := time.Date(2021, 1, 12, 10, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{`1610445600`, want, false},
+		{`"1610445600"`, want, false},
+		{`"Tue Jan 12 10:00:00 2021"`, want, false},
+		{`"20210112T100000"`, want, false},
+		{`"2021-01-12T10:00:00Z"`, want, false},
+		{`""`, time.Time{}, false},
+		{`0`, time.Time{}, false},
+		{`null`, time.Time{}, false},
+		{`"yesterday"`, time.Time{}, true},
+		{`{}`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		var got FlexTime
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("unmarshal %s: got=%v err=%v, want=%v wantErr=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if b, _ := json.Marshal(FlexTime{want}); string(b) != "1610445600" {
+		t.Errorf("wrong marshaled time %s", b)
+	}
+}
+
+func TestScanStartTime(t *testing.T) {
+	var tests = []struct {
+		in      string
+		want    ScanStartTime
+		wantErr bool
+	}{
+		{`"20210112T100000"`, "20210112T100000", false},
+		{`1610445600`, "20210112T100000", false},
+		{`""`, "", false},
+		{`0`, "", false},
+		{`null`, "", false},
+		{`{}`, "", true},
+	}
+	for _, tt := range tests {
+		var got ScanStartTime
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("unmarshal %s: got=%q err=%v, want=%q wantErr=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if b, _ := json.Marshal(ScanStartTime("20210112T100000")); string(b) != `"20210112T100000"` {
+		t.Errorf("wrong marshaled start time %s", b)
+	}
+
+	// The wall clock is in the time zone of the scan.
+	start, err := ScanStartTime("20210112T100000").In("Europe/Paris")
+	if err != nil || !start.Equal(time.Date(2021, 1, 12, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong start time %v, err=%v", start, err)
+	}
+	if start, err := ScanStartTime("").In("Europe/Paris"); err != nil || !start.IsZero() {
+		t.Errorf("empty start time should be zero, got=%v err=%v", start, err)
+	}
+	if _, err := ScanStartTime("tomorrow").In(""); err == nil {
+		t.Error("got no error, expected an invalid start time")
+	}
+}
+
+func TestFlexList(t *testing.T) {
+	var tests = []struct {
+		in      string
+		want    FlexList[Note]
+		wantErr bool
+	}{
+		{`[{"title":"a"},{"title":"b"}]`, FlexList[Note]{{Title: "a"}, {Title: "b"}}, false},
+		{`{"title":"a"}`, FlexList[Note]{{Title: "a"}}, false},
+		{`{"note":[{"title":"a"},{"title":"b"}]}`, FlexList[Note]{{Title: "a"}, {Title: "b"}}, false},
+		{`null`, nil, false},
+		{`""`, nil, false},
+		{`[]`, FlexList[Note]{}, false},
+		{`"a"`, nil, true},
+	}
+	for _, tt := range tests {
+		var got FlexList[Note]
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unmarshal %s: got=%+v err=%v, want=%+v wantErr=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	var os FlexList[string]
+	if err := json.Unmarshal([]byte(`"Linux Kernel 5.4"`), &os); err != nil || len(os) != 1 || os[0] != "Linux Kernel 5.4" {
+		t.Errorf("single string should be a list of one, got=%q err=%v", os, err)
+	}
+}
+
+// TestVersionsGolden decodes responses of several Nessus versions and compares
+// them, encoded again, with their golden files.
+func TestVersionsGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/versions/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no payloads: %v", err)
+	}
+	for _, file := range files {
+		var v interface{}
+		switch name := filepath.Base(file); {
+		case strings.HasPrefix(name, "scan_details_"):
+			v = &ScanDetailsResp{}
+		case strings.HasPrefix(name, "host_details_"):
+			v = &HostDetailsResp{}
+		case strings.HasPrefix(name, "scans_"):
+			v = &ListScansResponse{}
+		default:
+			t.Fatalf("unknown payload %s", name)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Errorf("cannot decode %s: %v", file, err)
+			continue
+		}
+		got, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden := strings.TrimSuffix(file, ".json") + ".golden"
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from %s:\n%s", file, golden, got)
+		}
+	}
+}
+
+func FuzzFlexInt64(f *testing.F) {
+	for _, seed := range []string{`3`, `"3"`, `""`, `null`, `3.0`, `"-9223372036854775808"`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var i FlexInt64
+		if err := json.Unmarshal(data, &i); err != nil {
+			return
+		}
+		b, err := json.Marshal(i)
+		if err != nil {
+			t.Fatalf("cannot marshal %d: %v", i, err)
+		}
+		var again FlexInt64
+		if err := json.Unmarshal(b, &again); err != nil || again != i {
+			t.Errorf("round trip of %s: got=%d err=%v, want=%d", data, again, err, i)
+		}
+	})
+}
+
+func FuzzFlexTime(f *testing.F) {
+	for _, seed := range []string{`1610445600`, `"1610445600"`, `"Tue Jan 12 10:00:00 2021"`, `"20210112T100000"`, `""`, `null`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var ft FlexTime
+		if err := json.Unmarshal(data, &ft); err != nil {
+			return
+		}
+		b, err := json.Marshal(ft)
+		if err != nil {
+			t.Fatalf("cannot marshal %v: %v", ft, err)
+		}
+		var again FlexTime
+		// Times are kept to the second.
+		if err := json.Unmarshal(b, &again); err != nil || again.Unix() != ft.Unix() {
+			t.Errorf("round trip of %s: got=%v err=%v, want=%v", data, again, err, ft)
+		}
+	})
+}
+
+func FuzzFlexList(f *testing.F) {
+	for _, seed := range []string{`[{"title":"a"}]`, `{"title":"a"}`, `{"note":[{"title":"a"}]}`, `""`, `null`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var l FlexList[Note]
+		if err := json.Unmarshal(data, &l); err != nil {
+			return
+		}
+		b, err := json.Marshal(l)
+		if err != nil {
+			t.Fatalf("cannot marshal %+v: %v", l, err)
+		}
+		var again FlexList[Note]
+		if err := json.Unmarshal(b, &again); err != nil || len(again) != len(l) {
+			t.Errorf("round trip of %s: got=%+v err=%v, want=%+v", data, again, err, l)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+// Package gate decides whether a completed scan passes a severity policy, e.g.
+// to fail a CI pipeline when a scan of a staging environment finds criticals,
+// and reports the verdict as JUnit XML.
+package gate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+// Policy is the set of rules a scan must comply with.
+type Policy struct {
+	// MaxCritical is the number of critical findings tolerated over the whole scan.
+	MaxCritical int64 `json:"max_critical"`
+	// MaxHigh is the number of high findings tolerated over the whole scan.
+	MaxHigh int64 `json:"max_high"`
+	// Allowlist lists the plugins whose findings are ignored.
+	Allowlist []AllowedPlugin `json:"allowlist"`
+}
+
+// AllowedPlugin ignores the findings of a plugin until it expires.
+type AllowedPlugin struct {
+	PluginID int64 `json:"plugin_id"`
+	// Expires is when the plugin findings count again, zero never expires.
+	Expires time.Time `json:"expires"`
+	Reason  string    `json:"reason"`
+}
+
+// Finding is a critical or high plugin finding on a host.
+type Finding struct {
+	PluginID   int64
+	PluginName string
+	Severity   nessie.Severity
+	// Reason is why an allowed finding is ignored.
+	Reason string
+}
+
+// HostResult is the outcome of the policy on a single host.
+type HostResult struct {
+	Hostname string
+	Critical int64
+	High     int64
+	// Failures are the findings counting against the policy.
+	Failures []Finding
+	// Allowed are the findings ignored thanks to the allowlist.
+	Allowed []Finding
+}
+
+// Verdict is the outcome of the policy on a scan.
+type Verdict struct {
+	Pass bool
+	// Reasons explain why the scan fails the policy.
+	Reasons []string
+	// Warnings do not fail the policy, e.g. expired allowlist entries.
+	Warnings []string
+	Critical int64
+	High     int64
+	Hosts    []HostResult
+}
+
+// Evaluate fetches the details of a completed scan and evaluates it against the policy.
+// Host details are only fetched for the hosts with critical or high findings.
+func Evaluate(n nessie.ScanService, scanID int64, policy Policy) (*Verdict, error) {
+	details, err := n.ScanDetails(scanID)
+	if err != nil {
+		return nil, err
+	}
+	if status := strings.ToLower(details.Info.Status); status != "completed" {
+		return nil, fmt.Errorf("scan %d is %s, not completed", scanID, status)
+	}
+	hosts := map[int64]*nessie.HostDetailsResp{}
+	for _, host := range details.Hosts {
+		if host.Critical == 0 && host.High == 0 {
+			continue
+		}
+		hostDetails, err := n.HostDetails(scanID, host.HostID)
+		if err != nil {
+			return nil, err
+		}
+		hosts[host.HostID] = hostDetails
+	}
+	return EvaluateResults(details, hosts, policy, time.Now()), nil
+}
+
+// EvaluateResults evaluates scan results against the policy at the given time.
+//
+// hosts are the HostDetails keyed by host ID. The allowlist can only be applied
+// to the hosts with details, the others are judged on their severity counts.
+func EvaluateResults(details *nessie.ScanDetailsResp, hosts map[int64]*nessie.HostDetailsResp, policy Policy, now time.Time) *Verdict {
+	allowed := map[int64]AllowedPlugin{}
+	expired := map[int64]AllowedPlugin{}
+	for _, a := range policy.Allowlist {
+		if a.Expires.IsZero() || now.Before(a.Expires) {
+			allowed[a.PluginID] = a
+		} else {
+			expired[a.PluginID] = a
+		}
+	}
+
+	v := &Verdict{}
+	seenExpired := map[int64]bool{}
+	for _, host := range details.Hosts {
+		res := HostResult{Hostname: host.Hostname}
+		hostDetails, ok := hosts[host.HostID]
+		if !ok {
+			res.Critical, res.High = host.Critical, host.High
+		} else {
+			for _, vuln := range hostDetails.Vulnerabilities {
+				if !vuln.Severity.AtLeast(nessie.SeverityHigh) {
+					continue
+				}
+				f := Finding{PluginID: vuln.PluginID, PluginName: vuln.PluginName, Severity: vuln.Severity}
+				if a, ok := allowed[vuln.PluginID]; ok {
+					f.Reason = a.Reason
+					res.Allowed = append(res.Allowed, f)
+					continue
+				}
+				if a, ok := expired[vuln.PluginID]; ok && !seenExpired[a.PluginID] {
+					seenExpired[a.PluginID] = true
+					v.Warnings = append(v.Warnings, fmt.Sprintf("allowlist entry for plugin %d expired on %s", a.PluginID, a.Expires.Format("2006-01-02")))
+				}
+				res.Failures = append(res.Failures, f)
+				if vuln.Severity.AtLeast(nessie.SeverityCritical) {
+					res.Critical++
+				} else {
+					res.High++
+				}
+			}
+		}
+		v.Critical += res.Critical
+		v.High += res.High
+		v.Hosts = append(v.Hosts, res)
+	}
+
+	if v.Critical > policy.MaxCritical {
+		v.Reasons = append(v.Reasons, fmt.Sprintf("%d critical findings, at most %d allowed", v.Critical, policy.MaxCritical))
+	}
+	if v.High > policy.MaxHigh {
+		v.Reasons = append(v.Reasons, fmt.Sprintf("%d high findings, at most %d allowed", v.High, policy.MaxHigh))
+	}
+	v.Pass = v.Critical <= policy.MaxCritical && v.High <= policy.MaxHigh
+	return v
+}
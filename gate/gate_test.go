@@ -0,0 +1,127 @@
+package gate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+var now = time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC)
+
+func scanResults() (*nessie.ScanDetailsResp, map[int64]*nessie.HostDetailsResp) {
+	details := &nessie.ScanDetailsResp{Hosts: []nessie.Host{
+		{HostID: 1, Hostname: "web", Critical: 1, High: 1},
+		{HostID: 2, Hostname: "db", High: 2},
+		{HostID: 3, Hostname: "cache"},
+	}}
+	details.Info.Status = "completed"
+	hosts := map[int64]*nessie.HostDetailsResp{1: {Vulnerabilities: []nessie.HostVulnerability{
+		{PluginID: 142960, PluginName: "Apache < 2.4.46", Severity: 4},
+		{PluginID: 51192, PluginName: "SSL Certificate Cannot Be Trusted", Severity: 3},
+		{PluginID: 70658, PluginName: "SSH CBC", Severity: 1},
+	}}}
+	return details, hosts
+}
+
+func TestEvaluateResults(t *testing.T) {
+	details, hosts := scanResults()
+	var tests = []struct {
+		policy       Policy
+		wantPass     bool
+		wantCritical int64
+		wantHigh     int64
+		wantReasons  int
+		wantWarnings int
+	}{
+		// The db host has no details and is judged on its counts.
+		{Policy{}, false, 1, 3, 2, 0},
+		{Policy{MaxCritical: 1, MaxHigh: 3}, true, 1, 3, 0, 0},
+		{Policy{MaxHigh: 3, Allowlist: []AllowedPlugin{{PluginID: 142960, Reason: "patched by WAF"}}}, true, 0, 3, 0, 0},
+		{Policy{MaxHigh: 2, Allowlist: []AllowedPlugin{{PluginID: 51192, Expires: now.Add(time.Hour)}}}, false, 1, 2, 1, 0},
+		// Expired allowlist entries count again, and are reported as warnings.
+		{Policy{MaxCritical: 0, MaxHigh: 3, Allowlist: []AllowedPlugin{{PluginID: 142960, Expires: now.Add(-time.Hour)}}}, false, 1, 3, 1, 1},
+		{Policy{MaxCritical: 1, MaxHigh: 3, Allowlist: []AllowedPlugin{{PluginID: 142960, Expires: now.Add(-time.Hour)}}}, true, 1, 3, 0, 1},
+	}
+	for _, tt := range tests {
+		v := EvaluateResults(details, hosts, tt.policy, now)
+		if v.Pass != tt.wantPass || v.Critical != tt.wantCritical || v.High != tt.wantHigh || len(v.Reasons) != tt.wantReasons || len(v.Warnings) != tt.wantWarnings {
+			t.Errorf("wrong verdict for %+v, got pass=%v critical=%d high=%d reasons=%v warnings=%v", tt.policy, v.Pass, v.Critical, v.High, v.Reasons, v.Warnings)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	details, hosts := scanResults()
+	policy := Policy{Allowlist: []AllowedPlugin{{PluginID: 51192, Reason: "internal CA"}}}
+	var buf bytes.Buffer
+	if err := EvaluateResults(details, hosts, policy, now).WriteJUnit(&buf); err != nil {
+		t.Fatalf("cannot write junit: %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid junit xml: %v\n%s", err, buf.String())
+	}
+	if len(suites.Suites) != 3 || suites.Failures != 2 || suites.Skipped != 1 || suites.Tests != 4 {
+		t.Fatalf("wrong test suites, got=%+v", suites)
+	}
+	web := suites.Suites[0]
+	if web.Name != "web" || web.Failures != 1 || web.Skipped != 1 || web.TestCases[0].Name != "plugin 142960: Apache < 2.4.46" || web.TestCases[0].Failure.Type != "critical" {
+		t.Errorf("wrong web suite, got=%+v", web)
+	}
+	if db := suites.Suites[1]; db.Failures != 1 || !strings.Contains(db.TestCases[0].Failure.Message, "2 high") {
+		t.Errorf("wrong db suite, got=%+v", db)
+	}
+	if cache := suites.Suites[2]; cache.Failures != 0 || len(cache.TestCases) != 1 || cache.TestCases[0].Failure != nil {
+		t.Errorf("wrong cache suite, got=%+v", cache)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	details, hosts := scanResults()
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch r.URL.Path {
+		case "/scans/42":
+			resp = details
+		case "/scans/42/hosts/1":
+			resp = hosts[1]
+		case "/scans/42/hosts/2":
+			resp = &nessie.HostDetailsResp{}
+		default:
+			return
+		}
+		fetched = append(fetched, r.URL.Path)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+
+	v, err := Evaluate(n, 42, Policy{MaxHigh: 1})
+	if err != nil {
+		t.Fatalf("cannot evaluate scan: %v", err)
+	}
+	// The host without critical nor high findings is not fetched.
+	if len(fetched) != 3 {
+		t.Errorf("wrong requests, got=%v", fetched)
+	}
+	if v.Pass || v.Critical != 1 || v.High != 1 {
+		t.Errorf("wrong verdict, got=%+v", v)
+	}
+
+	details.Info.Status = "running"
+	if _, err := Evaluate(n, 42, Policy{}); err == nil {
+		t.Error("got no error for a running scan")
+	}
+}
@@ -0,0 +1,105 @@
+package gate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the verdict as JUnit XML: each host is a test suite and
+// each failing plugin a failing test case. Allowed plugins are skipped test cases.
+func (v *Verdict) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: "nessus"}
+	for _, host := range v.Hosts {
+		suite := junitTestSuite{Name: host.Hostname}
+		for _, f := range host.Failures {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				ClassName: host.Hostname,
+				Name:      testCaseName(f),
+				Failure: &junitFailure{
+					Message: fmt.Sprintf("%s finding", f.Severity),
+					Type:    f.Severity.String(),
+					Text:    fmt.Sprintf("plugin %d (%s) found a %s vulnerability on %s", f.PluginID, f.PluginName, f.Severity, host.Hostname),
+				},
+			})
+			suite.Failures++
+		}
+		// Hosts judged on their counts only have no failing plugin to report.
+		if len(host.Failures) == 0 && host.Critical+host.High > 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				ClassName: host.Hostname,
+				Name:      "severity counts",
+				Failure: &junitFailure{
+					Message: fmt.Sprintf("%d critical and %d high findings", host.Critical, host.High),
+					Type:    "severity",
+				},
+			})
+			suite.Failures++
+		}
+		for _, f := range host.Allowed {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				ClassName: host.Hostname,
+				Name:      testCaseName(f),
+				Skipped:   &junitSkipped{Message: "allowlisted: " + f.Reason},
+			})
+			suite.Skipped++
+		}
+		if len(suite.TestCases) == 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{ClassName: host.Hostname, Name: "no critical or high findings"})
+		}
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func testCaseName(f Finding) string {
+	return fmt.Sprintf("plugin %d: %s", f.PluginID, f.PluginName)
+}
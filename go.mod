@@ -1,5 +1,7 @@
 module github.com/JerusJ/nessie
 
-go 1.17
+go 1.18
 
 require github.com/gorilla/schema v1.2.0
+
+require gopkg.in/yaml.v3 v3.0.1
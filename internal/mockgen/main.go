@@ -0,0 +1,252 @@
+// Command mockgen generates the mocks of the service interfaces of the nessie
+// package into nessietest. Each mock has a function field per method, e.g.
+// StartScanFunc for StartScan, called by the method; a method whose function
+// is not set panics so that unexpected calls fail the test.
+//
+// Run it with go generate from the root of the repository.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+)
+
+var (
+	source = flag.String("source", "nessie.go", "Go file declaring the interfaces to mock.")
+	out    = flag.String("out", "nessietest/mocks.go", "Go file to write the mocks to.")
+)
+
+func main() {
+	flag.Parse()
+	src, err := ioutil.ReadFile(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	code, err := generate(*source, src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type method struct {
+	name    string
+	params  []string // "name type"
+	args    []string // names, to forward the call
+	results []string // types
+}
+
+type iface struct {
+	name     string
+	embedded []string
+	methods  []method
+}
+
+// generate returns the source of the mocks of the interfaces declared in src:
+// the interfaces named *Service, and the interfaces only embedding them.
+func generate(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{pkg: f.Name.Name, imports: make(map[string]bool)}
+	var ifaces []*iface
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			i, err := g.iface(ts.Name.Name, it)
+			if err != nil {
+				return nil, err
+			}
+			if i != nil {
+				ifaces = append(ifaces, i)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by internal/mockgen from %s; DO NOT EDIT.\n\n", filename)
+	fmt.Fprintf(&b, "package nessietest\n\nimport (\n")
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "%q\n", imp)
+	}
+	fmt.Fprintf(&b, "\n\"github.com/JerusJ/nessie\"\n)\n")
+	for _, i := range ifaces {
+		g.write(&b, i)
+	}
+	return format.Source(b.Bytes())
+}
+
+type generator struct {
+	pkg     string
+	imports map[string]bool
+}
+
+func (g *generator) iface(name string, it *ast.InterfaceType) (*iface, error) {
+	i := &iface{name: name}
+	for _, field := range it.Methods.List {
+		switch t := field.Type.(type) {
+		case *ast.Ident:
+			i.embedded = append(i.embedded, t.Name)
+		case *ast.FuncType:
+			i.methods = append(i.methods, g.method(field.Names[0].Name, t))
+		default:
+			return nil, fmt.Errorf("unsupported interface element in %s", name)
+		}
+	}
+	if strings.HasSuffix(name, "Service") || (len(i.embedded) > 0 && len(i.methods) == 0) {
+		return i, nil
+	}
+	return nil, nil
+}
+
+func (g *generator) method(name string, ft *ast.FuncType) method {
+	m := method{name: name}
+	for _, field := range ft.Params.List {
+		typ := g.typeString(field.Type)
+		if len(field.Names) == 0 {
+			arg := fmt.Sprintf("p%d", len(m.args))
+			m.args = append(m.args, arg)
+			m.params = append(m.params, arg+" "+typ)
+		}
+		for _, n := range field.Names {
+			m.args = append(m.args, n.Name)
+			m.params = append(m.params, n.Name+" "+typ)
+		}
+	}
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			typ := g.typeString(field.Type)
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for j := 0; j < count; j++ {
+				m.results = append(m.results, typ)
+			}
+		}
+	}
+	return m
+}
+
+// typeString prints a type of the source package as seen from nessietest.
+func (g *generator) typeString(expr ast.Expr) string {
+	expr = g.qualify(expr)
+	var b bytes.Buffer
+	// Positions of the source would break the qualified identifiers over lines.
+	printer.Fprint(&b, token.NewFileSet(), expr)
+	return b.String()
+}
+
+// qualify returns a copy of expr with the exported identifiers of the source
+// package prefixed by its name, and records the imports it uses.
+func (g *generator) qualify(expr ast.Expr) ast.Expr {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if ast.IsExported(t.Name) {
+			return &ast.SelectorExpr{X: ast.NewIdent(g.pkg), Sel: ast.NewIdent(t.Name)}
+		}
+		return t
+	case *ast.SelectorExpr:
+		pkg := t.X.(*ast.Ident).Name
+		switch pkg {
+		case "http":
+			g.imports["net/http"] = true
+		default:
+			g.imports[pkg] = true
+		}
+		return t
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: g.qualify(t.X)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: t.Len, Elt: g.qualify(t.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: g.qualify(t.Key), Value: g.qualify(t.Value)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: t.Dir, Value: g.qualify(t.Value)}
+	case *ast.FuncType:
+		ft := &ast.FuncType{Params: g.qualifyFields(t.Params), Results: g.qualifyFields(t.Results)}
+		return ft
+	default:
+		return expr
+	}
+}
+
+func (g *generator) qualifyFields(fields *ast.FieldList) *ast.FieldList {
+	if fields == nil {
+		return nil
+	}
+	qualified := &ast.FieldList{}
+	for _, f := range fields.List {
+		qualified.List = append(qualified.List, &ast.Field{Names: f.Names, Type: g.qualify(f.Type)})
+	}
+	return qualified
+}
+
+func (g *generator) write(b *bytes.Buffer, i *iface) {
+	mock := "Mock" + i.name
+	if len(i.methods) == 0 {
+		fmt.Fprintf(b, "\n// %s implements %s.%s with the mocks of the interfaces it embeds.\n", mock, g.pkg, i.name)
+		fmt.Fprintf(b, "type %s struct {\n", mock)
+		for _, e := range i.embedded {
+			fmt.Fprintf(b, "Mock%s\n", e)
+		}
+		fmt.Fprintf(b, "}\n\nvar _ %s.%s = &%s{}\n", g.pkg, i.name, mock)
+		return
+	}
+
+	fmt.Fprintf(b, "\n// %s implements %s.%s by calling its function fields.\n", mock, g.pkg, i.name)
+	fmt.Fprintf(b, "type %s struct {\n", mock)
+	for _, m := range i.methods {
+		fmt.Fprintf(b, "%sFunc func(%s)%s\n", m.name, strings.Join(m.params, ", "), resultList(m.results))
+	}
+	fmt.Fprintf(b, "}\n\nvar _ %s.%s = &%s{}\n", g.pkg, i.name, mock)
+	for _, m := range i.methods {
+		fmt.Fprintf(b, "\n// %s calls %sFunc.\n", m.name, m.name)
+		fmt.Fprintf(b, "func (m *%s) %s(%s)%s {\n", mock, m.name, strings.Join(m.params, ", "), resultList(m.results))
+		fmt.Fprintf(b, "if m.%sFunc == nil {\npanic(\"nessietest: unexpected call to %s.%s\")\n}\n", m.name, mock, m.name)
+		call := fmt.Sprintf("m.%sFunc(%s)", m.name, strings.Join(m.args, ", "))
+		if len(m.results) > 0 {
+			fmt.Fprintf(b, "return %s\n}\n", call)
+		} else {
+			fmt.Fprintf(b, "%s\n}\n", call)
+		}
+	}
+}
+
+func resultList(results []string) string {
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		return " " + results[0]
+	default:
+		return " (" + strings.Join(results, ", ") + ")"
+	}
+}
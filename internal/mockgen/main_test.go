@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// TestGenerated checks that the mocks were generated again after the
+// interfaces changed.
+func TestGenerated(t *testing.T) {
+	src, err := ioutil.ReadFile("../../nessie.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := generate("nessie.go", src)
+	if err != nil {
+		t.Fatalf("cannot generate mocks: %v", err)
+	}
+	got, err := ioutil.ReadFile("../../nessietest/mocks.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("nessietest/mocks.go is out of date, run go generate")
+	}
+}
@@ -0,0 +1,63 @@
+// Package transfer holds the helpers backup and migrate share to recreate
+// the folders and scans of a server on another one.
+package transfer
+
+import (
+	"fmt"
+
+	"github.com/JerusJ/nessie"
+)
+
+// MatchFolder returns the folder of target matching f, by type for the
+// default folders and by name for the custom ones.
+func MatchFolder(target []nessie.Folder, f nessie.Folder) (int64, bool) {
+	for _, t := range target {
+		if f.Custom == 0 && t.Custom == 0 && t.Type == f.Type {
+			return t.ID, true
+		}
+		if f.Custom != 0 && t.Custom != 0 && t.Name == f.Name {
+			return t.ID, true
+		}
+	}
+	return 0, false
+}
+
+// ScanSettings returns the settings recreating a scan, with the IDs of its
+// server and the targets of its details.
+func ScanSettings(sc *nessie.Scan, targets string) nessie.ScanSettingsRequest {
+	settings := nessie.ScanSettingsRequest{
+		Name:                      sc.Name,
+		Description:               sc.Description,
+		FolderID:                  sc.FolderID,
+		PolicyID:                  int64(sc.PolicyID),
+		ScannerID:                 int64(sc.ScannerID),
+		TextTargets:               targets,
+		Enabled:                   sc.Enabled != 0,
+		Launch:                    nessie.LaunchFromRRules(sc.RRules),
+		RRules:                    sc.RRules,
+		TimeZone:                  sc.TimeZone,
+		Emails:                    sc.Emails,
+		FilterType:                sc.FilterType,
+		Filters:                   sc.NotificationFilters,
+		AttachReport:              sc.AttachReport,
+		AttachedReportType:        sc.AttachedReportType,
+		AttachedReportMaximumSize: int64(sc.AttachedReportMaximumSize),
+		ScanTimeWindow:            int64(sc.ScanTimeWindow),
+	}
+	settings.StartTime = string(sc.StartTime)
+	return settings
+}
+
+// BasicTemplate returns the UUID of the basic network scan template of n.
+func BasicTemplate(n nessie.ScanService) (string, error) {
+	templates, err := n.ScanTemplates()
+	if err != nil {
+		return "", fmt.Errorf("cannot list scan templates: %v", err)
+	}
+	for _, t := range templates {
+		if t.Name == "basic" {
+			return t.UUID, nil
+		}
+	}
+	return "", fmt.Errorf("no basic scan template")
+}
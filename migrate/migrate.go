@@ -0,0 +1,367 @@
+// Package migrate copies the policies, scan configurations and scan history of
+// folders of a Nessus server to another one, e.g. to consolidate servers.
+//
+// Every run of the history is exported from the source as a .nessus file and
+// imported on the target, where nessus turns it into a scan of its own named
+// after the source scan. The progress is kept in a State, so that a migration
+// interrupted or with failures can be run again to resume it.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/internal/transfer"
+)
+
+// Source is the part of the client of the server migrated from.
+type Source interface {
+	nessie.PolicyService
+	nessie.ScanService
+	nessie.FolderService
+	nessie.ExportService
+}
+
+// Target is the part of the client of the server migrated to.
+type Target interface {
+	nessie.PolicyService
+	nessie.ScanService
+	nessie.FolderService
+}
+
+// Kinds of migrated objects.
+const (
+	KindFolder = "folder"
+	KindPolicy = "policy"
+	KindScan   = "scan"
+	KindRun    = "run"
+)
+
+// Failure is an object that could not be migrated.
+type Failure struct {
+	// Kind is one of KindFolder, KindPolicy, KindScan or KindRun.
+	Kind string
+	Name string
+	Err  error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s %q: %v", f.Kind, f.Name, f.Err)
+}
+
+// Report is the outcome of a migration.
+type Report struct {
+	// Migrated counts the objects migrated by this migration, by kind.
+	Migrated map[string]int
+	// Resumed counts the objects migrated by a previous migration, by kind.
+	Resumed map[string]int
+	// Failures are the objects that could not be migrated. Migrating again
+	// retries them.
+	Failures []Failure
+}
+
+func (r *Report) fail(kind, name string, err error) {
+	r.Failures = append(r.Failures, Failure{Kind: kind, Name: name, Err: err})
+}
+
+// Migrator migrates folders from a source to a target server.
+type Migrator struct {
+	// Folders are the names of the source folders to migrate, all but the
+	// trash when empty. My Scans is migrated to My Scans of the target.
+	Folders []string
+	// StateFile keeps the progress of the migration, saved after every
+	// migrated object. The progress is only kept by the Migrator when empty.
+	StateFile string
+	// PollInterval is the time between checks of the status of exports.
+	PollInterval time.Duration
+
+	src   Source
+	dst   Target
+	state *State
+}
+
+// NewMigrator returns a migrator from src to dst, both logged in.
+func NewMigrator(src Source, dst Target) *Migrator {
+	return &Migrator{src: src, dst: dst, PollInterval: 5 * time.Second}
+}
+
+// Migrate migrates the folders, then the policies used by their scans, the
+// scans and their history. Custom folders and policies already on the target
+// are matched by name and reused. Scans are created with their schedule
+// disabled, so that targets are not scanned by both servers; enable them once
+// the source is retired.
+//
+// An error is returned when a server cannot be listed, the state cannot be
+// saved or ctx is done, failures of single objects are in the report.
+func (m *Migrator) Migrate(ctx context.Context) (*Report, error) {
+	if m.state == nil {
+		m.state = NewState()
+		if m.StateFile != "" {
+			st, err := LoadState(m.StateFile)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load state: %v", err)
+			}
+			m.state = st
+		}
+	}
+	report := &Report{Migrated: map[string]int{}, Resumed: map[string]int{}}
+
+	folders, err := m.migrateFolders(report)
+	if err != nil {
+		return report, err
+	}
+	var scans []nessie.Scan
+	for _, f := range folders {
+		list, err := m.src.ScansInFolder(f.ID)
+		if err != nil {
+			return report, fmt.Errorf("cannot list scans of folder %q: %v", f.Name, err)
+		}
+		scans = append(scans, list.Scans...)
+	}
+	sort.Slice(scans, func(i, j int) bool { return scans[i].ID < scans[j].ID })
+
+	templates, err := m.migratePolicies(report, scans)
+	if err != nil {
+		return report, err
+	}
+	for _, sc := range scans {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := m.migrateScan(ctx, report, sc, templates); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (m *Migrator) save() error {
+	if m.StateFile == "" {
+		return nil
+	}
+	if err := m.state.Save(m.StateFile); err != nil {
+		return fmt.Errorf("cannot save state: %v", err)
+	}
+	return nil
+}
+
+// migrateFolders maps the selected folders to the target and returns them.
+func (m *Migrator) migrateFolders(report *Report) ([]nessie.Folder, error) {
+	src, err := m.src.Folders()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list source folders: %v", err)
+	}
+	var selected []nessie.Folder
+	if len(m.Folders) == 0 {
+		for _, f := range src {
+			if f.Type != "trash" {
+				selected = append(selected, f)
+			}
+		}
+	}
+	for _, name := range m.Folders {
+		found := false
+		for _, f := range src {
+			if f.Name == name {
+				selected, found = append(selected, f), true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown folder %q", name)
+		}
+	}
+
+	dst, err := m.dst.Folders()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list target folders: %v", err)
+	}
+	var migrated []nessie.Folder
+	for _, f := range selected {
+		if _, ok := m.state.Folders[f.ID]; ok {
+			report.Resumed[KindFolder]++
+			migrated = append(migrated, f)
+			continue
+		}
+		id, ok := transfer.MatchFolder(dst, f)
+		if !ok {
+			if id, err = nessie.CreateFolderID(m.dst, f.Name); err != nil {
+				report.fail(KindFolder, f.Name, err)
+				continue
+			}
+		}
+		m.state.Folders[f.ID] = id
+		report.Migrated[KindFolder]++
+		if err := m.save(); err != nil {
+			return nil, err
+		}
+		migrated = append(migrated, f)
+	}
+	return migrated, nil
+}
+
+// migratePolicies migrates the policies of the scans and returns the template
+// UUIDs of the source policies.
+func (m *Migrator) migratePolicies(report *Report, scans []nessie.Scan) (map[int64]string, error) {
+	used := map[int64]bool{}
+	for _, sc := range scans {
+		if sc.PolicyID != 0 {
+			used[int64(sc.PolicyID)] = true
+		}
+	}
+	templates := map[int64]string{}
+	if len(used) == 0 {
+		return templates, nil
+	}
+	src, err := m.src.Policies()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list source policies: %v", err)
+	}
+	dst, err := m.dst.Policies()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list target policies: %v", err)
+	}
+	byName := map[string]int64{}
+	for _, p := range dst {
+		byName[p.Name] = p.ID
+	}
+	for _, p := range src {
+		if !used[p.ID] {
+			continue
+		}
+		templates[p.ID] = p.TemplateUUID
+		if _, ok := m.state.Policies[p.ID]; ok {
+			report.Resumed[KindPolicy]++
+			continue
+		}
+		id, ok := byName[p.Name]
+		if !ok {
+			file, err := m.src.ExportPolicy(p.ID)
+			if err != nil {
+				report.fail(KindPolicy, p.Name, err)
+				continue
+			}
+			imported, err := m.dst.ImportPolicy(fmt.Sprintf("policy-%d.nessus", p.ID), file)
+			if err != nil {
+				report.fail(KindPolicy, p.Name, err)
+				continue
+			}
+			id = imported.ID
+		}
+		m.state.Policies[p.ID] = id
+		report.Migrated[KindPolicy]++
+		if err := m.save(); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// migrateScan creates the scan on the target and imports its history.
+func (m *Migrator) migrateScan(ctx context.Context, report *Report, sc nessie.Scan, templates map[int64]string) error {
+	details, err := m.src.ScanDetails(sc.ID)
+	if err != nil {
+		report.fail(KindScan, sc.Name, err)
+		return nil
+	}
+	folderID := m.state.Folders[sc.FolderID]
+
+	if _, ok := m.state.Scans[sc.ID]; ok {
+		report.Resumed[KindScan]++
+	} else if err := m.createScan(report, sc, details.Info.Targets, folderID, templates); err != nil {
+		report.fail(KindScan, sc.Name, err)
+	} else if err := m.save(); err != nil {
+		return err
+	}
+
+	// The history does not depend on the scan, which imports do not update.
+	history := details.History
+	sort.Slice(history, func(i, j int) bool { return history[i].HistoryID < history[j].HistoryID })
+	for _, h := range history {
+		name := fmt.Sprintf("%s/%d", sc.Name, h.HistoryID)
+		if _, ok := m.state.Runs[h.HistoryID]; ok {
+			report.Resumed[KindRun]++
+			continue
+		}
+		switch h.Status {
+		case "completed", "imported", "canceled", "aborted":
+		default:
+			report.fail(KindRun, name, fmt.Errorf("run is %s", h.Status))
+			continue
+		}
+		data, err := m.export(ctx, sc.ID, h.HistoryID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report.fail(KindRun, name, err)
+			continue
+		}
+		imported, err := m.dst.ImportScan(fmt.Sprintf("scan-%d-%d.nessus", sc.ID, h.HistoryID), data, folderID)
+		if err != nil {
+			report.fail(KindRun, name, err)
+			continue
+		}
+		m.state.Runs[h.HistoryID] = imported.ID
+		report.Migrated[KindRun]++
+		if err := m.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) createScan(report *Report, sc nessie.Scan, targets string, folderID int64, templates map[int64]string) error {
+	settings := transfer.ScanSettings(&sc, targets)
+	settings.FolderID = folderID
+	// Scanners are local to each server, the target uses its default one.
+	settings.ScannerID = 0
+	settings.Enabled = false
+	if settings.PolicyID != 0 {
+		id, ok := m.state.Policies[settings.PolicyID]
+		if !ok {
+			return fmt.Errorf("policy %d was not migrated", settings.PolicyID)
+		}
+		settings.PolicyID = id
+	}
+	template := templates[int64(sc.PolicyID)]
+	if template == "" {
+		var err error
+		if template, err = transfer.BasicTemplate(m.dst); err != nil {
+			return err
+		}
+	}
+	created, err := m.dst.CreateScan(nessie.NewScanRequest{UUID: template, Settings: settings})
+	if err != nil {
+		return err
+	}
+	m.state.Scans[sc.ID] = created.ID
+	report.Migrated[KindScan]++
+	return nil
+}
+
+// export exports a run of a scan as a .nessus file.
+func (m *Migrator) export(ctx context.Context, scanID, historyID int64) ([]byte, error) {
+	exportID, err := m.src.ExportScanWithOptions(scanID, nessie.ExportOptions{Format: nessie.ExportNessus, HistoryID: historyID})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ready, err := m.src.ExportFinished(scanID, exportID)
+		if err != nil {
+			return nil, err
+		}
+		if ready {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(m.PollInterval):
+		}
+	}
+	return m.src.DownloadExport(scanID, exportID)
+}
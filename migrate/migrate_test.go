@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/nessietest"
+)
+
+func folderID(t *testing.T, n nessie.Nessus, name string) int64 {
+	t.Helper()
+	folders, err := n.Folders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range folders {
+		if f.Name == name {
+			return f.ID
+		}
+	}
+	t.Fatalf("no folder %q", name)
+	return 0
+}
+
+// runScan creates a scan in the folder and completes the given number of runs.
+func runScan(t *testing.T, s *nessietest.Server, n nessie.Nessus, name string, folder, policy int64, runs int) {
+	t.Helper()
+	sc, err := n.CreateScan(nessie.NewScanRequest{
+		UUID: nessietest.BasicTemplateUUID,
+		Settings: nessie.ScanSettingsRequest{
+			Name: name, FolderID: folder, PolicyID: policy, TextTargets: "10.0.0.1,10.0.0.2",
+			Enabled: true, Launch: "DAILY", RRules: "FREQ=DAILY;INTERVAL=1", StartTime: "20210104T020000",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < runs; i++ {
+		if _, err := n.StartScan(sc.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CompleteScan(sc.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// populate creates a daily scan with a policy and two runs in prod, a scan
+// with one run in My Scans and a scan in an unmigrated folder.
+func populate(t *testing.T, s *nessietest.Server, n nessie.Nessus) {
+	t.Helper()
+	for _, name := range []string{"prod", "lab"} {
+		if err := n.CreateFolder(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policy, err := n.CreatePolicy(nessie.CreatePolicyRequest{
+		UUID:     nessietest.BasicTemplateUUID,
+		Settings: nessie.PolicySettings{Name: "fast"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runScan(t, s, n, "daily", folderID(t, n, "prod"), policy.PolicyID, 2)
+	runScan(t, s, n, "adhoc", nessietest.MyScansFolderID, 0, 1)
+	runScan(t, s, n, "lab", folderID(t, n, "lab"), 0, 1)
+}
+
+func TestMigrate(t *testing.T) {
+	src := nessietest.NewServer()
+	defer src.Close()
+	srcNessus := src.Client(t)
+	populate(t, src, srcNessus)
+
+	dst := nessietest.NewServer()
+	defer dst.Close()
+	dstNessus := dst.Client(t)
+	// Imports of the first run fail once.
+	dst.InjectError("POST", "/scans/import", http.StatusInternalServerError, 1)
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	m := NewMigrator(srcNessus, dstNessus)
+	m.Folders = []string{"prod", "My Scans"}
+	m.StateFile = stateFile
+	m.PollInterval = 0
+	report, err := m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("cannot migrate: %v", err)
+	}
+	if len(report.Failures) != 1 || report.Failures[0].Kind != KindRun || !strings.HasPrefix(report.Failures[0].Name, "daily/") {
+		t.Errorf("wrong failures %v", report.Failures)
+	}
+	want := map[string]int{KindFolder: 2, KindPolicy: 1, KindScan: 2, KindRun: 2}
+	for kind, count := range want {
+		if report.Migrated[kind] != count {
+			t.Errorf("migrated %d %s, want %d", report.Migrated[kind], kind, count)
+		}
+	}
+
+	// A new migrator resumes from the state file and retries the failed run.
+	m = NewMigrator(srcNessus, dstNessus)
+	m.Folders = []string{"prod", "My Scans"}
+	m.StateFile = stateFile
+	m.PollInterval = 0
+	report, err = m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("cannot resume: %v", err)
+	}
+	if len(report.Failures) != 0 || report.Migrated[KindRun] != 1 || report.Migrated[KindScan] != 0 || report.Resumed[KindScan] != 2 || report.Resumed[KindRun] != 2 {
+		t.Errorf("wrong resumed report %+v", report)
+	}
+
+	prod := folderID(t, dstNessus, "prod")
+	list, err := dstNessus.ScansInFolder(prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configs, imports int
+	for _, sc := range list.Scans {
+		if sc.Name != "daily" {
+			t.Errorf("unexpected scan %q in prod", sc.Name)
+		}
+		switch sc.Status {
+		case "imported":
+			imports++
+			details, err := dstNessus.ScanDetails(sc.ID)
+			if err != nil || len(details.Hosts) != 2 {
+				t.Errorf("wrong imported results %+v: %v", details, err)
+			}
+		default:
+			configs++
+			if sc.Enabled != 0 || sc.PolicyID == 0 || sc.RRules != "FREQ=DAILY;INTERVAL=1" {
+				t.Errorf("wrong migrated scan %+v", sc)
+			}
+		}
+	}
+	if configs != 1 || imports != 2 {
+		t.Errorf("prod has %d scans and %d imported runs, want 1 and 2", configs, imports)
+	}
+	if mine, err := dstNessus.ScansInFolder(nessietest.MyScansFolderID); err != nil || len(mine.Scans) != 2 {
+		t.Errorf("My Scans should have adhoc and its run, got %+v: %v", mine, err)
+	}
+	if folders, err := dstNessus.Folders(); err != nil || len(folders) != 3 {
+		t.Errorf("lab should not be migrated, got %+v: %v", folders, err)
+	}
+}
+
+func TestMigrateUnknownFolder(t *testing.T) {
+	s := nessietest.NewServer()
+	defer s.Close()
+	n := s.Client(t)
+	m := NewMigrator(n, n)
+	m.Folders = []string{"missing"}
+	if _, err := m.Migrate(context.Background()); err == nil || !strings.Contains(err.Error(), `unknown folder "missing"`) {
+		t.Errorf("unknown folder should fail the migration, got %v", err)
+	}
+}
+
+func TestMigrateCanceled(t *testing.T) {
+	src := nessietest.NewServer()
+	defer src.Close()
+	srcNessus := src.Client(t)
+	populate(t, src, srcNessus)
+	dst := nessietest.NewServer()
+	defer dst.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewMigrator(srcNessus, dst.Client(t)).Migrate(ctx); err != context.Canceled {
+		t.Errorf("canceled migration should fail with %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := LoadState(path)
+	if err != nil || st.Runs == nil {
+		t.Fatalf("missing state should be new, got %+v: %v", st, err)
+	}
+	st.Runs[12] = 34
+	if err := st.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if st, err = LoadState(path); err != nil || st.Runs[12] != 34 || st.Scans == nil {
+		t.Errorf("wrong loaded state %+v: %v", st, err)
+	}
+}
@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// State is the progress of a migration, mapping the IDs of the migrated
+// objects of the source to the IDs of the target. Since IDs are per server,
+// each source server needs its own state.
+type State struct {
+	Folders  map[int64]int64 `json:"folders"`
+	Policies map[int64]int64 `json:"policies"`
+	Scans    map[int64]int64 `json:"scans"`
+	// Runs maps the history IDs of the source scans to the scans importing
+	// them on the target.
+	Runs map[int64]int64 `json:"runs"`
+}
+
+// NewState returns the state of a migration that did not start.
+func NewState() *State {
+	s := &State{}
+	s.init()
+	return s
+}
+
+func (s *State) init() {
+	for _, m := range []*map[int64]int64{&s.Folders, &s.Policies, &s.Scans, &s.Runs} {
+		if *m == nil {
+			*m = map[int64]int64{}
+		}
+	}
+}
+
+// LoadState reads a state saved by Save. A missing file is a new state.
+func LoadState(path string) (*State, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &State{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	s.init()
+	return s, nil
+}
+
+// Save writes the state to a file, replaced atomically so that an interrupted
+// save keeps the previous state.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
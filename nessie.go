@@ -3,6 +3,7 @@ package nessie
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
@@ -22,65 +23,139 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
+//go:generate go run ./internal/mockgen -source nessie.go -out nessietest/mocks.go
+
 // Nessus exposes the resources offered via the Tenable Nessus RESTful API.
+// Components only needing a part of the API should depend on the service interfaces it embeds.
 type Nessus interface {
+	SessionService
+	AdminService
+	UserService
+	PluginService
+	PluginRuleService
+	PolicyService
+	ScanService
+	FolderService
+	ExportService
+}
+
+// SessionService authenticates to nessus and issues raw requests.
+type SessionService interface {
 	SetVerbose(bool)
 	AuthCookie() string
 	Request(method string, resource string, js interface{}, wantStatus []int) (resp *http.Response, err error)
 	Login(username, password string) error
 	Logout() error
 	Session() (Session, error)
+}
 
+// AdminService manages the server, its settings, scanners and plugin feed.
+type AdminService interface {
 	ServerProperties() (*ServerProperties, error)
 	ServerStatus() (*ServerStatus, error)
 
+	AdvancedSettings() ([]AdvancedSetting, error)
+	EditAdvancedSettings(settings []AdvancedSetting) error
+	ProxySettings() (*ProxySettings, error)
+	EditProxySettings(settings ProxySettings) error
+	MailSettings() (*MailSettings, error)
+	EditMailSettings(settings MailSettings) error
+	SendTestEmail(to string) error
+	LDAPSettings() (*LDAPSettings, error)
+	EditLDAPSettings(settings LDAPSettings) error
+
+	UpdatePlugins() error
+	UploadPluginArchive(filePath string) error
+	RestartServer() error
+	WaitUntilReady(ctx context.Context, progress func(*ServerStatus)) error
+
+	Scanners() ([]Scanner, error)
+	AgentGroups() ([]AgentGroup, error)
+	Upload(filePath string) error
+}
+
+// UserService manages users and their permissions.
+type UserService interface {
 	CreateUser(username, password, userType, permissions, name, email string) (*User, error)
 	ListUsers() ([]User, error)
 	DeleteUser(userID int) error
 	SetUserPassword(userID int, password string) error
 	EditUser(userID int, permissions, name, email string) (*User, error)
 
+	Permissions(objectType string, objectID int64) ([]Permission, error)
+	SetPermissions(objectType string, objectID int64, acls []Permission) error
+
+	ListGroups() ([]Group, error)
+	CreateGroup(name string) (Group, error)
+}
+
+// PluginService reads the plugins of the loaded feed.
+type PluginService interface {
 	PluginFamilies() ([]PluginFamily, error)
 	FamilyDetails(ID int64) (*FamilyDetails, error)
 	PluginDetails(ID int64) (*PluginDetails, error)
 	AllPlugins() (chan PluginDetails, error)
+}
 
-	Scanners() ([]Scanner, error)
+// PolicyService manages scan policies.
+type PolicyService interface {
 	Policies() ([]Policy, error)
+	PolicyTemplates() ([]Template, error)
 	CreatePolicy(policySettings CreatePolicyRequest) (CreatePolicyResp, error)
 	ConfigurePolicy(id int64, policySettings CreatePolicyRequest) error
 	DeletePolicy(id int64) error
+	ExportPolicy(id int64) ([]byte, error)
+	ImportPolicy(name string, data []byte) (*Policy, error)
+}
 
-	Upload(filePath string) error
-	AgentGroups() ([]AgentGroup, error)
+// PluginRuleService manages the rules changing the severity of plugins.
+type PluginRuleService interface {
+	PluginRules() ([]Rule, error)
+	CreatePluginRule(rule Rule) error
+}
 
+// ScanService manages scans, their runs and results.
+type ScanService interface {
 	NewScan(editorTmplUUID, settingsName string, outputFolderID, policyID, scannerID int64, launch string, targets []string) (*Scan, error)
 	CreateScan(newScanRequest NewScanRequest) (*Scan, error)
 	Scans() (*ListScansResponse, error)
+	ScansSince(ctx context.Context, since Timestamp) (*ListScansResponse, error)
+	ScansInFolder(folderID int64) (*ListScansResponse, error)
 	ScanTemplates() ([]Template, error)
-	PolicyTemplates() ([]Template, error)
 	StartScan(scanID int64) (string, error)
 	PauseScan(scanID int64) error
 	ResumeScan(scanID int64) error
 	StopScan(scanID int64) error
 	DeleteScan(scanID int64) error
 	ScanDetails(scanID int64) (*ScanDetailsResp, error)
+	ScanDetailsFiltered(scanID int64, filters *FilterSet) (*ScanDetailsResp, error)
+	ScanHistoryDetails(scanID, historyID int64) (*ScanDetailsResp, error)
 	ConfigureScan(scanID int64, scanSetting NewScanRequest) (*Scan, error)
+	ImportScan(name string, data []byte, folderID int64) (*Scan, error)
+	HostDetails(scanID, hostID int64) (*HostDetailsResp, error)
+	HostHistoryDetails(scanID, hostID, historyID int64) (*HostDetailsResp, error)
+	PluginOutput(scanID, hostID, pluginID, historyID int64) (*PluginOutputResp, error)
 
 	Timezones() ([]TimeZone, error)
+}
 
+// FolderService manages the folders scans are sorted in.
+type FolderService interface {
 	Folders() ([]Folder, error)
 	CreateFolder(name string) error
 	EditFolder(folderID int64, newName string) error
 	DeleteFolder(folderID int64) error
+}
 
+// ExportService exports scan results to files.
+type ExportService interface {
 	ExportScan(scanID, templateID int64, format string) (int64, error)
+	ExportScanWithOptions(scanID int64, opts ExportOptions) (int64, error)
 	ExportFinished(scanID, exportID int64) (bool, error)
 	DownloadExport(scanID, exportID int64) ([]byte, error)
-
-	Permissions(objectType string, objectID int64) ([]Permission, error)
 }
 
 const (
@@ -112,6 +187,8 @@ type nessusImpl struct {
 }
 
 // NewNessus will return a new Nessus instance, if caCertPath is empty, the host certificate roots will be used to check for the validity of the nessus server API certificate.
+// Like the other constructors, it fetches the API token of the server from nessus6.js and returns an error when
+// the server cannot be reached or fails. A server without the script or the token gets no X-API-Token header.
 func NewNessus(apiURL, caCertPath string) (Nessus, error) {
 	return newNessus(apiURL, caCertPath, "", "", false, false, false, nil)
 }
@@ -132,6 +209,19 @@ func NewFingerprintedNessus(apiURL string, certFingerprints []string) (Nessus, e
 	return newNessus(apiURL, "", "", "", false, true, true, certFingerprints)
 }
 
+// NewNessusWithHTTPClient will return a nessus instance issuing its requests with the given HTTP client,
+// e.g. to use a custom transport, proxy or timeout. Certificate checks are left to the client.
+// The API token is fetched with the client, and an error is returned when it cannot be.
+func NewNessusWithHTTPClient(apiURL string, client *http.Client) (Nessus, error) {
+	return newNessusWithClient(apiURL, "", "", client)
+}
+
+// NewNessusWithHTTPClientAndAPICredentials will return a nessus instance issuing its requests with the given
+// HTTP client and authenticating with API keys instead of the standard 'Cookie' login mechanism.
+func NewNessusWithHTTPClientAndAPICredentials(apiURL, accessKey, secretKey string, client *http.Client) (Nessus, error) {
+	return newNessusWithClient(apiURL, accessKey, secretKey, client)
+}
+
 func newNessus(
 	apiURL,
 	caCertPath,
@@ -173,7 +263,14 @@ func newNessus(
 		},
 	}
 
-	apiToken := getApiToken(apiURL, client)
+	return newNessusWithClient(apiURL, accessKey, secretKey, client)
+}
+
+func newNessusWithClient(apiURL, accessKey, secretKey string, client *http.Client) (Nessus, error) {
+	apiToken, err := getApiToken(apiURL, client)
+	if err != nil {
+		return nil, err
+	}
 
 	return &nessusImpl{
 		apiURL:    apiURL,
@@ -209,21 +306,31 @@ func createDialTLSFuncToVerifyFingerprint(certFingerprints []string, config *tls
 	}
 }
 
-func getApiToken(url string, client *http.Client) string {
+// getApiToken returns the API token served in nessus6.js. Servers without
+// the script or whose script holds no token get an empty token, and thus no
+// X-API-Token header, other failures are errors.
+func getApiToken(url string, client *http.Client) (string, error) {
 	nessusJs := fmt.Sprintf("%s/%s", url, NessusApiTokenPath)
 
 	resp, err := client.Get(nessusJs)
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("cannot fetch api token: %v", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("cannot fetch api token: unexpected status code %d: %s", resp.StatusCode, body)
+	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("cannot read api token: %v", err)
 	}
 
-	return NessusAPITokenRegex.FindString(string(data))
+	return NessusAPITokenRegex.FindString(string(data)), nil
 }
 
 func (n *nessusImpl) SetVerbose(verbosity bool) {
@@ -236,6 +343,11 @@ func (n *nessusImpl) AuthCookie() string {
 
 // Request make a request to Nessus
 func (n *nessusImpl) Request(method string, resource string, js interface{}, wantStatus []int) (resp *http.Response, err error) {
+	return n.request(context.Background(), method, resource, js, wantStatus)
+}
+
+// request is Request, canceled when ctx is done.
+func (n *nessusImpl) request(ctx context.Context, method string, resource string, js interface{}, wantStatus []int) (resp *http.Response, err error) {
 	u, err := url.ParseRequestURI(n.apiURL)
 	if err != nil {
 		return nil, err
@@ -262,6 +374,7 @@ func (n *nessusImpl) Request(method string, resource string, js interface{}, wan
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
 
@@ -405,6 +518,241 @@ func (n *nessusImpl) ServerStatus() (*ServerStatus, error) {
 	return reply, nil
 }
 
+const (
+	ServerStatusReady      = "ready"
+	ServerStatusLoading    = "loading"
+	ServerStatusRegister   = "register"
+	ServerStatusRestarting = "restarting"
+)
+
+// serverReadyPollInterval is how often WaitUntilReady polls the server status.
+var serverReadyPollInterval = 5 * time.Second
+
+// UpdatePlugins will ask the nessus instance to fetch the latest plugin feed.
+// The update runs in the background, use WaitUntilReady to wait until the new plugins are loaded.
+func (n *nessusImpl) UpdatePlugins() error {
+	if n.verbose {
+		log.Println("Updating plugins...")
+	}
+
+	_, err := n.Request("POST", "/settings/software-update", nil, []int{http.StatusOK})
+	return err
+}
+
+// UploadPluginArchive will upload an offline plugin archive (all-2.0.tar.gz) and install it.
+// Like UpdatePlugins, the plugins are compiled in the background.
+func (n *nessusImpl) UploadPluginArchive(filePath string) error {
+	if n.verbose {
+		log.Println("Uploading plugin archive...")
+	}
+
+	fileName, err := n.uploadFile(filePath)
+	if err != nil {
+		return err
+	}
+	req := uploadPluginArchiveRequest{FileName: fileName}
+	_, err = n.Request("POST", "/settings/software-update/upload", req, []int{http.StatusOK})
+	return err
+}
+
+// RestartServer will restart the nessus service, the current session is invalidated.
+func (n *nessusImpl) RestartServer() error {
+	if n.verbose {
+		log.Println("Restarting server...")
+	}
+
+	_, err := n.Request("POST", "/server/restart", nil, []int{http.StatusOK})
+	return err
+}
+
+// WaitUntilReady will poll the server status until nessus reports it is ready or ctx is done.
+// While nessus is loading plugins or waiting to be registered it answers with a 503, each of
+// those intermediate statuses is passed to progress if it is not nil.
+// Errors while polling (e.g. during a restart) are retried until ctx is done.
+func (n *nessusImpl) WaitUntilReady(ctx context.Context, progress func(*ServerStatus)) error {
+	if n.verbose {
+		log.Println("Waiting for server to be ready...")
+	}
+
+	ticker := time.NewTicker(serverReadyPollInterval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		status, err := n.ServerStatus()
+		if err == nil && status.Status == ServerStatusReady {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		} else if progress != nil {
+			progress(status)
+		}
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("server not ready: %v (last error: %v)", ctx.Err(), lastErr)
+			}
+			return fmt.Errorf("server not ready: %v", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+const (
+	SMTPAuthNone    = "NONE"
+	SMTPAuthPlain   = "PLAIN"
+	SMTPAuthLogin   = "LOGIN"
+	SMTPAuthNTLM    = "NTLM"
+	SMTPAuthCRAMMD5 = "CRAM-MD5"
+
+	SMTPEncryptionNone     = "No Encryption"
+	SMTPEncryptionTLSMaybe = "Use TLS if available"
+	SMTPEncryptionSSL      = "Force SSL"
+	SMTPEncryptionTLS      = "Force TLS"
+
+	LDAPEncryptionNone = "none"
+	LDAPEncryptionSSL  = "ssl"
+	LDAPEncryptionTLS  = "tls"
+)
+
+// AdvancedSettings will return the advanced settings (global scanner preferences) of the nessus instance.
+func (n *nessusImpl) AdvancedSettings() ([]AdvancedSetting, error) {
+	if n.verbose {
+		log.Println("Getting advanced settings...")
+	}
+
+	resp, err := n.Request("GET", "/settings/advanced", nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &listAdvancedSettingsResp{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply.Preferences, nil
+}
+
+// EditAdvancedSettings will create or update the given advanced settings.
+// Settings with an empty ID are added, the others are edited in place.
+func (n *nessusImpl) EditAdvancedSettings(settings []AdvancedSetting) error {
+	if n.verbose {
+		log.Println("Editing advanced settings...")
+	}
+
+	// The API expects a flattened list of setting.<idx>.<field> keys.
+	data := map[string]string{}
+	for i, setting := range settings {
+		prefix := fmt.Sprintf("setting.%d.", i)
+		if setting.ID == "" {
+			data[prefix+"action"] = "add"
+		} else {
+			data[prefix+"action"] = "edit"
+			data[prefix+"id"] = setting.ID
+		}
+		data[prefix+"name"] = setting.Name
+		data[prefix+"value"] = setting.Value
+	}
+
+	_, err := n.Request("PUT", "/settings/advanced", data, []int{http.StatusOK})
+	return err
+}
+
+// ProxySettings will return the proxy used by the nessus instance to reach the internet.
+func (n *nessusImpl) ProxySettings() (*ProxySettings, error) {
+	if n.verbose {
+		log.Println("Getting proxy settings...")
+	}
+
+	resp, err := n.Request("GET", "/settings/network/proxy", nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &ProxySettings{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// EditProxySettings will replace the proxy settings of the nessus instance.
+func (n *nessusImpl) EditProxySettings(settings ProxySettings) error {
+	if n.verbose {
+		log.Println("Editing proxy settings...")
+	}
+
+	_, err := n.Request("PUT", "/settings/network/proxy", settings, []int{http.StatusOK})
+	return err
+}
+
+// MailSettings will return the SMTP server configuration of the nessus instance.
+func (n *nessusImpl) MailSettings() (*MailSettings, error) {
+	if n.verbose {
+		log.Println("Getting mail settings...")
+	}
+
+	resp, err := n.Request("GET", "/settings/network/mail", nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &MailSettings{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// EditMailSettings will replace the SMTP server configuration of the nessus instance.
+func (n *nessusImpl) EditMailSettings(settings MailSettings) error {
+	if n.verbose {
+		log.Println("Editing mail settings...")
+	}
+
+	_, err := n.Request("PUT", "/settings/network/mail", settings, []int{http.StatusOK})
+	return err
+}
+
+// SendTestEmail will send a test email through the configured SMTP server.
+func (n *nessusImpl) SendTestEmail(to string) error {
+	if n.verbose {
+		log.Println("Sending test email...")
+	}
+
+	req := testEmailRequest{To: to}
+	_, err := n.Request("POST", "/settings/network/mail/test", req, []int{http.StatusOK})
+	return err
+}
+
+// LDAPSettings will return the LDAP server used to authenticate users.
+func (n *nessusImpl) LDAPSettings() (*LDAPSettings, error) {
+	if n.verbose {
+		log.Println("Getting LDAP settings...")
+	}
+
+	resp, err := n.Request("GET", "/settings/ldap", nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &LDAPSettings{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// EditLDAPSettings will replace the LDAP server configuration of the nessus instance.
+func (n *nessusImpl) EditLDAPSettings(settings LDAPSettings) error {
+	if n.verbose {
+		log.Println("Editing LDAP settings...")
+	}
+
+	_, err := n.Request("PUT", "/settings/ldap", settings, []int{http.StatusOK})
+	return err
+}
+
 const (
 	UserTypeLocal = "local"
 	UserTypeLDAP  = "ldap"
@@ -661,8 +1009,10 @@ func (n *nessusImpl) Policies() ([]Policy, error) {
 	return reply.Policies, nil
 }
 
+// Launch settings of scans, see also Schedule.
 const (
 	LaunchOnDemand = "ON_DEMAND"
+	LaunchOnce     = "ONETIME"
 	LaunchDaily    = "DAILY"
 	LaunchWeekly   = "WEEKLY"
 	LaunchMonthly  = "MONTHLY"
@@ -718,8 +1068,37 @@ func (n *nessusImpl) Scans() (*ListScansResponse, error) {
 	if n.verbose {
 		log.Println("Getting scans list...")
 	}
+	return n.listScans(context.Background(), "")
+}
+
+// ScansSince lists the scans modified at or after since, a server timestamp
+// such as ListScansResponse.Timestamp of a previous call. Folders are always
+// listed. A zero since lists every scan.
+func (n *nessusImpl) ScansSince(ctx context.Context, since Timestamp) (*ListScansResponse, error) {
+	if n.verbose {
+		log.Printf("Getting scans modified since %d...\n", since.Epoch())
+	}
+	var query string
+	if !since.IsZero() {
+		query = fmt.Sprintf("last_modification_date=%d", since.Epoch())
+	}
+	return n.listScans(ctx, query)
+}
+
+// ScansInFolder lists the scans of a folder.
+func (n *nessusImpl) ScansInFolder(folderID int64) (*ListScansResponse, error) {
+	if n.verbose {
+		log.Printf("Getting scans of folder %d...\n", folderID)
+	}
+	return n.listScans(context.Background(), fmt.Sprintf("folder_id=%d", folderID))
+}
 
-	resp, err := n.Request("GET", "/scans", nil, []int{http.StatusOK})
+func (n *nessusImpl) listScans(ctx context.Context, query string) (*ListScansResponse, error) {
+	resource := "/scans"
+	if query != "" {
+		resource += "?" + query
+	}
+	resp, err := n.request(ctx, "GET", resource, nil, []int{http.StatusOK})
 	if err != nil {
 		return nil, err
 	}
@@ -820,11 +1199,34 @@ func (n *nessusImpl) DeleteScan(scanID int64) error {
 }
 
 func (n *nessusImpl) ScanDetails(scanID int64) (*ScanDetailsResp, error) {
+	return n.ScanDetailsFiltered(scanID, nil)
+}
+
+// ScanDetailsFiltered returns the details of a scan, only including the hosts and vulnerabilities matching filters.
+// A nil filter set returns the full details.
+func (n *nessusImpl) ScanDetailsFiltered(scanID int64, filters *FilterSet) (*ScanDetailsResp, error) {
+	return n.scanDetails(scanID, 0, filters)
+}
+
+// ScanHistoryDetails returns the details of a previous run of the scan, as listed in ScanDetailsResp.History.
+func (n *nessusImpl) ScanHistoryDetails(scanID, historyID int64) (*ScanDetailsResp, error) {
+	return n.scanDetails(scanID, historyID, nil)
+}
+
+func (n *nessusImpl) scanDetails(scanID, historyID int64, filters *FilterSet) (*ScanDetailsResp, error) {
 	if n.verbose {
 		log.Println("Getting details about a scan...")
 	}
 
-	resp, err := n.Request("GET", fmt.Sprintf("/scans/%d", scanID), nil, []int{http.StatusOK})
+	resource := fmt.Sprintf("/scans/%d", scanID)
+	q := filters.Query()
+	if historyID != 0 {
+		q = joinQuery(fmt.Sprintf("history_id=%d", historyID), q)
+	}
+	if q != "" {
+		resource += "?" + q
+	}
+	resp, err := n.Request("GET", resource, nil, []int{http.StatusOK})
 	if err != nil {
 		return nil, err
 	}
@@ -839,6 +1241,66 @@ func (n *nessusImpl) ScanDetails(scanID int64) (*ScanDetailsResp, error) {
 	return reply, nil
 }
 
+// HostDetails returns the vulnerabilities and compliance results of a host of the scan.
+func (n *nessusImpl) HostDetails(scanID, hostID int64) (*HostDetailsResp, error) {
+	return n.HostHistoryDetails(scanID, hostID, 0)
+}
+
+// HostHistoryDetails returns the details of a host in a previous run of the scan, 0 is the latest run.
+// Host IDs are not stable across runs, they must be taken from the details of the same run.
+func (n *nessusImpl) HostHistoryDetails(scanID, hostID, historyID int64) (*HostDetailsResp, error) {
+	if n.verbose {
+		log.Println("Getting details about a host...")
+	}
+
+	resource := fmt.Sprintf("/scans/%d/hosts/%d", scanID, hostID)
+	if historyID != 0 {
+		resource += fmt.Sprintf("?history_id=%d", historyID)
+	}
+	resp, err := n.Request("GET", resource, nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &HostDetailsResp{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// PluginOutput returns the output of a plugin on a host, per port, in a run of the scan (0 is the latest run).
+func (n *nessusImpl) PluginOutput(scanID, hostID, pluginID, historyID int64) (*PluginOutputResp, error) {
+	if n.verbose {
+		log.Println("Getting plugin output...")
+	}
+
+	resource := fmt.Sprintf("/scans/%d/hosts/%d/plugins/%d", scanID, hostID, pluginID)
+	if historyID != 0 {
+		resource += fmt.Sprintf("?history_id=%d", historyID)
+	}
+	resp, err := n.Request("GET", resource, nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &PluginOutputResp{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func joinQuery(queries ...string) string {
+	var parts []string
+	for _, q := range queries {
+		if q != "" {
+			parts = append(parts, q)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
 func (n *nessusImpl) ConfigureScan(scanID int64, scanSetting NewScanRequest) (*Scan, error) {
 	if n.verbose {
 		log.Println("Configuring a scan...")
@@ -901,6 +1363,24 @@ func (n *nessusImpl) CreateFolder(name string) error {
 	return err
 }
 
+// CreateFolderID creates a custom folder and returns its ID. Nessus does not
+// return the ID of a created folder, so the folders are listed to find it.
+func CreateFolderID(n FolderService, name string) (int64, error) {
+	if err := n.CreateFolder(name); err != nil {
+		return 0, err
+	}
+	folders, err := n.Folders()
+	if err != nil {
+		return 0, fmt.Errorf("cannot list folders: %v", err)
+	}
+	for _, f := range folders {
+		if f.Custom != 0 && f.Name == name {
+			return f.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("created folder is not listed")
+}
+
 func (n *nessusImpl) EditFolder(folderID int64, newName string) error {
 	if n.verbose {
 		log.Println("Editing folders...")
@@ -936,7 +1416,29 @@ func (n *nessusImpl) ExportScan(scanID, templateID int64, format string) (int64,
 	}
 
 	req := exportScanRequest{Format: format, TemplateID: templateID}
-	resp, err := n.Request("POST", fmt.Sprintf("/scans/%d/export", scanID), req, []int{http.StatusOK})
+	return n.exportScan(fmt.Sprintf("/scans/%d/export", scanID), req)
+}
+
+// ExportScanWithOptions exports a scan to a File resource, like ExportScan, with control over
+// the chapters, filters, history run, password and columns of the export.
+// The options are validated against the requested format before anything is sent to nessus.
+func (n *nessusImpl) ExportScanWithOptions(scanID int64, opts ExportOptions) (int64, error) {
+	if n.verbose {
+		log.Println("Exporting scan with options...")
+	}
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	resource := fmt.Sprintf("/scans/%d/export", scanID)
+	if opts.HistoryID != 0 {
+		resource += fmt.Sprintf("?history_id=%d", opts.HistoryID)
+	}
+	return n.exportScan(resource, opts.request())
+}
+
+func (n *nessusImpl) exportScan(resource string, req exportScanRequest) (int64, error) {
+	resp, err := n.Request("POST", resource, req, []int{http.StatusOK})
 	if err != nil {
 		return 0, err
 	}
@@ -1040,6 +1542,17 @@ func (n *nessusImpl) Permissions(objectType string, objectID int64) ([]Permissio
 	return reply, nil
 }
 
+// SetPermissions replaces the permissions of an object, e.g. a scan or a policy.
+func (n *nessusImpl) SetPermissions(objectType string, objectID int64, acls []Permission) error {
+	if n.verbose {
+		log.Printf("Setting permissions of %s %d...\n", objectType, objectID)
+	}
+
+	req := setPermissionsRequest{Acls: acls}
+	_, err := n.Request("PUT", fmt.Sprintf("/permissions/%s/%d", objectType, objectID), req, []int{http.StatusOK})
+	return err
+}
+
 // CreatePolicy Create a policy.
 func (n *nessusImpl) CreatePolicy(createPolicyRequest CreatePolicyRequest) (CreatePolicyResp, error) {
 	if n.verbose {
@@ -1079,47 +1592,168 @@ func (n *nessusImpl) DeletePolicy(policyID int64) error {
 	return err
 }
 
-// Upload Upload a file.
-func (n *nessusImpl) Upload(filePath string) error {
+// ExportPolicy returns a policy as a .nessus policy file.
+func (n *nessusImpl) ExportPolicy(policyID int64) ([]byte, error) {
 	if n.verbose {
-		log.Println("Uploading a file...")
+		log.Println("Exporting a policy...")
 	}
 
+	resp, err := n.Request("GET", fmt.Sprintf("/policies/%d/export", policyID), nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ImportPolicy uploads a .nessus policy file, e.g. from ExportPolicy, under
+// the given file name and creates a policy from it.
+func (n *nessusImpl) ImportPolicy(name string, data []byte) (*Policy, error) {
+	if n.verbose {
+		log.Println("Importing a policy...")
+	}
+
+	fileName, err := n.upload(name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.Request("POST", "/policies/import", importPolicyRequest{File: fileName}, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &Policy{}
+	if err = json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// ImportScan uploads a .nessus scan export and imports it as a new scan in the
+// given folder, My Scans when 0. The results of the file become the only run
+// of the imported scan.
+func (n *nessusImpl) ImportScan(name string, data []byte, folderID int64) (*Scan, error) {
+	if n.verbose {
+		log.Println("Importing a scan...")
+	}
+
+	fileName, err := n.upload(name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.Request("POST", "/scans/import", importScanRequest{File: fileName, FolderID: folderID}, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := struct {
+		Scan Scan `json:"scan"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return &reply.Scan, nil
+}
+
+// PluginRules returns the rules changing the severity of plugins or hiding
+// their findings.
+func (n *nessusImpl) PluginRules() ([]Rule, error) {
+	if n.verbose {
+		log.Println("Getting list of plugin rules...")
+	}
+
+	resp, err := n.Request("GET", "/plugin-rules", nil, []int{http.StatusOK})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reply := &listPluginRulesResp{}
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	return reply.PluginRules, nil
+}
+
+// CreatePluginRule creates a plugin rule, its ID and owner are ignored.
+func (n *nessusImpl) CreatePluginRule(rule Rule) error {
+	if n.verbose {
+		log.Println("Creating a plugin rule...")
+	}
+
+	req := createPluginRuleRequest{PluginID: rule.PluginID, Type: rule.Type, Host: rule.Host, Date: rule.Date}
+	_, err := n.Request("POST", "/plugin-rules", req, []int{http.StatusOK})
+	return err
+}
+
+// Upload Upload a file.
+func (n *nessusImpl) Upload(filePath string) error {
+	_, err := n.uploadFile(filePath)
+	return err
+}
+
+// uploadFile uploads a file and returns the name nessus stored it under.
+func (n *nessusImpl) uploadFile(filePath string) (string, error) {
 	f, err := os.OpenFile(filePath, os.O_RDONLY, 0644)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer f.Close()
+	return n.upload(filepath.Base(filePath), f)
+}
+
+// upload uploads the content of r as a file named name, and returns the name
+// nessus stored it under.
+func (n *nessusImpl) upload(name string, r io.Reader) (string, error) {
+	if n.verbose {
+		log.Println("Uploading a file...")
 	}
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("Filedata", filepath.Base(filePath))
+	part, err := writer.CreateFormFile("Filedata", name)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if _, err = io.Copy(part, r); err != nil {
+		return "", err
 	}
-	_, err = io.Copy(part, f)
 
 	if err = writer.Close(); nil != err {
-		return err
+		return "", err
 	}
 
 	u, err := url.ParseRequestURI(n.apiURL)
 	if err != nil {
-		return err
+		return "", err
 	}
 	u.Path = "/file/upload"
 	urlStr := fmt.Sprintf("%v", u)
 
 	req, err := http.NewRequest(http.MethodPost, urlStr, body)
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	req.Header.Add("Accept", "application/json")
 	if n.authCookie != "" {
 		req.Header.Add("X-Cookie", fmt.Sprintf("token=%s", n.authCookie))
 	}
+	if n.accessKey != "" && n.secretKey != "" {
+		req.Header.Add("X-ApiKeys", fmt.Sprintf("accessKey=%s; secretKey=%s", n.accessKey, n.secretKey))
+	}
 
 	resp, err := n.client.Do(req)
 	if nil != err {
-		return err
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("Unexpected status code, got %d wanted %v (%s)", resp.StatusCode, []int{http.StatusOK}, body)
 	}
 
 	reply := struct {
@@ -1127,17 +1761,17 @@ func (n *nessusImpl) Upload(filePath string) error {
 	}{}
 
 	if err = json.NewDecoder(resp.Body).Decode(&reply); nil != err {
-		return err
+		return "", err
 	}
 
 	// Duplicate updates will get different replies
 	// request:             CIS_CentOS_7_Server_L1_v3.0.0.audit
 	// reply: {FileUploaded:CIS_CentOS_7_Server_L1_v3.0.0-6.audit}
 	if 0 == len(reply.FileUploaded) {
-		return fmt.Errorf("Upload failed, api reply: %+v", reply)
+		return "", fmt.Errorf("Upload failed, api reply: %+v", reply)
 	}
 
-	return nil
+	return reply.FileUploaded, nil
 }
 
 // AgentGroups Returns a list of agent groups.
@@ -1,6 +1,7 @@
 package nessie
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -8,9 +9,12 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -135,6 +139,19 @@ func TestMethods(t *testing.T) {
 		{&Session{}, http.StatusOK, func(n Nessus) { n.Session() }},
 		{&ServerProperties{}, http.StatusOK, func(n Nessus) { n.ServerProperties() }},
 		{&ServerStatus{}, http.StatusOK, func(n Nessus) { n.ServerStatus() }},
+		{nil, http.StatusOK, func(n Nessus) { n.UpdatePlugins() }},
+		{nil, http.StatusOK, func(n Nessus) { n.RestartServer() }},
+		{&listAdvancedSettingsResp{}, http.StatusOK, func(n Nessus) { n.AdvancedSettings() }},
+		{nil, http.StatusOK, func(n Nessus) {
+			n.EditAdvancedSettings([]AdvancedSetting{{Name: "max_hosts", Value: "100"}})
+		}},
+		{&ProxySettings{}, http.StatusOK, func(n Nessus) { n.ProxySettings() }},
+		{nil, http.StatusOK, func(n Nessus) { n.EditProxySettings(ProxySettings{Proxy: "proxy.local", ProxyPort: 3128}) }},
+		{&MailSettings{}, http.StatusOK, func(n Nessus) { n.MailSettings() }},
+		{nil, http.StatusOK, func(n Nessus) { n.EditMailSettings(MailSettings{Host: "smtp.local", Port: 25}) }},
+		{nil, http.StatusOK, func(n Nessus) { n.SendTestEmail("sec@example.com") }},
+		{&LDAPSettings{}, http.StatusOK, func(n Nessus) { n.LDAPSettings() }},
+		{nil, http.StatusOK, func(n Nessus) { n.EditLDAPSettings(LDAPSettings{Host: "ldap.local", Port: 389}) }},
 		{&User{}, http.StatusOK, func(n Nessus) {
 			n.CreateUser("username", "pass", UserTypeLocal, Permissions32, "name", "email@foo.com")
 		}},
@@ -153,6 +170,8 @@ func TestMethods(t *testing.T) {
 			n.NewScan("editorUUID", "settingsName", 42, 43, 44, LaunchDaily, []string{"target1", "target2"})
 		}},
 		{&ListScansResponse{}, http.StatusOK, func(n Nessus) { n.Scans() }},
+		{&ListScansResponse{}, http.StatusOK, func(n Nessus) { n.ScansSince(context.Background(), UnixTimestamp(1609459200)) }},
+		{&ListScansResponse{}, http.StatusOK, func(n Nessus) { n.ScansInFolder(3) }},
 		{[]Template{}, http.StatusOK, func(n Nessus) { n.ScanTemplates() }},
 		{[]Template{}, http.StatusOK, func(n Nessus) { n.PolicyTemplates() }},
 		{"id", http.StatusOK, func(n Nessus) { n.StartScan(42) }},
@@ -161,15 +180,28 @@ func TestMethods(t *testing.T) {
 		{nil, http.StatusOK, func(n Nessus) { n.StopScan(42) }},
 		{nil, http.StatusOK, func(n Nessus) { n.DeleteScan(42) }},
 		{&ScanDetailsResp{}, http.StatusOK, func(n Nessus) { n.ScanDetails(42) }},
+		{&HostDetailsResp{}, http.StatusOK, func(n Nessus) { n.HostDetails(42, 43) }},
+		{&ScanDetailsResp{}, http.StatusOK, func(n Nessus) { n.ScanHistoryDetails(42, 43) }},
+		{&HostDetailsResp{}, http.StatusOK, func(n Nessus) { n.HostHistoryDetails(42, 43, 44) }},
+		{&PluginOutputResp{}, http.StatusOK, func(n Nessus) { n.PluginOutput(42, 43, 44, 45) }},
 		{[]TimeZone{}, http.StatusOK, func(n Nessus) { n.Timezones() }},
 		{[]Folder{}, http.StatusOK, func(n Nessus) { n.Folders() }},
 		{nil, http.StatusOK, func(n Nessus) { n.CreateFolder("name") }},
 		{nil, http.StatusOK, func(n Nessus) { n.EditFolder(42, "newname") }},
 		{nil, http.StatusOK, func(n Nessus) { n.DeleteFolder(42) }},
-		{42, http.StatusOK, func(n Nessus) { n.ExportScan(42, ExportPDF) }},
+		{42, http.StatusOK, func(n Nessus) { n.ExportScan(42, 43, ExportPDF) }},
+		{42, http.StatusOK, func(n Nessus) {
+			n.ExportScanWithOptions(42, ExportOptions{Format: ExportPDF, Chapters: []string{ChapterVulnByHost}})
+		}},
 		{true, http.StatusOK, func(n Nessus) { n.ExportFinished(42, 43) }},
 		{[]byte("raw export"), http.StatusOK, func(n Nessus) { n.DownloadExport(42, 43) }},
 		{[]Permission{}, http.StatusOK, func(n Nessus) { n.Permissions("scanner", 42) }},
+		{&listGroupsResp{}, http.StatusOK, func(n Nessus) { n.ListGroups() }},
+		{&Group{}, http.StatusOK, func(n Nessus) { n.CreateGroup("auditors") }},
+		{[]byte("<NessusClientData_v2/>"), http.StatusOK, func(n Nessus) { n.ExportPolicy(42) }},
+		{&listPluginRulesResp{}, http.StatusOK, func(n Nessus) { n.PluginRules() }},
+		{nil, http.StatusOK, func(n Nessus) { n.CreatePluginRule(Rule{PluginID: 42, Type: "recast_low", Host: "10.0.0.1"}) }},
+		{nil, http.StatusOK, func(n Nessus) { n.SetPermissions("scan", 42, []Permission{{Type: "default", Permissions: 16}}) }},
 	}
 	for _, tt := range tests {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -192,6 +224,187 @@ func TestMethods(t *testing.T) {
 	}
 }
 
+func TestWaitUntilReady(t *testing.T) {
+	defer func(interval time.Duration) { serverReadyPollInterval = interval }(serverReadyPollInterval)
+	serverReadyPollInterval = time.Millisecond
+	statuses := []ServerStatus{
+		{Status: ServerStatusRegister},
+		{Status: ServerStatusLoading, Progress: 40},
+		{Status: ServerStatusLoading, Progress: 90},
+		{Status: ServerStatusReady, Progress: 100},
+	}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/server/status" {
+			return
+		}
+		status := statuses[calls]
+		if calls < len(statuses)-1 {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		j, err := json.Marshal(status)
+		if err != nil {
+			t.Errorf("cannot serialize response: %v", err)
+			return
+		}
+		w.Write(j)
+	}))
+	defer server.Close()
+	n, err := NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+
+	var progress []int64
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = n.WaitUntilReady(ctx, func(s *ServerStatus) {
+		if !s.MustDestroySession {
+			t.Errorf("intermediate status %q should come from a 503", s.Status)
+		}
+		progress = append(progress, s.Progress)
+	})
+	if err != nil {
+		t.Fatalf("got error waiting for server: %v", err)
+	}
+	if got, want := fmt.Sprint(progress), "[0 40 90]"; got != want {
+		t.Errorf("wrong progress reported, got=%s, want=%s", got, want)
+	}
+
+	// A server that never becomes ready must give up once the context is done.
+	calls = 0
+	statuses = []ServerStatus{{Status: ServerStatusLoading}, {Status: ServerStatusLoading}}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := n.WaitUntilReady(ctx, nil); err == nil {
+		t.Error("got no error, expected a timeout")
+	}
+}
+
+func TestUploadStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file/upload" {
+			return
+		}
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`{"error":"file too large"}`))
+	}))
+	defer server.Close()
+	n, err := NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	_, err = n.ImportPolicy("policy.nessus", []byte("<NessusClientData_v2/>"))
+	if err == nil || !strings.Contains(err.Error(), "413") || !strings.Contains(err.Error(), "file too large") {
+		t.Errorf("upload error should hold the status and body, got %v", err)
+	}
+}
+
+func TestServerPropertiesHealth(t *testing.T) {
+	now := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	p := &ServerProperties{
+		Expiration:      NewTimestamp(now.Add(-time.Hour)),
+		LoadedPluginSet: "202101021504",
+	}
+	if !p.LicenseExpired(now) {
+		t.Error("license should be expired")
+	}
+	if p.FeedStale(now, 10*24*time.Hour) {
+		t.Error("feed should not be stale after 8 days")
+	}
+	if !p.FeedStale(now, 7*24*time.Hour) {
+		t.Error("feed should be stale after 8 days")
+	}
+	if !(&ServerProperties{}).FeedStale(now, time.Hour) {
+		t.Error("missing plugin set should be stale")
+	}
+}
+
+func TestEditAdvancedSettings(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/settings/advanced" {
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("could not decode request body: %v", err)
+		}
+	}))
+	defer server.Close()
+	n, err := NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	settings := []AdvancedSetting{
+		{ID: "12", Name: "max_hosts", Value: "100"},
+		{Name: "custom_pref", Value: "yes"},
+	}
+	if err := n.EditAdvancedSettings(settings); err != nil {
+		t.Fatalf("got error editing settings: %v", err)
+	}
+	want := map[string]string{
+		"setting.0.action": "edit",
+		"setting.0.id":     "12",
+		"setting.0.name":   "max_hosts",
+		"setting.0.value":  "100",
+		"setting.1.action": "add",
+		"setting.1.name":   "custom_pref",
+		"setting.1.value":  "yes",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected payload, got=%v, want=%v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("unexpected value for %s, got=%q, want=%q", k, got[k], v)
+		}
+	}
+}
+
+func TestParsePortKey(t *testing.T) {
+	var tests = []struct {
+		key  string
+		want PortKey
+	}{
+		{"443 / tcp / www", PortKey{443, "tcp", "www"}},
+		{"0 / udp / general", PortKey{0, "udp", "general"}},
+		{"22 / tcp", PortKey{22, "tcp", ""}},
+		{"garbage", PortKey{}},
+	}
+	for _, tt := range tests {
+		if got := ParsePortKey(tt.key); got != tt.want {
+			t.Errorf("wrong port key for %q, got=%+v, want=%+v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestPluginOutputPorts(t *testing.T) {
+	var tests = []struct {
+		data string
+		want []string
+	}{
+		{`{"ports": {"8443 / tcp / www": [], "443 / tcp / www": [{"hostname": "web"}]}}`, []string{"443 / tcp / www", "8443 / tcp / www"}},
+		{`{"ports": ["22 / tcp / ssh"]}`, []string{"22 / tcp / ssh"}},
+		{`{"ports": null}`, nil},
+		{`{}`, nil},
+	}
+	for _, tt := range tests {
+		var o PluginOutput
+		if err := json.Unmarshal([]byte(tt.data), &o); err != nil {
+			t.Errorf("cannot decode %s: %v", tt.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(o.Ports, tt.want) {
+			t.Errorf("wrong ports for %s, got=%q, want=%q", tt.data, o.Ports, tt.want)
+		}
+	}
+	o := PluginOutput{Ports: []string{"443 / tcp / www"}}
+	if keys := o.PortKeys(); len(keys) != 1 || keys[0] != (PortKey{443, "tcp", "www"}) {
+		t.Errorf("wrong port keys %+v", keys)
+	}
+}
+
 func TestSha256Fingerprint(t *testing.T) {
 	want := "AzuD2SQxVI4TQkkDwjWpkir1bdNNU8m3KzfPFYSJIT4="
 	got := sha256Fingerprint([]byte("abc123!"))
@@ -234,10 +447,17 @@ func TestNewFingerprintedNessus(t *testing.T) {
 	if err == nil {
 		t.Fatalf("should not accept empty fingerprint: %v", err)
 	}
-	_, err = NewFingerprintedNessus("https://192.0.2.1", []string{"a"})
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	_, err = NewFingerprintedNessus(server.URL, []string{sha256Fingerprint(server.Certificate().RawSubjectPublicKeyInfo)})
 	if err != nil {
 		t.Fatalf("should accept a non-empty fingerprint: %v", err)
 	}
+	// The api token is fetched when the instance is created.
+	_, err = NewFingerprintedNessus(server.URL, []string{"a"})
+	if err == nil {
+		t.Fatalf("should not accept a server with another fingerprint")
+	}
 }
 
 func TestCreateDialTLSFuncToVerifyFingerprint(t *testing.T) {
@@ -295,3 +515,85 @@ func TestCreateDialTLSFuncToVerifyFingerprint(t *testing.T) {
 		}
 	}
 }
+
+func TestNewNessusWithHTTPClient(t *testing.T) {
+	const token = "01234567-89AB-CDEF-0123-456789ABCDEF"
+	var status int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	var tests = []struct {
+		status    int
+		body      string
+		wantToken string
+		wantError bool
+	}{
+		{http.StatusOK, `getApiToken:function(){return"` + token + `"}`, token, false},
+		// A missing script or token is tolerated.
+		{http.StatusOK, "no token here", "", false},
+		{http.StatusNotFound, "", "", false},
+		{http.StatusInternalServerError, "boom", "", true},
+		{http.StatusUnauthorized, "", "", true},
+	}
+	for _, tt := range tests {
+		status, body = tt.status, tt.body
+		n, err := NewNessusWithHTTPClient(server.URL, server.Client())
+		if (err != nil) != tt.wantError {
+			t.Errorf("status %d: got error %v, want error %v", tt.status, err, tt.wantError)
+			continue
+		}
+		if err == nil && n.(*nessusImpl).apiToken != tt.wantToken {
+			t.Errorf("status %d: wrong api token %q, want %q", tt.status, n.(*nessusImpl).apiToken, tt.wantToken)
+		}
+	}
+	server.Close()
+	if _, err := NewNessusWithHTTPClient(server.URL, server.Client()); err == nil {
+		t.Errorf("should not create an instance when the api token cannot be fetched")
+	}
+}
+
+func TestScanCursor(t *testing.T) {
+	var queries []string
+	timestamps := []int64{100, 200, 0}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scans" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		if len(queries) > len(timestamps) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(&ListScansResponse{Timestamp: UnixTimestamp(timestamps[len(queries)-1])})
+	}))
+	defer server.Close()
+	n, err := NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	c := NewScanCursor(n)
+	for i := 0; i < 4; i++ {
+		_, err := c.Next(context.Background())
+		if (err != nil) != (i == 3) {
+			t.Errorf("call %d: unexpected error %v", i, err)
+		}
+	}
+	// A failed call or a response without timestamp keeps the cursor.
+	want := []string{"", "last_modification_date=100", "last_modification_date=200", "last_modification_date=200"}
+	if fmt.Sprint(queries) != fmt.Sprint(want) || c.Timestamp().Epoch() != 200 {
+		t.Errorf("wrong queries, got=%q want=%q (timestamp=%d)", queries, want, c.Timestamp().Epoch())
+	}
+	c.Reset()
+	if !c.Timestamp().IsZero() {
+		t.Errorf("reset cursor should list every scan, got timestamp=%v", c.Timestamp())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := n.ScansSince(ctx, Timestamp{}); err == nil {
+		t.Error("canceled listing should fail")
+	}
+}
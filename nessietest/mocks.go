@@ -0,0 +1,745 @@
+// Code generated by internal/mockgen from nessie.go; DO NOT EDIT.
+
+package nessietest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/JerusJ/nessie"
+)
+
+// MockNessus implements nessie.Nessus with the mocks of the interfaces it embeds.
+type MockNessus struct {
+	MockSessionService
+	MockAdminService
+	MockUserService
+	MockPluginService
+	MockPluginRuleService
+	MockPolicyService
+	MockScanService
+	MockFolderService
+	MockExportService
+}
+
+var _ nessie.Nessus = &MockNessus{}
+
+// MockSessionService implements nessie.SessionService by calling its function fields.
+type MockSessionService struct {
+	SetVerboseFunc func(p0 bool)
+	AuthCookieFunc func() string
+	RequestFunc    func(method string, resource string, js interface{}, wantStatus []int) (*http.Response, error)
+	LoginFunc      func(username string, password string) error
+	LogoutFunc     func() error
+	SessionFunc    func() (nessie.Session, error)
+}
+
+var _ nessie.SessionService = &MockSessionService{}
+
+// SetVerbose calls SetVerboseFunc.
+func (m *MockSessionService) SetVerbose(p0 bool) {
+	if m.SetVerboseFunc == nil {
+		panic("nessietest: unexpected call to MockSessionService.SetVerbose")
+	}
+	m.SetVerboseFunc(p0)
+}
+
+// AuthCookie calls AuthCookieFunc.
+func (m *MockSessionService) AuthCookie() string {
+	if m.AuthCookieFunc == nil {
+		panic("nessietest: unexpected call to MockSessionService.AuthCookie")
+	}
+	return m.AuthCookieFunc()
+}
+
+// Request calls RequestFunc.
+func (m *MockSessionService) Request(method string, resource string, js interface{}, wantStatus []int) (*http.Response, error) {
+	if m.RequestFunc == nil {
+		panic("nessietest: unexpected call to MockSessionService.Request")
+	}
+	return m.RequestFunc(method, resource, js, wantStatus)
+}
+
+// Login calls LoginFunc.
+func (m *MockSessionService) Login(username string, password string) error {
+	if m.LoginFunc == nil {
+		panic("nessietest: unexpected call to MockSessionService.Login")
+	}
+	return m.LoginFunc(username, password)
+}
+
+// Logout calls LogoutFunc.
+func (m *MockSessionService) Logout() error {
+	if m.LogoutFunc == nil {
+		panic("nessietest: unexpected call to MockSessionService.Logout")
+	}
+	return m.LogoutFunc()
+}
+
+// Session calls SessionFunc.
+func (m *MockSessionService) Session() (nessie.Session, error) {
+	if m.SessionFunc == nil {
+		panic("nessietest: unexpected call to MockSessionService.Session")
+	}
+	return m.SessionFunc()
+}
+
+// MockAdminService implements nessie.AdminService by calling its function fields.
+type MockAdminService struct {
+	ServerPropertiesFunc     func() (*nessie.ServerProperties, error)
+	ServerStatusFunc         func() (*nessie.ServerStatus, error)
+	AdvancedSettingsFunc     func() ([]nessie.AdvancedSetting, error)
+	EditAdvancedSettingsFunc func(settings []nessie.AdvancedSetting) error
+	ProxySettingsFunc        func() (*nessie.ProxySettings, error)
+	EditProxySettingsFunc    func(settings nessie.ProxySettings) error
+	MailSettingsFunc         func() (*nessie.MailSettings, error)
+	EditMailSettingsFunc     func(settings nessie.MailSettings) error
+	SendTestEmailFunc        func(to string) error
+	LDAPSettingsFunc         func() (*nessie.LDAPSettings, error)
+	EditLDAPSettingsFunc     func(settings nessie.LDAPSettings) error
+	UpdatePluginsFunc        func() error
+	UploadPluginArchiveFunc  func(filePath string) error
+	RestartServerFunc        func() error
+	WaitUntilReadyFunc       func(ctx context.Context, progress func(*nessie.ServerStatus)) error
+	ScannersFunc             func() ([]nessie.Scanner, error)
+	AgentGroupsFunc          func() ([]nessie.AgentGroup, error)
+	UploadFunc               func(filePath string) error
+}
+
+var _ nessie.AdminService = &MockAdminService{}
+
+// ServerProperties calls ServerPropertiesFunc.
+func (m *MockAdminService) ServerProperties() (*nessie.ServerProperties, error) {
+	if m.ServerPropertiesFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.ServerProperties")
+	}
+	return m.ServerPropertiesFunc()
+}
+
+// ServerStatus calls ServerStatusFunc.
+func (m *MockAdminService) ServerStatus() (*nessie.ServerStatus, error) {
+	if m.ServerStatusFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.ServerStatus")
+	}
+	return m.ServerStatusFunc()
+}
+
+// AdvancedSettings calls AdvancedSettingsFunc.
+func (m *MockAdminService) AdvancedSettings() ([]nessie.AdvancedSetting, error) {
+	if m.AdvancedSettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.AdvancedSettings")
+	}
+	return m.AdvancedSettingsFunc()
+}
+
+// EditAdvancedSettings calls EditAdvancedSettingsFunc.
+func (m *MockAdminService) EditAdvancedSettings(settings []nessie.AdvancedSetting) error {
+	if m.EditAdvancedSettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.EditAdvancedSettings")
+	}
+	return m.EditAdvancedSettingsFunc(settings)
+}
+
+// ProxySettings calls ProxySettingsFunc.
+func (m *MockAdminService) ProxySettings() (*nessie.ProxySettings, error) {
+	if m.ProxySettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.ProxySettings")
+	}
+	return m.ProxySettingsFunc()
+}
+
+// EditProxySettings calls EditProxySettingsFunc.
+func (m *MockAdminService) EditProxySettings(settings nessie.ProxySettings) error {
+	if m.EditProxySettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.EditProxySettings")
+	}
+	return m.EditProxySettingsFunc(settings)
+}
+
+// MailSettings calls MailSettingsFunc.
+func (m *MockAdminService) MailSettings() (*nessie.MailSettings, error) {
+	if m.MailSettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.MailSettings")
+	}
+	return m.MailSettingsFunc()
+}
+
+// EditMailSettings calls EditMailSettingsFunc.
+func (m *MockAdminService) EditMailSettings(settings nessie.MailSettings) error {
+	if m.EditMailSettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.EditMailSettings")
+	}
+	return m.EditMailSettingsFunc(settings)
+}
+
+// SendTestEmail calls SendTestEmailFunc.
+func (m *MockAdminService) SendTestEmail(to string) error {
+	if m.SendTestEmailFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.SendTestEmail")
+	}
+	return m.SendTestEmailFunc(to)
+}
+
+// LDAPSettings calls LDAPSettingsFunc.
+func (m *MockAdminService) LDAPSettings() (*nessie.LDAPSettings, error) {
+	if m.LDAPSettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.LDAPSettings")
+	}
+	return m.LDAPSettingsFunc()
+}
+
+// EditLDAPSettings calls EditLDAPSettingsFunc.
+func (m *MockAdminService) EditLDAPSettings(settings nessie.LDAPSettings) error {
+	if m.EditLDAPSettingsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.EditLDAPSettings")
+	}
+	return m.EditLDAPSettingsFunc(settings)
+}
+
+// UpdatePlugins calls UpdatePluginsFunc.
+func (m *MockAdminService) UpdatePlugins() error {
+	if m.UpdatePluginsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.UpdatePlugins")
+	}
+	return m.UpdatePluginsFunc()
+}
+
+// UploadPluginArchive calls UploadPluginArchiveFunc.
+func (m *MockAdminService) UploadPluginArchive(filePath string) error {
+	if m.UploadPluginArchiveFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.UploadPluginArchive")
+	}
+	return m.UploadPluginArchiveFunc(filePath)
+}
+
+// RestartServer calls RestartServerFunc.
+func (m *MockAdminService) RestartServer() error {
+	if m.RestartServerFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.RestartServer")
+	}
+	return m.RestartServerFunc()
+}
+
+// WaitUntilReady calls WaitUntilReadyFunc.
+func (m *MockAdminService) WaitUntilReady(ctx context.Context, progress func(*nessie.ServerStatus)) error {
+	if m.WaitUntilReadyFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.WaitUntilReady")
+	}
+	return m.WaitUntilReadyFunc(ctx, progress)
+}
+
+// Scanners calls ScannersFunc.
+func (m *MockAdminService) Scanners() ([]nessie.Scanner, error) {
+	if m.ScannersFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.Scanners")
+	}
+	return m.ScannersFunc()
+}
+
+// AgentGroups calls AgentGroupsFunc.
+func (m *MockAdminService) AgentGroups() ([]nessie.AgentGroup, error) {
+	if m.AgentGroupsFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.AgentGroups")
+	}
+	return m.AgentGroupsFunc()
+}
+
+// Upload calls UploadFunc.
+func (m *MockAdminService) Upload(filePath string) error {
+	if m.UploadFunc == nil {
+		panic("nessietest: unexpected call to MockAdminService.Upload")
+	}
+	return m.UploadFunc(filePath)
+}
+
+// MockUserService implements nessie.UserService by calling its function fields.
+type MockUserService struct {
+	CreateUserFunc      func(username string, password string, userType string, permissions string, name string, email string) (*nessie.User, error)
+	ListUsersFunc       func() ([]nessie.User, error)
+	DeleteUserFunc      func(userID int) error
+	SetUserPasswordFunc func(userID int, password string) error
+	EditUserFunc        func(userID int, permissions string, name string, email string) (*nessie.User, error)
+	PermissionsFunc     func(objectType string, objectID int64) ([]nessie.Permission, error)
+	SetPermissionsFunc  func(objectType string, objectID int64, acls []nessie.Permission) error
+	ListGroupsFunc      func() ([]nessie.Group, error)
+	CreateGroupFunc     func(name string) (nessie.Group, error)
+}
+
+var _ nessie.UserService = &MockUserService{}
+
+// CreateUser calls CreateUserFunc.
+func (m *MockUserService) CreateUser(username string, password string, userType string, permissions string, name string, email string) (*nessie.User, error) {
+	if m.CreateUserFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.CreateUser")
+	}
+	return m.CreateUserFunc(username, password, userType, permissions, name, email)
+}
+
+// ListUsers calls ListUsersFunc.
+func (m *MockUserService) ListUsers() ([]nessie.User, error) {
+	if m.ListUsersFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.ListUsers")
+	}
+	return m.ListUsersFunc()
+}
+
+// DeleteUser calls DeleteUserFunc.
+func (m *MockUserService) DeleteUser(userID int) error {
+	if m.DeleteUserFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.DeleteUser")
+	}
+	return m.DeleteUserFunc(userID)
+}
+
+// SetUserPassword calls SetUserPasswordFunc.
+func (m *MockUserService) SetUserPassword(userID int, password string) error {
+	if m.SetUserPasswordFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.SetUserPassword")
+	}
+	return m.SetUserPasswordFunc(userID, password)
+}
+
+// EditUser calls EditUserFunc.
+func (m *MockUserService) EditUser(userID int, permissions string, name string, email string) (*nessie.User, error) {
+	if m.EditUserFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.EditUser")
+	}
+	return m.EditUserFunc(userID, permissions, name, email)
+}
+
+// Permissions calls PermissionsFunc.
+func (m *MockUserService) Permissions(objectType string, objectID int64) ([]nessie.Permission, error) {
+	if m.PermissionsFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.Permissions")
+	}
+	return m.PermissionsFunc(objectType, objectID)
+}
+
+// SetPermissions calls SetPermissionsFunc.
+func (m *MockUserService) SetPermissions(objectType string, objectID int64, acls []nessie.Permission) error {
+	if m.SetPermissionsFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.SetPermissions")
+	}
+	return m.SetPermissionsFunc(objectType, objectID, acls)
+}
+
+// ListGroups calls ListGroupsFunc.
+func (m *MockUserService) ListGroups() ([]nessie.Group, error) {
+	if m.ListGroupsFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.ListGroups")
+	}
+	return m.ListGroupsFunc()
+}
+
+// CreateGroup calls CreateGroupFunc.
+func (m *MockUserService) CreateGroup(name string) (nessie.Group, error) {
+	if m.CreateGroupFunc == nil {
+		panic("nessietest: unexpected call to MockUserService.CreateGroup")
+	}
+	return m.CreateGroupFunc(name)
+}
+
+// MockPluginService implements nessie.PluginService by calling its function fields.
+type MockPluginService struct {
+	PluginFamiliesFunc func() ([]nessie.PluginFamily, error)
+	FamilyDetailsFunc  func(ID int64) (*nessie.FamilyDetails, error)
+	PluginDetailsFunc  func(ID int64) (*nessie.PluginDetails, error)
+	AllPluginsFunc     func() (chan nessie.PluginDetails, error)
+}
+
+var _ nessie.PluginService = &MockPluginService{}
+
+// PluginFamilies calls PluginFamiliesFunc.
+func (m *MockPluginService) PluginFamilies() ([]nessie.PluginFamily, error) {
+	if m.PluginFamiliesFunc == nil {
+		panic("nessietest: unexpected call to MockPluginService.PluginFamilies")
+	}
+	return m.PluginFamiliesFunc()
+}
+
+// FamilyDetails calls FamilyDetailsFunc.
+func (m *MockPluginService) FamilyDetails(ID int64) (*nessie.FamilyDetails, error) {
+	if m.FamilyDetailsFunc == nil {
+		panic("nessietest: unexpected call to MockPluginService.FamilyDetails")
+	}
+	return m.FamilyDetailsFunc(ID)
+}
+
+// PluginDetails calls PluginDetailsFunc.
+func (m *MockPluginService) PluginDetails(ID int64) (*nessie.PluginDetails, error) {
+	if m.PluginDetailsFunc == nil {
+		panic("nessietest: unexpected call to MockPluginService.PluginDetails")
+	}
+	return m.PluginDetailsFunc(ID)
+}
+
+// AllPlugins calls AllPluginsFunc.
+func (m *MockPluginService) AllPlugins() (chan nessie.PluginDetails, error) {
+	if m.AllPluginsFunc == nil {
+		panic("nessietest: unexpected call to MockPluginService.AllPlugins")
+	}
+	return m.AllPluginsFunc()
+}
+
+// MockPolicyService implements nessie.PolicyService by calling its function fields.
+type MockPolicyService struct {
+	PoliciesFunc        func() ([]nessie.Policy, error)
+	PolicyTemplatesFunc func() ([]nessie.Template, error)
+	CreatePolicyFunc    func(policySettings nessie.CreatePolicyRequest) (nessie.CreatePolicyResp, error)
+	ConfigurePolicyFunc func(id int64, policySettings nessie.CreatePolicyRequest) error
+	DeletePolicyFunc    func(id int64) error
+	ExportPolicyFunc    func(id int64) ([]byte, error)
+	ImportPolicyFunc    func(name string, data []byte) (*nessie.Policy, error)
+}
+
+var _ nessie.PolicyService = &MockPolicyService{}
+
+// Policies calls PoliciesFunc.
+func (m *MockPolicyService) Policies() ([]nessie.Policy, error) {
+	if m.PoliciesFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.Policies")
+	}
+	return m.PoliciesFunc()
+}
+
+// PolicyTemplates calls PolicyTemplatesFunc.
+func (m *MockPolicyService) PolicyTemplates() ([]nessie.Template, error) {
+	if m.PolicyTemplatesFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.PolicyTemplates")
+	}
+	return m.PolicyTemplatesFunc()
+}
+
+// CreatePolicy calls CreatePolicyFunc.
+func (m *MockPolicyService) CreatePolicy(policySettings nessie.CreatePolicyRequest) (nessie.CreatePolicyResp, error) {
+	if m.CreatePolicyFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.CreatePolicy")
+	}
+	return m.CreatePolicyFunc(policySettings)
+}
+
+// ConfigurePolicy calls ConfigurePolicyFunc.
+func (m *MockPolicyService) ConfigurePolicy(id int64, policySettings nessie.CreatePolicyRequest) error {
+	if m.ConfigurePolicyFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.ConfigurePolicy")
+	}
+	return m.ConfigurePolicyFunc(id, policySettings)
+}
+
+// DeletePolicy calls DeletePolicyFunc.
+func (m *MockPolicyService) DeletePolicy(id int64) error {
+	if m.DeletePolicyFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.DeletePolicy")
+	}
+	return m.DeletePolicyFunc(id)
+}
+
+// ExportPolicy calls ExportPolicyFunc.
+func (m *MockPolicyService) ExportPolicy(id int64) ([]byte, error) {
+	if m.ExportPolicyFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.ExportPolicy")
+	}
+	return m.ExportPolicyFunc(id)
+}
+
+// ImportPolicy calls ImportPolicyFunc.
+func (m *MockPolicyService) ImportPolicy(name string, data []byte) (*nessie.Policy, error) {
+	if m.ImportPolicyFunc == nil {
+		panic("nessietest: unexpected call to MockPolicyService.ImportPolicy")
+	}
+	return m.ImportPolicyFunc(name, data)
+}
+
+// MockPluginRuleService implements nessie.PluginRuleService by calling its function fields.
+type MockPluginRuleService struct {
+	PluginRulesFunc      func() ([]nessie.Rule, error)
+	CreatePluginRuleFunc func(rule nessie.Rule) error
+}
+
+var _ nessie.PluginRuleService = &MockPluginRuleService{}
+
+// PluginRules calls PluginRulesFunc.
+func (m *MockPluginRuleService) PluginRules() ([]nessie.Rule, error) {
+	if m.PluginRulesFunc == nil {
+		panic("nessietest: unexpected call to MockPluginRuleService.PluginRules")
+	}
+	return m.PluginRulesFunc()
+}
+
+// CreatePluginRule calls CreatePluginRuleFunc.
+func (m *MockPluginRuleService) CreatePluginRule(rule nessie.Rule) error {
+	if m.CreatePluginRuleFunc == nil {
+		panic("nessietest: unexpected call to MockPluginRuleService.CreatePluginRule")
+	}
+	return m.CreatePluginRuleFunc(rule)
+}
+
+// MockScanService implements nessie.ScanService by calling its function fields.
+type MockScanService struct {
+	NewScanFunc             func(editorTmplUUID string, settingsName string, outputFolderID int64, policyID int64, scannerID int64, launch string, targets []string) (*nessie.Scan, error)
+	CreateScanFunc          func(newScanRequest nessie.NewScanRequest) (*nessie.Scan, error)
+	ScansFunc               func() (*nessie.ListScansResponse, error)
+	ScansSinceFunc          func(ctx context.Context, since nessie.Timestamp) (*nessie.ListScansResponse, error)
+	ScansInFolderFunc       func(folderID int64) (*nessie.ListScansResponse, error)
+	ScanTemplatesFunc       func() ([]nessie.Template, error)
+	StartScanFunc           func(scanID int64) (string, error)
+	PauseScanFunc           func(scanID int64) error
+	ResumeScanFunc          func(scanID int64) error
+	StopScanFunc            func(scanID int64) error
+	DeleteScanFunc          func(scanID int64) error
+	ScanDetailsFunc         func(scanID int64) (*nessie.ScanDetailsResp, error)
+	ScanDetailsFilteredFunc func(scanID int64, filters *nessie.FilterSet) (*nessie.ScanDetailsResp, error)
+	ScanHistoryDetailsFunc  func(scanID int64, historyID int64) (*nessie.ScanDetailsResp, error)
+	ConfigureScanFunc       func(scanID int64, scanSetting nessie.NewScanRequest) (*nessie.Scan, error)
+	ImportScanFunc          func(name string, data []byte, folderID int64) (*nessie.Scan, error)
+	HostDetailsFunc         func(scanID int64, hostID int64) (*nessie.HostDetailsResp, error)
+	HostHistoryDetailsFunc  func(scanID int64, hostID int64, historyID int64) (*nessie.HostDetailsResp, error)
+	PluginOutputFunc        func(scanID int64, hostID int64, pluginID int64, historyID int64) (*nessie.PluginOutputResp, error)
+	TimezonesFunc           func() ([]nessie.TimeZone, error)
+}
+
+var _ nessie.ScanService = &MockScanService{}
+
+// NewScan calls NewScanFunc.
+func (m *MockScanService) NewScan(editorTmplUUID string, settingsName string, outputFolderID int64, policyID int64, scannerID int64, launch string, targets []string) (*nessie.Scan, error) {
+	if m.NewScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.NewScan")
+	}
+	return m.NewScanFunc(editorTmplUUID, settingsName, outputFolderID, policyID, scannerID, launch, targets)
+}
+
+// CreateScan calls CreateScanFunc.
+func (m *MockScanService) CreateScan(newScanRequest nessie.NewScanRequest) (*nessie.Scan, error) {
+	if m.CreateScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.CreateScan")
+	}
+	return m.CreateScanFunc(newScanRequest)
+}
+
+// Scans calls ScansFunc.
+func (m *MockScanService) Scans() (*nessie.ListScansResponse, error) {
+	if m.ScansFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.Scans")
+	}
+	return m.ScansFunc()
+}
+
+// ScansSince calls ScansSinceFunc.
+func (m *MockScanService) ScansSince(ctx context.Context, since nessie.Timestamp) (*nessie.ListScansResponse, error) {
+	if m.ScansSinceFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ScansSince")
+	}
+	return m.ScansSinceFunc(ctx, since)
+}
+
+// ScansInFolder calls ScansInFolderFunc.
+func (m *MockScanService) ScansInFolder(folderID int64) (*nessie.ListScansResponse, error) {
+	if m.ScansInFolderFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ScansInFolder")
+	}
+	return m.ScansInFolderFunc(folderID)
+}
+
+// ScanTemplates calls ScanTemplatesFunc.
+func (m *MockScanService) ScanTemplates() ([]nessie.Template, error) {
+	if m.ScanTemplatesFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ScanTemplates")
+	}
+	return m.ScanTemplatesFunc()
+}
+
+// StartScan calls StartScanFunc.
+func (m *MockScanService) StartScan(scanID int64) (string, error) {
+	if m.StartScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.StartScan")
+	}
+	return m.StartScanFunc(scanID)
+}
+
+// PauseScan calls PauseScanFunc.
+func (m *MockScanService) PauseScan(scanID int64) error {
+	if m.PauseScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.PauseScan")
+	}
+	return m.PauseScanFunc(scanID)
+}
+
+// ResumeScan calls ResumeScanFunc.
+func (m *MockScanService) ResumeScan(scanID int64) error {
+	if m.ResumeScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ResumeScan")
+	}
+	return m.ResumeScanFunc(scanID)
+}
+
+// StopScan calls StopScanFunc.
+func (m *MockScanService) StopScan(scanID int64) error {
+	if m.StopScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.StopScan")
+	}
+	return m.StopScanFunc(scanID)
+}
+
+// DeleteScan calls DeleteScanFunc.
+func (m *MockScanService) DeleteScan(scanID int64) error {
+	if m.DeleteScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.DeleteScan")
+	}
+	return m.DeleteScanFunc(scanID)
+}
+
+// ScanDetails calls ScanDetailsFunc.
+func (m *MockScanService) ScanDetails(scanID int64) (*nessie.ScanDetailsResp, error) {
+	if m.ScanDetailsFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ScanDetails")
+	}
+	return m.ScanDetailsFunc(scanID)
+}
+
+// ScanDetailsFiltered calls ScanDetailsFilteredFunc.
+func (m *MockScanService) ScanDetailsFiltered(scanID int64, filters *nessie.FilterSet) (*nessie.ScanDetailsResp, error) {
+	if m.ScanDetailsFilteredFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ScanDetailsFiltered")
+	}
+	return m.ScanDetailsFilteredFunc(scanID, filters)
+}
+
+// ScanHistoryDetails calls ScanHistoryDetailsFunc.
+func (m *MockScanService) ScanHistoryDetails(scanID int64, historyID int64) (*nessie.ScanDetailsResp, error) {
+	if m.ScanHistoryDetailsFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ScanHistoryDetails")
+	}
+	return m.ScanHistoryDetailsFunc(scanID, historyID)
+}
+
+// ConfigureScan calls ConfigureScanFunc.
+func (m *MockScanService) ConfigureScan(scanID int64, scanSetting nessie.NewScanRequest) (*nessie.Scan, error) {
+	if m.ConfigureScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ConfigureScan")
+	}
+	return m.ConfigureScanFunc(scanID, scanSetting)
+}
+
+// ImportScan calls ImportScanFunc.
+func (m *MockScanService) ImportScan(name string, data []byte, folderID int64) (*nessie.Scan, error) {
+	if m.ImportScanFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.ImportScan")
+	}
+	return m.ImportScanFunc(name, data, folderID)
+}
+
+// HostDetails calls HostDetailsFunc.
+func (m *MockScanService) HostDetails(scanID int64, hostID int64) (*nessie.HostDetailsResp, error) {
+	if m.HostDetailsFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.HostDetails")
+	}
+	return m.HostDetailsFunc(scanID, hostID)
+}
+
+// HostHistoryDetails calls HostHistoryDetailsFunc.
+func (m *MockScanService) HostHistoryDetails(scanID int64, hostID int64, historyID int64) (*nessie.HostDetailsResp, error) {
+	if m.HostHistoryDetailsFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.HostHistoryDetails")
+	}
+	return m.HostHistoryDetailsFunc(scanID, hostID, historyID)
+}
+
+// PluginOutput calls PluginOutputFunc.
+func (m *MockScanService) PluginOutput(scanID int64, hostID int64, pluginID int64, historyID int64) (*nessie.PluginOutputResp, error) {
+	if m.PluginOutputFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.PluginOutput")
+	}
+	return m.PluginOutputFunc(scanID, hostID, pluginID, historyID)
+}
+
+// Timezones calls TimezonesFunc.
+func (m *MockScanService) Timezones() ([]nessie.TimeZone, error) {
+	if m.TimezonesFunc == nil {
+		panic("nessietest: unexpected call to MockScanService.Timezones")
+	}
+	return m.TimezonesFunc()
+}
+
+// MockFolderService implements nessie.FolderService by calling its function fields.
+type MockFolderService struct {
+	FoldersFunc      func() ([]nessie.Folder, error)
+	CreateFolderFunc func(name string) error
+	EditFolderFunc   func(folderID int64, newName string) error
+	DeleteFolderFunc func(folderID int64) error
+}
+
+var _ nessie.FolderService = &MockFolderService{}
+
+// Folders calls FoldersFunc.
+func (m *MockFolderService) Folders() ([]nessie.Folder, error) {
+	if m.FoldersFunc == nil {
+		panic("nessietest: unexpected call to MockFolderService.Folders")
+	}
+	return m.FoldersFunc()
+}
+
+// CreateFolder calls CreateFolderFunc.
+func (m *MockFolderService) CreateFolder(name string) error {
+	if m.CreateFolderFunc == nil {
+		panic("nessietest: unexpected call to MockFolderService.CreateFolder")
+	}
+	return m.CreateFolderFunc(name)
+}
+
+// EditFolder calls EditFolderFunc.
+func (m *MockFolderService) EditFolder(folderID int64, newName string) error {
+	if m.EditFolderFunc == nil {
+		panic("nessietest: unexpected call to MockFolderService.EditFolder")
+	}
+	return m.EditFolderFunc(folderID, newName)
+}
+
+// DeleteFolder calls DeleteFolderFunc.
+func (m *MockFolderService) DeleteFolder(folderID int64) error {
+	if m.DeleteFolderFunc == nil {
+		panic("nessietest: unexpected call to MockFolderService.DeleteFolder")
+	}
+	return m.DeleteFolderFunc(folderID)
+}
+
+// MockExportService implements nessie.ExportService by calling its function fields.
+type MockExportService struct {
+	ExportScanFunc            func(scanID int64, templateID int64, format string) (int64, error)
+	ExportScanWithOptionsFunc func(scanID int64, opts nessie.ExportOptions) (int64, error)
+	ExportFinishedFunc        func(scanID int64, exportID int64) (bool, error)
+	DownloadExportFunc        func(scanID int64, exportID int64) ([]byte, error)
+}
+
+var _ nessie.ExportService = &MockExportService{}
+
+// ExportScan calls ExportScanFunc.
+func (m *MockExportService) ExportScan(scanID int64, templateID int64, format string) (int64, error) {
+	if m.ExportScanFunc == nil {
+		panic("nessietest: unexpected call to MockExportService.ExportScan")
+	}
+	return m.ExportScanFunc(scanID, templateID, format)
+}
+
+// ExportScanWithOptions calls ExportScanWithOptionsFunc.
+func (m *MockExportService) ExportScanWithOptions(scanID int64, opts nessie.ExportOptions) (int64, error) {
+	if m.ExportScanWithOptionsFunc == nil {
+		panic("nessietest: unexpected call to MockExportService.ExportScanWithOptions")
+	}
+	return m.ExportScanWithOptionsFunc(scanID, opts)
+}
+
+// ExportFinished calls ExportFinishedFunc.
+func (m *MockExportService) ExportFinished(scanID int64, exportID int64) (bool, error) {
+	if m.ExportFinishedFunc == nil {
+		panic("nessietest: unexpected call to MockExportService.ExportFinished")
+	}
+	return m.ExportFinishedFunc(scanID, exportID)
+}
+
+// DownloadExport calls DownloadExportFunc.
+func (m *MockExportService) DownloadExport(scanID int64, exportID int64) ([]byte, error) {
+	if m.DownloadExportFunc == nil {
+		panic("nessietest: unexpected call to MockExportService.DownloadExport")
+	}
+	return m.DownloadExportFunc(scanID, exportID)
+}
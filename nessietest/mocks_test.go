@@ -0,0 +1,35 @@
+package nessietest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+)
+
+func TestMockScanService(t *testing.T) {
+	var since []int64
+	scans := &MockScanService{
+		ScansSinceFunc: func(ctx context.Context, ts nessie.Timestamp) (*nessie.ListScansResponse, error) {
+			since = append(since, ts.Epoch())
+			return &nessie.ListScansResponse{Timestamp: nessie.UnixTimestamp(100)}, nil
+		},
+	}
+	c := nessie.NewScanCursor(scans)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Next(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(since) != 2 || since[0] != 0 || since[1] != 100 {
+		t.Errorf("wrong calls %v", since)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("calling a method without function should panic")
+		}
+	}()
+	var n nessie.Nessus = &MockNessus{MockScanService: *scans}
+	n.StartScan(42)
+}
@@ -0,0 +1,319 @@
+package nessietest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/JerusJ/nessie"
+)
+
+// Redacted replaces the secrets of recorded interactions.
+const Redacted = "REDACTED"
+
+// redactedAPIToken replaces the API token served in nessus6.js, it must still
+// look like a token for the client to find it.
+const redactedAPIToken = "00000000-0000-0000-0000-000000000000"
+
+// redactedHeaders carry credentials.
+var redactedHeaders = []string{"X-Cookie", "X-Apikeys", "X-Api-Token", "Cookie", "Set-Cookie", "Authorization"}
+
+// redactedFields are the JSON fields and query parameters carrying
+// credentials, at any depth.
+var redactedFields = map[string]bool{
+	"token":          true,
+	"password":       true,
+	"accessKey":      true,
+	"secretKey":      true,
+	"access_key":     true,
+	"secret_key":     true,
+	"api_token":      true,
+	"smtp_pass":      true,
+	"proxy_password": true,
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request without its host, so that fixtures recorded
+// against a server can be replayed on any address.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response as received from the server.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is stored as a string when it is valid UTF-8 and base64 encoded
+// otherwise, e.g. for PDF or DB exports.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string][]byte{"base64": b})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var encoded map[string][]byte
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	*b = encoded["base64"]
+	return nil
+}
+
+// Recorder is an http.RoundTripper recording the interactions with a real
+// server, with their credentials redacted, to replay them with a Replayer.
+//
+//	rec := nessietest.NewRecorder(http.DefaultTransport)
+//	n, _ := nessie.NewNessusWithHTTPClient(url, &http.Client{Transport: rec})
+//	...
+//	rec.Save("testdata/scan_lifecycle.json")
+type Recorder struct {
+	transport    http.RoundTripper
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder returns a recorder sending the requests with transport, or
+// http.DefaultTransport when nil.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{transport: transport}
+}
+
+// RoundTrip sends the request and records it with its response.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	recorded := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redactURI(req.URL),
+			Header: redactHeader(req.Header),
+			Body:   redactBody(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       redactBody(respBody),
+		},
+	}
+	if req.URL.Path == "/"+nessie.NessusApiTokenPath {
+		recorded.Response.Body = Body(nessie.NessusAPITokenRegex.ReplaceAllString(string(respBody), redactedAPIToken))
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, recorded)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to a fixture file.
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func redactHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	redacted := h.Clone()
+	// Redacted bodies do not have their original length.
+	redacted.Del("Content-Length")
+	for _, name := range redactedHeaders {
+		if _, ok := redacted[name]; ok {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+// redactURI returns the request URI with the values of the query parameters
+// carrying credentials redacted, keeping the order of the parameters.
+func redactURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && redactedFields[name] {
+			params[i] = key + "=" + Redacted
+		}
+	}
+	redacted := *u
+	redacted.RawQuery = strings.Join(params, "&")
+	return redacted.RequestURI()
+}
+
+// redactBody replaces the credentials of JSON bodies, other bodies are kept
+// as is.
+func redactBody(body []byte) Body {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	if !redactValue(v) {
+		return body
+	}
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// redactValue redacts v in place and reports whether anything was redacted.
+func redactValue(v interface{}) bool {
+	var redacted bool
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if redactedFields[k] {
+				if s, ok := field.(string); ok && s != "" {
+					v[k] = Redacted
+					redacted = true
+				}
+				continue
+			}
+			redacted = redactValue(field) || redacted
+		}
+	case []interface{}:
+		for _, item := range v {
+			redacted = redactValue(item) || redacted
+		}
+	}
+	return redacted
+}
+
+// Replayer is an http.RoundTripper serving the interactions recorded by a
+// Recorder. Requests are matched on their method and URL, in the order they
+// were recorded, so that polling the same resource replays its successive
+// states.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a replayer serving the interactions of a fixture file.
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("cannot read fixture %s: %v", path, err)
+	}
+	return NewReplayerFromInteractions(interactions), nil
+}
+
+// NewReplayerFromInteractions returns a replayer serving the given interactions.
+func NewReplayerFromInteractions(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+// RoundTrip returns the first unused response recorded for the request, and
+// an error if there is none. Query parameters carrying credentials are not
+// matched, as they are redacted when recording.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	uri := redactURI(req.URL)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, recorded := range r.interactions {
+		if r.used[i] || recorded.Request.Method != req.Method || recorded.Request.URL != uri {
+			continue
+		}
+		r.used[i] = true
+		header := recorded.Response.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+			StatusCode:    recorded.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(recorded.Response.Body)),
+			ContentLength: int64(len(recorded.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response left for %s %s", req.Method, uri)
+}
+
+// Unused returns the recorded requests that were not replayed, e.g. to check
+// that a regression suite still exercises its whole fixture.
+func (r *Replayer) Unused() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []string
+	for i, recorded := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, strings.Join([]string{recorded.Request.Method, recorded.Request.URL}, " "))
+		}
+	}
+	return unused
+}
@@ -0,0 +1,172 @@
+package nessietest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+)
+
+var update = flag.Bool("update", false, "record the fixtures again from a fake server")
+
+const scanFixture = "testdata/scan_lifecycle.json"
+
+// scanScenario launches a scan, waits for it and exports it, returning what
+// it observed.
+func scanScenario(t *testing.T, n nessie.Nessus) []string {
+	if err := n.Login(DefaultUsername, DefaultPassword); err != nil {
+		t.Fatalf("cannot login: %v", err)
+	}
+	scan, err := n.NewScan(BasicTemplateUUID, "weekly", MyScansFolderID, 0, 1, nessie.LaunchOnDemand, []string{"192.0.2.10"})
+	if err != nil {
+		t.Fatalf("cannot create scan: %v", err)
+	}
+	if _, err := n.StartScan(scan.ID); err != nil {
+		t.Fatalf("cannot launch scan: %v", err)
+	}
+	var observed []string
+	for i := 0; i < 2; i++ {
+		details, err := n.ScanDetails(scan.ID)
+		if err != nil {
+			t.Fatalf("cannot get scan details: %v", err)
+		}
+		observed = append(observed, fmt.Sprintf("%s %d hosts", details.Info.Status, len(details.Hosts)))
+	}
+	exportID, err := n.ExportScan(scan.ID, 0, nessie.ExportCSV)
+	if err != nil {
+		t.Fatalf("cannot export scan: %v", err)
+	}
+	for ready := false; !ready; {
+		if ready, err = n.ExportFinished(scan.ID, exportID); err != nil {
+			t.Fatalf("cannot get export status: %v", err)
+		}
+	}
+	report, err := n.DownloadExport(scan.ID, exportID)
+	if err != nil {
+		t.Fatalf("cannot download export: %v", err)
+	}
+	observed = append(observed, strings.SplitN(string(report), ",", 2)[0])
+	if err := n.Logout(); err != nil {
+		t.Fatalf("cannot logout: %v", err)
+	}
+	return observed
+}
+
+func TestRecordReplay(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	rec := NewRecorder(nil)
+	n, err := nessie.NewNessusWithHTTPClient(s.URL, &http.Client{Transport: rec})
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	recorded := scanScenario(t, n)
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if *update {
+		path = scanFixture
+	}
+	if err := rec.Save(path); err != nil {
+		t.Fatalf("cannot save fixture: %v", err)
+	}
+	fixture, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{`"password":"` + DefaultPassword, "token-", APIToken} {
+		if strings.Contains(string(fixture), secret) {
+			t.Errorf("fixture leaks %q:\n%s", secret, fixture)
+		}
+	}
+
+	replay, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("cannot load fixture: %v", err)
+	}
+	n, err = nessie.NewNessusWithHTTPClient("http://replay.invalid", &http.Client{Transport: replay})
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	if replayed := scanScenario(t, n); fmt.Sprint(replayed) != fmt.Sprint(recorded) {
+		t.Errorf("replay differs, got=%v want=%v", replayed, recorded)
+	}
+	if unused := replay.Unused(); len(unused) != 0 {
+		t.Errorf("requests were not replayed: %v", unused)
+	}
+	if _, err := n.Scans(); err == nil {
+		t.Error("requests that were not recorded should fail")
+	}
+}
+
+func TestReplayFixture(t *testing.T) {
+	replay, err := NewReplayer(scanFixture)
+	if err != nil {
+		t.Fatalf("cannot load fixture: %v", err)
+	}
+	n, err := nessie.NewNessusWithHTTPClient("http://replay.invalid", &http.Client{Transport: replay})
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	want := []string{"running 0 hosts", "completed 1 hosts", "Plugin ID"}
+	if got := scanScenario(t, n); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("wrong replay, got=%v want=%v", got, want)
+	}
+}
+
+func TestBody(t *testing.T) {
+	for _, body := range []Body{Body(`{"status":"ready"}`), {0x25, 0x50, 0x44, 0x46, 0xff, 0xfe}} {
+		data, err := body.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Body
+		if err := got.UnmarshalJSON(data); err != nil || string(got) != string(body) {
+			t.Errorf("wrong round trip of %q through %s, got %q: %v", body, data, got, err)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	var tests = []struct {
+		body, want string
+	}{
+		{`{"smtp_host":"smtp.local","smtp_pass":"s3cret"}`, `{"smtp_host":"smtp.local","smtp_pass":"REDACTED"}`},
+		{`{"proxy":"proxy.local","proxy_password":"s3cret"}`, `{"proxy":"proxy.local","proxy_password":"REDACTED"}`},
+		{`{"users":[{"password":"s3cret"}]}`, `{"users":[{"password":"REDACTED"}]}`},
+		{`{"smtp_pass":""}`, `{"smtp_pass":""}`},
+	}
+	for _, tt := range tests {
+		if got := string(redactBody([]byte(tt.body))); got != tt.want {
+			t.Errorf("wrong redacted body of %s, got=%s want=%s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	rec := NewRecorder(nil)
+	client := &http.Client{Transport: rec}
+	resp, err := client.Get(s.URL + "/scans/1/export/2/download?token=abc123&chapters=vuln_by_host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	recorded := rec.Interactions()[0].Request.URL
+	if want := "/scans/1/export/2/download?token=REDACTED&chapters=vuln_by_host"; recorded != want {
+		t.Errorf("wrong recorded url, got=%s want=%s", recorded, want)
+	}
+
+	// Requests with other credentials replay the recorded response.
+	replay := NewReplayerFromInteractions(rec.Interactions())
+	resp, err = (&http.Client{Transport: replay}).Get("http://replay.invalid/scans/1/export/2/download?token=def456&chapters=vuln_by_host")
+	if err != nil {
+		t.Fatalf("cannot replay: %v", err)
+	}
+	resp.Body.Close()
+}
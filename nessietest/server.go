@@ -0,0 +1,1138 @@
+// Package nessietest provides an in-memory fake Nessus server to test code
+// using the nessie client without a real scanner.
+//
+// The server keeps the state of sessions, users, groups, permissions, folders,
+// policies, plugins, plugin rules, uploaded files, scans and exports.
+// Launched scans go through a simulated lifecycle: they run for a number of
+// polls of the scans list or details, then complete with the results set by
+// SetScanResults. Errors, latency and loading phases can be injected to test
+// retries.
+package nessietest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+// Credentials of the administrator created with the server.
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "admin"
+)
+
+// Folders created with the server.
+const (
+	TrashFolderID   int64 = 2
+	MyScansFolderID int64 = 3
+)
+
+// Template of the scans and policies offered by the server.
+const (
+	BasicTemplateUUID = "731a8e52-3ea6-a291-ec0a-d2ff0619c19d7bd788d6be818b65"
+	// APIToken is the X-API-Token advertised by the server.
+	APIToken = "6A5A8D8F-6A3D-4A8E-9F1D-2F3B3C0D9E11"
+)
+
+type user struct {
+	nessie.User
+	password string
+}
+
+type run struct {
+	history nessie.History
+	hosts   []nessie.Host
+	vulns   []nessie.Vulnerability
+}
+
+type scan struct {
+	nessie.Scan
+	targets string
+	// polls is the number of polls left before a running scan completes.
+	polls int
+	hosts []nessie.Host
+	vulns []nessie.Vulnerability
+	runs  []*run
+}
+
+type export struct {
+	scanID  int64
+	format  string
+	polls   int
+	content []byte
+}
+
+type fault struct {
+	method  string
+	pattern string
+	status  int
+	// count is the number of requests left to fail, negative fails forever.
+	count int
+}
+
+// Server is a fake Nessus server listening on a local address, see URL.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	now       func() time.Time
+	lastID    int64
+	users     map[int]*user
+	sessions  map[string]int
+	accessKey string
+	secretKey string
+	folders   map[int64]*nessie.Folder
+	policies  map[int64]*nessie.Policy
+	families  map[int64]*nessie.FamilyDetails
+	plugins   map[int64]*nessie.PluginDetails
+	scans     map[int64]*scan
+	exports   map[int64]*export
+	// permissions are keyed by "<object type>/<object id>".
+	permissions map[string][]nessie.Permission
+	groups      map[int64]*nessie.Group
+	rules       map[int64]*nessie.Rule
+	// files are the uploaded files by the name they are stored under.
+	files      map[string][]byte
+	properties nessie.ServerProperties
+	scanPolls  int
+	latency    time.Duration
+	loading    int
+	loadingLen int
+	faults     []*fault
+}
+
+// NewServer starts a fake server with an administrator, the default folders
+// and no scans. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		now:      time.Now,
+		lastID:   MyScansFolderID,
+		users:    make(map[int]*user),
+		sessions: make(map[string]int),
+		folders: map[int64]*nessie.Folder{
+			TrashFolderID:   {ID: TrashFolderID, Name: "Trash", Type: "trash"},
+			MyScansFolderID: {ID: MyScansFolderID, Name: "My Scans", Type: "main", DefaultTag: 1},
+		},
+		policies:    make(map[int64]*nessie.Policy),
+		families:    make(map[int64]*nessie.FamilyDetails),
+		plugins:     make(map[int64]*nessie.PluginDetails),
+		scans:       make(map[int64]*scan),
+		exports:     make(map[int64]*export),
+		permissions: make(map[string][]nessie.Permission),
+		groups:      make(map[int64]*nessie.Group),
+		rules:       make(map[int64]*nessie.Rule),
+		files:       make(map[string][]byte),
+		properties: nessie.ServerProperties{
+			NessusType:      "Nessus Professional",
+			NessusUIVersion: "8.13.1",
+			ServerVersion:   "8.13.1",
+			Feed:            "ProFeed",
+			LoadedPluginSet: "202101021504",
+			ServerUUID:      "7b9ab2a8-2d2a-4e4b-8c5a-bc4f3a9e0c1d",
+		},
+		scanPolls: 2,
+	}
+	s.users[1] = &user{
+		User:     nessie.User{ID: 1, Username: DefaultUsername, Permissions: 128, Type: nessie.UserTypeLocal},
+		password: DefaultPassword,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a client of the server logged in as the administrator,
+// failing the test when it cannot be created or cannot log in.
+func (s *Server) Client(t testing.TB) nessie.Nessus {
+	t.Helper()
+	n, err := nessie.NewInsecureNessus(s.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	if err := n.Login(DefaultUsername, DefaultPassword); err != nil {
+		t.Fatalf("cannot login: %v", err)
+	}
+	return n
+}
+
+// SetClock replaces the clock used for creation, modification and scan times.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetAPIKeys accepts the given API keys for the administrator.
+func (s *Server) SetAPIKeys(accessKey, secretKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessKey, s.secretKey = accessKey, secretKey
+}
+
+// SetProperties replaces the properties served by /server/properties.
+func (s *Server) SetProperties(p nessie.ServerProperties) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.properties = p
+}
+
+// SetScanDuration sets the number of polls of the scans list or details a
+// launched scan runs for before completing, 2 by default.
+func (s *Server) SetScanDuration(polls int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanPolls = polls
+}
+
+// SetLatency delays every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetLoading makes the next requests fail with 503 as nessus does while it
+// loads its plugins. /server/status reports the loading progress.
+func (s *Server) SetLoading(requests int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loading, s.loadingLen = requests, requests
+}
+
+// InjectError makes the next count requests whose method and path match fail
+// with the given status. The pattern uses the syntax of path.Match, e.g.
+// "/scans/*/launch". An empty method matches every method and a negative
+// count fails until ClearErrors.
+func (s *Server) InjectError(method, pattern string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{method: method, pattern: pattern, status: status, count: count})
+}
+
+// ClearErrors removes the injected errors.
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// AddPlugin adds a plugin to a family, created if it does not exist yet.
+func (s *Server) AddPlugin(family string, plugin nessie.PluginDetails) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plugin.FamilyName = family
+	s.plugins[plugin.ID] = &plugin
+	for _, f := range s.families {
+		if f.Name == family {
+			f.Plugins = append(f.Plugins, plugin.Plugin)
+			return
+		}
+	}
+	id := s.nextID()
+	s.families[id] = &nessie.FamilyDetails{ID: id, Name: family, Plugins: []nessie.Plugin{plugin.Plugin}}
+}
+
+// SetScanResults sets the hosts and vulnerabilities of the next runs of a
+// scan. By default a run finds one host per target and no vulnerability.
+func (s *Server) SetScanResults(scanID int64, hosts []nessie.Host, vulns []nessie.Vulnerability) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.scans[scanID]
+	if !ok {
+		return fmt.Errorf("no scan %d", scanID)
+	}
+	sc.hosts, sc.vulns = hosts, vulns
+	return nil
+}
+
+// Scan returns the current state of a scan.
+func (s *Server) Scan(scanID int64) (nessie.Scan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.scans[scanID]
+	if !ok {
+		return nessie.Scan{}, false
+	}
+	return sc.Scan, true
+}
+
+// CompleteScan completes a running scan without waiting for its polls.
+func (s *Server) CompleteScan(scanID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.scans[scanID]
+	if !ok || sc.Status != "running" {
+		return fmt.Errorf("no running scan %d", scanID)
+	}
+	s.complete(sc)
+	return nil
+}
+
+func (s *Server) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.faults {
+		if f.count == 0 || (f.method != "" && f.method != r.Method) {
+			continue
+		}
+		if ok, _ := path.Match(f.pattern, r.URL.Path); ok {
+			f.count--
+			writeError(w, f.status, "injected error")
+			return
+		}
+	}
+	if r.URL.Path == "/"+nessie.NessusApiTokenPath {
+		fmt.Fprintf(w, `{key:"getApiToken",value:function(){return"%s"}}`, APIToken)
+		return
+	}
+	if s.loading > 0 {
+		s.loading--
+		if r.URL.Path == "/server/status" {
+			progress := int64((s.loadingLen - s.loading - 1) * 100 / s.loadingLen)
+			writeJSON(w, http.StatusServiceUnavailable, &nessie.ServerStatus{Status: nessie.ServerStatusLoading, Progress: progress})
+			return
+		}
+		writeError(w, http.StatusServiceUnavailable, "Nessus is loading")
+		return
+	}
+	switch {
+	case r.URL.Path == "/server/status":
+		writeJSON(w, http.StatusOK, &nessie.ServerStatus{Status: nessie.ServerStatusReady, Progress: 100})
+		return
+	case r.URL.Path == "/server/properties" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, &s.properties)
+		return
+	case r.URL.Path == "/session" && r.Method == "POST":
+		s.login(w, r)
+		return
+	}
+	u, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Invalid Credentials")
+		return
+	}
+	s.route(w, r, u)
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, u := range s.users {
+		if u.Username == req.Username && u.password == req.Password {
+			token := fmt.Sprintf("token-%d", s.nextID())
+			s.sessions[token] = u.ID
+			writeJSON(w, http.StatusOK, map[string]string{"token": token})
+			return
+		}
+	}
+	writeError(w, http.StatusUnauthorized, "Invalid Credentials")
+}
+
+func (s *Server) authenticate(r *http.Request) (*user, bool) {
+	if token := strings.TrimPrefix(r.Header.Get("X-Cookie"), "token="); token != "" {
+		if id, ok := s.sessions[token]; ok {
+			u, ok := s.users[id]
+			return u, ok
+		}
+	}
+	if s.accessKey != "" && r.Header.Get("X-ApiKeys") == fmt.Sprintf("accessKey=%s; secretKey=%s", s.accessKey, s.secretKey) {
+		return s.users[1], true
+	}
+	return nil, false
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, u *user) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	// ids holds the numeric path segments, where they are expected.
+	ids := make([]int64, len(parts))
+	for i := 1; i < len(parts); i++ {
+		ids[i], _ = strconv.ParseInt(parts[i], 10, 64)
+	}
+	route := r.Method + " " + parts[0]
+	for i := 1; i < len(parts); i++ {
+		if ids[i] != 0 {
+			route += "/{id}"
+		} else {
+			route += "/" + parts[i]
+		}
+	}
+
+	switch route {
+	case "GET session":
+		writeJSON(w, http.StatusOK, &nessie.Session{
+			ID: int64(u.ID), Username: u.Username, Name: u.Name, Email: u.Email,
+			Type: u.Type, Perms: int64(u.Permissions),
+		})
+	case "DELETE session":
+		delete(s.sessions, strings.TrimPrefix(r.Header.Get("X-Cookie"), "token="))
+	case "GET scanners":
+		writeJSON(w, http.StatusOK, map[string][]nessie.Scanner{"scanners": {{
+			ID: 1, Name: "Local Scanner", Type: "local", Status: "on",
+			ScanCount: int64(s.runningScans()), LoadedPluginSet: s.properties.LoadedPluginSet,
+		}}})
+	case "GET editor/scan/templates", "GET editor/policy/templates":
+		writeJSON(w, http.StatusOK, map[string][]nessie.Template{"templates": {{
+			UUID: BasicTemplateUUID, Name: "basic", Title: "Basic Network Scan",
+		}}})
+
+	case "GET users":
+		users := make([]nessie.User, 0, len(s.users))
+		for _, u := range s.users {
+			users = append(users, u.User)
+		}
+		sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+		writeJSON(w, http.StatusOK, map[string][]nessie.User{"users": users})
+	case "POST users":
+		s.createUser(w, r)
+	case "PUT users/{id}":
+		s.editUser(w, r, int(ids[1]))
+	case "DELETE users/{id}":
+		if _, ok := s.users[int(ids[1])]; !ok {
+			writeError(w, http.StatusNotFound, "User does not exist")
+			return
+		}
+		delete(s.users, int(ids[1]))
+	case "PUT users/{id}/chpasswd":
+		s.setUserPassword(w, r, int(ids[1]))
+
+	case "POST file/upload":
+		s.uploadFile(w, r)
+
+	case "GET groups":
+		groups := make([]nessie.Group, 0, len(s.groups))
+		for _, g := range s.groups {
+			groups = append(groups, *g)
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+		writeJSON(w, http.StatusOK, map[string][]nessie.Group{"groups": groups})
+	case "POST groups":
+		var req struct {
+			Name string `json:"name"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		for _, g := range s.groups {
+			if g.Name == req.Name {
+				writeError(w, http.StatusBadRequest, "Group already exists")
+				return
+			}
+		}
+		g := &nessie.Group{ID: s.nextID(), Name: req.Name, Permissions: 16}
+		s.groups[g.ID] = g
+		writeJSON(w, http.StatusOK, g)
+
+	case "GET plugin-rules":
+		rules := make([]nessie.Rule, 0, len(s.rules))
+		for _, rule := range s.rules {
+			rules = append(rules, *rule)
+		}
+		sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+		writeJSON(w, http.StatusOK, map[string][]nessie.Rule{"plugin_rules": rules})
+	case "POST plugin-rules":
+		var req nessie.Rule
+		if !decode(w, r, &req) {
+			return
+		}
+		if req.PluginID == 0 || req.Type == "" {
+			writeError(w, http.StatusBadRequest, "Invalid plugin rule")
+			return
+		}
+		req.ID, req.Owner, req.OwnerID = s.nextID(), u.Username, int64(u.ID)
+		s.rules[req.ID] = &req
+
+	case "GET folders":
+		writeJSON(w, http.StatusOK, map[string][]nessie.Folder{"folders": s.listFolders()})
+	case "POST folders":
+		var req struct {
+			Name string `json:"name"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		id := s.nextID()
+		s.folders[id] = &nessie.Folder{ID: id, Name: req.Name, Type: "custom", Custom: 1}
+		writeJSON(w, http.StatusOK, map[string]int64{"id": id})
+	case "PUT folders/{id}":
+		f, ok := s.folders[ids[1]]
+		if !ok || f.Custom == 0 {
+			writeError(w, http.StatusForbidden, "Cannot edit folder")
+			return
+		}
+		var req struct {
+			Name string `json:"name"`
+		}
+		if decode(w, r, &req) {
+			f.Name = req.Name
+		}
+	case "DELETE folders/{id}":
+		f, ok := s.folders[ids[1]]
+		if !ok || f.Custom == 0 {
+			writeError(w, http.StatusForbidden, "Cannot delete folder")
+			return
+		}
+		// Scans of a deleted folder go to the trash.
+		for _, sc := range s.scans {
+			if sc.FolderID == f.ID {
+				sc.FolderID = TrashFolderID
+				sc.ContainerID = int(TrashFolderID)
+			}
+		}
+		delete(s.folders, f.ID)
+
+	case "GET policies":
+		policies := make([]nessie.Policy, 0, len(s.policies))
+		for _, p := range s.policies {
+			policies = append(policies, *p)
+		}
+		sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+		writeJSON(w, http.StatusOK, map[string][]nessie.Policy{"policies": policies})
+	case "POST policies":
+		var req nessie.CreatePolicyRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		now := nessie.NewTimestamp(s.now())
+		p := &nessie.Policy{
+			ID: s.nextID(), TemplateUUID: req.UUID, Name: req.Settings.Name, Desc: req.Settings.Description,
+			OwnerID: int64(u.ID), Owner: u.Username, CreationDate: now, LastModificationDate: now,
+		}
+		s.policies[p.ID] = p
+		writeJSON(w, http.StatusOK, &nessie.CreatePolicyResp{PolicyID: p.ID, PolicyName: p.Name})
+	case "PUT policies/{id}":
+		p, ok := s.policies[ids[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Policy does not exist")
+			return
+		}
+		var req nessie.CreatePolicyRequest
+		if decode(w, r, &req) {
+			p.Name, p.Desc, p.LastModificationDate = req.Settings.Name, req.Settings.Description, nessie.NewTimestamp(s.now())
+		}
+	case "GET policies/{id}/export":
+		p, ok := s.policies[ids[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Policy does not exist")
+			return
+		}
+		var f policyFile
+		f.Policy.Name, f.Policy.Comments = p.Name, p.Desc
+		f.Policy.Preferences = []policyPreference{{Name: "template_uuid", Value: p.TemplateUUID}}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(&f)
+	case "POST policies/import":
+		var req struct {
+			File string `json:"file"`
+		}
+		if !decode(w, r, &req) {
+			return
+		}
+		data, ok := s.files[req.File]
+		if !ok {
+			writeError(w, http.StatusNotFound, "File does not exist")
+			return
+		}
+		var f policyFile
+		if err := xml.Unmarshal(data, &f); err != nil || f.Policy.Name == "" {
+			writeError(w, http.StatusBadRequest, "Invalid policy file")
+			return
+		}
+		now := nessie.NewTimestamp(s.now())
+		p := &nessie.Policy{
+			ID: s.nextID(), TemplateUUID: BasicTemplateUUID, Name: f.Policy.Name, Desc: f.Policy.Comments,
+			OwnerID: int64(u.ID), Owner: u.Username, CreationDate: now, LastModificationDate: now,
+		}
+		for _, pref := range f.Policy.Preferences {
+			if pref.Name == "template_uuid" && pref.Value != "" {
+				p.TemplateUUID = pref.Value
+			}
+		}
+		s.policies[p.ID] = p
+		writeJSON(w, http.StatusOK, p)
+	case "DELETE policies/{id}":
+		if _, ok := s.policies[ids[1]]; !ok {
+			writeError(w, http.StatusNotFound, "Policy does not exist")
+			return
+		}
+		delete(s.policies, ids[1])
+
+	case "GET permissions/scan/{id}", "GET permissions/policy/{id}":
+		acls, ok := s.permissions[parts[1]+"/"+parts[2]]
+		if !ok {
+			acls = []nessie.Permission{{Owner: 1, Type: "user", Permissions: 128, ID: int64(u.ID), Name: u.Username}}
+		}
+		writeJSON(w, http.StatusOK, acls)
+	case "PUT permissions/scan/{id}", "PUT permissions/policy/{id}":
+		var req struct {
+			Acls []nessie.Permission `json:"acls"`
+		}
+		if decode(w, r, &req) {
+			s.permissions[parts[1]+"/"+parts[2]] = req.Acls
+		}
+
+	case "GET plugins/families":
+		families := make([]nessie.PluginFamily, 0, len(s.families))
+		for _, f := range s.families {
+			families = append(families, nessie.PluginFamily{ID: f.ID, Name: f.Name, Count: int64(len(f.Plugins))})
+		}
+		sort.Slice(families, func(i, j int) bool { return families[i].ID < families[j].ID })
+		writeJSON(w, http.StatusOK, &nessie.PluginFamilies{Families: families})
+	case "GET plugins/families/{id}":
+		f, ok := s.families[ids[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Family does not exist")
+			return
+		}
+		writeJSON(w, http.StatusOK, f)
+	case "GET plugins/plugin/{id}":
+		p, ok := s.plugins[ids[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Plugin does not exist")
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+
+	case "GET scans":
+		s.listScans(w, r)
+	case "POST scans":
+		s.createScan(w, r, u)
+	case "POST scans/import":
+		s.importScan(w, r, u)
+	default:
+		if len(parts) < 2 || parts[0] != "scans" {
+			writeError(w, http.StatusNotFound, "The requested file was not found")
+			return
+		}
+		sc, ok := s.scans[ids[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "The requested file was not found")
+			return
+		}
+		s.routeScan(w, r, route, sc, ids)
+	}
+}
+
+func (s *Server) routeScan(w http.ResponseWriter, r *http.Request, route string, sc *scan, ids []int64) {
+	switch route {
+	case "GET scans/{id}":
+		s.tick()
+		s.scanDetails(w, r, sc)
+	case "PUT scans/{id}":
+		var req nessie.NewScanRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		s.configure(sc, req.Settings)
+		writeJSON(w, http.StatusOK, &sc.Scan)
+	case "DELETE scans/{id}":
+		if sc.Status == "running" || sc.Status == "paused" {
+			writeError(w, http.StatusConflict, "Scan is running")
+			return
+		}
+		delete(s.scans, sc.ID)
+	case "POST scans/{id}/launch":
+		if sc.Status == "running" || sc.Status == "paused" {
+			writeError(w, http.StatusForbidden, "Scan is already running")
+			return
+		}
+		s.launch(sc)
+		writeJSON(w, http.StatusOK, map[string]string{"scan_uuid": sc.UUID})
+	case "POST scans/{id}/pause":
+		s.transition(w, sc, "running", "paused")
+	case "POST scans/{id}/resume":
+		s.transition(w, sc, "paused", "running")
+	case "POST scans/{id}/stop":
+		if sc.Status == "paused" {
+			s.transition(w, sc, "paused", "canceled")
+			return
+		}
+		s.transition(w, sc, "running", "canceled")
+	case "POST scans/{id}/export":
+		s.exportScan(w, r, sc)
+	case "GET scans/{id}/export/{id}/status":
+		e, ok := s.exports[ids[3]]
+		if !ok || e.scanID != sc.ID {
+			writeError(w, http.StatusNotFound, "The requested file was not found")
+			return
+		}
+		status := "ready"
+		if e.polls > 0 {
+			e.polls--
+			status = "loading"
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": status})
+	case "GET scans/{id}/export/{id}/download":
+		e, ok := s.exports[ids[3]]
+		if !ok || e.scanID != sc.ID || e.polls > 0 {
+			writeError(w, http.StatusNotFound, "The requested file was not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(e.content)
+	default:
+		writeError(w, http.StatusNotFound, "The requested file was not found")
+	}
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		Permissions string `json:"permissions"`
+		Name        string `json:"name"`
+		Email       string `json:"email"`
+		Type        string `json:"type"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	for _, u := range s.users {
+		if u.Username == req.Username {
+			writeError(w, http.StatusConflict, "Duplicate username")
+			return
+		}
+	}
+	perms, err := strconv.Atoi(req.Permissions)
+	if err != nil || req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "Invalid user")
+		return
+	}
+	u := &user{
+		User:     nessie.User{ID: int(s.nextID()), Username: req.Username, Name: req.Name, Email: req.Email, Permissions: perms, Type: req.Type},
+		password: req.Password,
+	}
+	s.users[u.ID] = u
+	writeJSON(w, http.StatusOK, &u.User)
+}
+
+func (s *Server) editUser(w http.ResponseWriter, r *http.Request, id int) {
+	u, ok := s.users[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "User does not exist")
+		return
+	}
+	var req struct {
+		Permissions string `json:"permissions"`
+		Name        string `json:"name"`
+		Email       string `json:"email"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Permissions != "" {
+		perms, err := strconv.Atoi(req.Permissions)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid permissions")
+			return
+		}
+		u.Permissions = perms
+	}
+	if req.Name != "" {
+		u.Name = req.Name
+	}
+	if req.Email != "" {
+		u.Email = req.Email
+	}
+	writeJSON(w, http.StatusOK, &u.User)
+}
+
+func (s *Server) setUserPassword(w http.ResponseWriter, r *http.Request, id int) {
+	u, ok := s.users[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "User does not exist")
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	if decode(w, r, &req) {
+		u.password = req.Password
+	}
+}
+
+func (s *Server) listFolders() []nessie.Folder {
+	folders := make([]nessie.Folder, 0, len(s.folders))
+	for _, f := range s.folders {
+		folders = append(folders, *f)
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].ID < folders[j].ID })
+	return folders
+}
+
+func (s *Server) runningScans() int {
+	var running int
+	for _, sc := range s.scans {
+		if sc.Status == "running" {
+			running++
+		}
+	}
+	return running
+}
+
+// listScans honors the folder_id and last_modification_date parameters.
+func (s *Server) listScans(w http.ResponseWriter, r *http.Request) {
+	s.tick()
+	folderID, _ := strconv.ParseInt(r.URL.Query().Get("folder_id"), 10, 64)
+	since, _ := strconv.ParseInt(r.URL.Query().Get("last_modification_date"), 10, 64)
+	resp := &nessie.ListScansResponse{Folders: s.listFolders(), Scans: []nessie.Scan{}, Timestamp: nessie.NewTimestamp(s.now())}
+	for _, sc := range s.scans {
+		if (folderID != 0 && sc.FolderID != folderID) || sc.LastModificationDate.Epoch() < since {
+			continue
+		}
+		resp.Scans = append(resp.Scans, sc.Scan)
+	}
+	sort.Slice(resp.Scans, func(i, j int) bool { return resp.Scans[i].ID < resp.Scans[j].ID })
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) createScan(w http.ResponseWriter, r *http.Request, u *user) {
+	var req nessie.NewScanRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.UUID != BasicTemplateUUID {
+		writeError(w, http.StatusBadRequest, "Invalid template")
+		return
+	}
+	if req.Settings.Name == "" || req.Settings.TextTargets == "" {
+		writeError(w, http.StatusBadRequest, "Invalid scan settings")
+		return
+	}
+	now := nessie.NewTimestamp(s.now())
+	sc := &scan{Scan: nessie.Scan{
+		ID: s.nextID(), Status: "empty", Owner: u.Username, UserPermissions: 128,
+		CreationDate: now, FolderID: MyScansFolderID, ContainerID: int(MyScansFolderID), Enabled: 1,
+	}}
+	s.configure(sc, req.Settings)
+	s.scans[sc.ID] = sc
+	if req.Settings.LaunchNow {
+		s.launch(sc)
+	}
+	writeJSON(w, http.StatusOK, map[string]*nessie.Scan{"scan": &sc.Scan})
+}
+
+func (s *Server) configure(sc *scan, settings nessie.ScanSettingsRequest) {
+	if settings.Name != "" {
+		sc.Name = settings.Name
+	}
+	if settings.FolderID != 0 {
+		sc.FolderID = settings.FolderID
+		sc.ContainerID = int(settings.FolderID)
+	}
+	if settings.TextTargets != "" {
+		sc.targets = settings.TextTargets
+		sc.CustomTargets = settings.TextTargets
+	}
+	sc.Description = settings.Description
+	sc.PolicyID = int(settings.PolicyID)
+	sc.ScannerID = int(settings.ScannerID)
+	sc.RRules = settings.RRules
+	sc.TimeZone = settings.TimeZone
+	sc.StartTime = nessie.ScanStartTime(settings.StartTime)
+	sc.Emails = settings.Emails
+	sc.FilterType = settings.FilterType
+	sc.NotificationFilters = settings.Filters
+	sc.Enabled = 0
+	if settings.Enabled {
+		sc.Enabled = 1
+	}
+	sc.LastModificationDate = nessie.NewTimestamp(s.now())
+}
+
+func (s *Server) launch(sc *scan) {
+	now := nessie.NewTimestamp(s.now())
+	sc.Status = "running"
+	sc.UUID = fmt.Sprintf("run-%d", s.nextID())
+	sc.polls = s.scanPolls
+	sc.LastModificationDate = now
+	sc.runs = append(sc.runs, &run{history: nessie.History{
+		HistoryID: s.nextID(), UUID: sc.UUID, Status: "running", CreationDate: now, LastModificationDate: now,
+	}})
+}
+
+func (s *Server) transition(w http.ResponseWriter, sc *scan, from, to string) {
+	if sc.Status != from {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Scan is %s", sc.Status))
+		return
+	}
+	sc.Status = to
+	sc.LastModificationDate = nessie.NewTimestamp(s.now())
+	current := sc.runs[len(sc.runs)-1]
+	current.history.Status = to
+	current.history.LastModificationDate = sc.LastModificationDate
+}
+
+// tick advances the running scans by one poll.
+func (s *Server) tick() {
+	for _, sc := range s.scans {
+		if sc.Status != "running" {
+			continue
+		}
+		sc.polls--
+		if sc.polls <= 0 {
+			s.complete(sc)
+		}
+	}
+}
+
+func (s *Server) complete(sc *scan) {
+	sc.Status = "completed"
+	sc.LastModificationDate = nessie.NewTimestamp(s.now())
+	current := sc.runs[len(sc.runs)-1]
+	current.history.Status = "completed"
+	current.history.LastModificationDate = sc.LastModificationDate
+	current.hosts, current.vulns = sc.hosts, sc.vulns
+	if current.hosts == nil {
+		for i, target := range strings.Split(sc.targets, ",") {
+			id := int64(i + 1)
+			current.hosts = append(current.hosts, nessie.Host{HostID: id, HostIdx: id, Hostname: strings.TrimSpace(target), Progress: "100-100/200-200"})
+		}
+	}
+}
+
+func (s *Server) scanDetails(w http.ResponseWriter, r *http.Request, sc *scan) {
+	resp := &nessie.ScanDetailsResp{}
+	resp.Info.Name = sc.Name
+	resp.Info.Status = sc.Status
+	resp.Info.FolderID = sc.FolderID
+	resp.Info.Targets = sc.targets
+	resp.Info.ObjectID = sc.ID
+	resp.Info.UserPerms = sc.UserPermissions
+	resp.Info.EditAllowed = true
+	resp.Info.ScannerName = "Local Scanner"
+	resp.Info.Timestamp = nessie.NewTimestamp(s.now())
+	for _, run := range sc.runs {
+		resp.History = append(resp.History, run.history)
+	}
+
+	var current *run
+	if len(sc.runs) > 0 {
+		current = sc.runs[len(sc.runs)-1]
+	}
+	if historyID, _ := strconv.ParseInt(r.URL.Query().Get("history_id"), 10, 64); historyID != 0 {
+		current = nil
+		for _, run := range sc.runs {
+			if run.history.HistoryID == historyID {
+				current = run
+			}
+		}
+		if current == nil {
+			writeError(w, http.StatusNotFound, "The requested file was not found")
+			return
+		}
+		resp.Info.Status = current.history.Status
+	}
+	if current != nil {
+		resp.UUID = current.history.UUID
+		resp.Info.UUID = current.history.UUID
+		resp.Info.ScanStart = current.history.CreationDate
+		resp.Hosts = current.hosts
+		resp.Vulnerabilities = current.vulns
+		resp.Info.HostCount = int64(len(current.hosts))
+		resp.NumHosts = resp.Info.HostCount
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) exportScan(w http.ResponseWriter, r *http.Request, sc *scan) {
+	var req struct {
+		Format string `json:"format"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	switch req.Format {
+	case nessie.ExportNessus, nessie.ExportCSV, nessie.ExportPDF, nessie.ExportHTML, nessie.ExportDB:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid format")
+		return
+	}
+	if len(sc.runs) == 0 {
+		writeError(w, http.StatusBadRequest, "Scan has no results")
+		return
+	}
+	current := sc.runs[len(sc.runs)-1]
+	if historyID, _ := strconv.ParseInt(r.URL.Query().Get("history_id"), 10, 64); historyID != 0 {
+		current = nil
+		for _, run := range sc.runs {
+			if run.history.HistoryID == historyID {
+				current = run
+			}
+		}
+		if current == nil {
+			writeError(w, http.StatusNotFound, "History does not exist")
+			return
+		}
+	}
+	id := s.nextID()
+	s.exports[id] = &export{scanID: sc.ID, format: req.Format, polls: 1, content: exportContent(sc.Name, current, req.Format)}
+	writeJSON(w, http.StatusOK, map[string]int64{"file": id})
+}
+
+// exportContent returns a minimal .nessus or CSV report of a run of the scan,
+// and a placeholder for the other formats.
+func exportContent(name string, run *run, format string) []byte {
+	hosts := run.hosts
+	var b bytes.Buffer
+	switch format {
+	case nessie.ExportNessus:
+		b.WriteString(`<?xml version="1.0" ?>` + "\n" + `<NessusClientData_v2><Report name="`)
+		xml.EscapeText(&b, []byte(name))
+		b.WriteString(`">`)
+		for _, h := range hosts {
+			b.WriteString(`<ReportHost name="`)
+			xml.EscapeText(&b, []byte(h.Hostname))
+			b.WriteString(`"><HostProperties></HostProperties></ReportHost>`)
+		}
+		b.WriteString("</Report></NessusClientData_v2>\n")
+	case nessie.ExportCSV:
+		b.WriteString("Plugin ID,CVE,CVSS,Risk,Host,Protocol,Port,Name,Synopsis,Description,Solution,See Also,Plugin Output\n")
+	default:
+		fmt.Fprintf(&b, "%s export of scan %q\n", format, name)
+	}
+	return b.Bytes()
+}
+
+// scanFile is the part of a .nessus scan export read by imports.
+type scanFile struct {
+	XMLName xml.Name `xml:"NessusClientData_v2"`
+	Report  struct {
+		Name  string `xml:"name,attr"`
+		Hosts []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"ReportHost"`
+	} `xml:"Report"`
+}
+
+// importScan creates a scan named after the report of an uploaded .nessus
+// file, with a single imported run of its hosts.
+func (s *Server) importScan(w http.ResponseWriter, r *http.Request, u *user) {
+	var req struct {
+		File     string `json:"file"`
+		FolderID int64  `json:"folder_id"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	data, ok := s.files[req.File]
+	if !ok {
+		writeError(w, http.StatusNotFound, "File does not exist")
+		return
+	}
+	var f scanFile
+	if err := xml.Unmarshal(data, &f); err != nil || f.Report.Name == "" {
+		writeError(w, http.StatusBadRequest, "Invalid scan file")
+		return
+	}
+	folderID := MyScansFolderID
+	if req.FolderID != 0 {
+		if _, ok := s.folders[req.FolderID]; !ok {
+			writeError(w, http.StatusNotFound, "Folder does not exist")
+			return
+		}
+		folderID = req.FolderID
+	}
+	now := nessie.NewTimestamp(s.now())
+	sc := &scan{Scan: nessie.Scan{
+		ID: s.nextID(), Name: f.Report.Name, Status: "imported", Owner: u.Username, UserPermissions: 128,
+		CreationDate: now, LastModificationDate: now, FolderID: folderID, ContainerID: int(folderID),
+	}}
+	imported := &run{history: nessie.History{
+		HistoryID: s.nextID(), UUID: fmt.Sprintf("run-%d", s.nextID()), Status: "imported", CreationDate: now, LastModificationDate: now,
+	}}
+	for i, h := range f.Report.Hosts {
+		id := int64(i + 1)
+		imported.hosts = append(imported.hosts, nessie.Host{HostID: id, HostIdx: id, Hostname: h.Name, Progress: "100-100/200-200"})
+	}
+	sc.runs = []*run{imported}
+	sc.UUID = imported.history.UUID
+	s.scans[sc.ID] = sc
+	writeJSON(w, http.StatusOK, map[string]*nessie.Scan{"scan": &sc.Scan})
+}
+
+// policyFile is the part of a .nessus policy file exported and imported by
+// the server.
+type policyFile struct {
+	XMLName xml.Name `xml:"NessusClientData_v2"`
+	Policy  struct {
+		Name        string             `xml:"policyName"`
+		Comments    string             `xml:"policyComments"`
+		Preferences []policyPreference `xml:"Preferences>ServerPreferences>preference"`
+	} `xml:"Policy"`
+}
+
+type policyPreference struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+}
+
+// uploadFile stores the Filedata part, renamed like nessus when the name is
+// taken.
+func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
+	f, header, err := r.FormFile("Filedata")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer f.Close()
+	var data bytes.Buffer
+	if _, err := data.ReadFrom(f); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := header.Filename
+	ext := path.Ext(name)
+	for i := 1; s.files[name] != nil; i++ {
+		name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(header.Filename, ext), i, ext)
+	}
+	s.files[name] = data.Bytes()
+	writeJSON(w, http.StatusOK, map[string]string{"fileuploaded": name})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
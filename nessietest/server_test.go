@@ -0,0 +1,319 @@
+package nessietest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+func TestSession(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	n, err := nessie.NewInsecureNessus(s.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	if _, err := n.Scans(); err == nil {
+		t.Error("listing scans without a session should fail")
+	}
+	if err := n.Login(DefaultUsername, "wrong"); err == nil {
+		t.Error("login with a wrong password should fail")
+	}
+	if err := n.Login(DefaultUsername, DefaultPassword); err != nil {
+		t.Fatalf("cannot login: %v", err)
+	}
+	session, err := n.Session()
+	if err != nil || session.Username != DefaultUsername {
+		t.Errorf("wrong session %+v: %v", session, err)
+	}
+	if err := n.Logout(); err != nil {
+		t.Fatalf("cannot logout: %v", err)
+	}
+
+	s.SetAPIKeys("access", "secret")
+	n, err = nessie.NewInsecureNessusWithAPICredentials(s.URL, "access", "secret")
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	if _, err := n.Scans(); err != nil {
+		t.Errorf("listing scans with API keys should succeed: %v", err)
+	}
+}
+
+func TestUsersFoldersPolicies(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	n := s.Client(t)
+
+	u, err := n.CreateUser("alice", "pass", nessie.UserTypeLocal, nessie.Permissions32, "Alice", "")
+	if err != nil {
+		t.Fatalf("cannot create user: %v", err)
+	}
+	if _, err := n.CreateUser("alice", "pass", nessie.UserTypeLocal, nessie.Permissions32, "", ""); err == nil {
+		t.Error("duplicate user should fail")
+	}
+	if _, err := n.EditUser(u.ID, nessie.Permissions64, "", "alice@example.com"); err != nil {
+		t.Errorf("cannot edit user: %v", err)
+	}
+	if err := n.SetUserPassword(u.ID, "newpass"); err != nil {
+		t.Errorf("cannot set password: %v", err)
+	}
+	alice, err := nessie.NewInsecureNessus(s.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	if err := alice.Login("alice", "newpass"); err != nil {
+		t.Errorf("cannot login with the new password: %v", err)
+	}
+	users, err := n.ListUsers()
+	if err != nil || len(users) != 2 || users[1].Email != "alice@example.com" || users[1].Permissions != 64 {
+		t.Errorf("wrong users %+v: %v", users, err)
+	}
+
+	if err := n.CreateFolder("prod"); err != nil {
+		t.Fatalf("cannot create folder: %v", err)
+	}
+	folders, err := n.Folders()
+	if err != nil || len(folders) != 3 || folders[2].Name != "prod" {
+		t.Fatalf("wrong folders %+v: %v", folders, err)
+	}
+	if err := n.EditFolder(folders[2].ID, "production"); err != nil {
+		t.Errorf("cannot edit folder: %v", err)
+	}
+	if err := n.DeleteFolder(MyScansFolderID); err == nil {
+		t.Error("deleting a default folder should fail")
+	}
+
+	var req nessie.CreatePolicyRequest
+	req.UUID = BasicTemplateUUID
+	req.Settings.Name = "web"
+	created, err := n.CreatePolicy(req)
+	if err != nil || created.PolicyName != "web" {
+		t.Fatalf("wrong policy %+v: %v", created, err)
+	}
+	exported, err := n.ExportPolicy(created.PolicyID)
+	if err != nil {
+		t.Fatalf("cannot export policy: %v", err)
+	}
+	if err := n.DeletePolicy(created.PolicyID); err != nil {
+		t.Errorf("cannot delete policy: %v", err)
+	}
+	imported, err := n.ImportPolicy("web.nessus", exported)
+	if err != nil || imported.Name != "web" || imported.TemplateUUID != BasicTemplateUUID {
+		t.Fatalf("wrong imported policy %+v: %v", imported, err)
+	}
+	if _, err := n.ImportPolicy("junk.nessus", []byte("junk")); err == nil {
+		t.Error("importing an invalid policy file should fail")
+	}
+	created.PolicyID = imported.ID
+	if err := n.DeletePolicy(created.PolicyID); err != nil {
+		t.Errorf("cannot delete policy: %v", err)
+	}
+	if policies, err := n.Policies(); err != nil || len(policies) != 0 {
+		t.Errorf("wrong policies %+v: %v", policies, err)
+	}
+}
+
+func TestGroupsPermissionsRules(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	n := s.Client(t)
+
+	g, err := n.CreateGroup("auditors")
+	if err != nil {
+		t.Fatalf("cannot create group: %v", err)
+	}
+	if _, err := n.CreateGroup("auditors"); err == nil {
+		t.Error("duplicate group should fail")
+	}
+	if groups, err := n.ListGroups(); err != nil || len(groups) != 1 || groups[0].ID != g.ID {
+		t.Errorf("wrong groups %+v: %v", groups, err)
+	}
+
+	acls := []nessie.Permission{{Type: "group", ID: g.ID, Name: g.Name, Permissions: 16}}
+	if err := n.SetPermissions("scan", 42, acls); err != nil {
+		t.Fatalf("cannot set permissions: %v", err)
+	}
+	if got, err := n.Permissions("scan", 42); err != nil || len(got) != 1 || got[0].Name != "auditors" {
+		t.Errorf("wrong permissions %+v: %v", got, err)
+	}
+
+	if err := n.CreatePluginRule(nessie.Rule{PluginID: 19506, Type: "recast_info", Host: "10.0.0.1"}); err != nil {
+		t.Fatalf("cannot create plugin rule: %v", err)
+	}
+	if err := n.CreatePluginRule(nessie.Rule{Host: "10.0.0.1"}); err == nil {
+		t.Error("plugin rule without plugin should fail")
+	}
+	if rules, err := n.PluginRules(); err != nil || len(rules) != 1 || rules[0].Owner != DefaultUsername {
+		t.Errorf("wrong plugin rules %+v: %v", rules, err)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddPlugin("Web Servers", nessie.PluginDetails{Plugin: nessie.Plugin{ID: 142960, Name: "Apache < 2.4.46"}})
+	s.AddPlugin("Web Servers", nessie.PluginDetails{Plugin: nessie.Plugin{ID: 11219, Name: "Nessus SYN scanner"}})
+	n := s.Client(t)
+
+	var got []string
+	plugins, err := n.AllPlugins()
+	if err != nil {
+		t.Fatalf("cannot get plugins: %v", err)
+	}
+	for p := range plugins {
+		got = append(got, p.Name+"/"+p.FamilyName)
+	}
+	if len(got) != 2 {
+		t.Errorf("wrong plugins %v", got)
+	}
+}
+
+func TestScanLifecycle(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	n := s.Client(t)
+
+	scan, err := n.NewScan(BasicTemplateUUID, "weekly", MyScansFolderID, 0, 1, nessie.LaunchOnDemand, []string{"192.0.2.10", "192.0.2.11"})
+	if err != nil {
+		t.Fatalf("cannot create scan: %v", err)
+	}
+	if _, err := n.ExportScan(scan.ID, 0, nessie.ExportNessus); err == nil {
+		t.Error("exporting a scan that never ran should fail")
+	}
+	if _, err := n.StartScan(scan.ID); err != nil {
+		t.Fatalf("cannot launch scan: %v", err)
+	}
+	if err := n.PauseScan(scan.ID); err != nil {
+		t.Fatalf("cannot pause scan: %v", err)
+	}
+	if got, _ := s.Scan(scan.ID); got.Status != "paused" {
+		t.Errorf("scan should be paused, got %q", got.Status)
+	}
+	if err := n.ResumeScan(scan.ID); err != nil {
+		t.Fatalf("cannot resume scan: %v", err)
+	}
+	var statuses []string
+	for i := 0; i < 3; i++ {
+		details, err := n.ScanDetails(scan.ID)
+		if err != nil {
+			t.Fatalf("cannot get scan details: %v", err)
+		}
+		statuses = append(statuses, details.Info.Status)
+	}
+	if strings.Join(statuses, ",") != "running,completed,completed" {
+		t.Errorf("wrong lifecycle %v", statuses)
+	}
+	details, err := n.ScanDetails(scan.ID)
+	if err != nil || len(details.Hosts) != 2 || details.Hosts[1].Hostname != "192.0.2.11" || len(details.History) != 1 {
+		t.Fatalf("wrong results %+v: %v", details, err)
+	}
+
+	// Runs keep the results they completed with.
+	if err := s.SetScanResults(scan.ID, []nessie.Host{{HostID: 1, Hostname: "192.0.2.10", Critical: 2}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.StartScan(scan.ID); err != nil {
+		t.Fatalf("cannot launch scan: %v", err)
+	}
+	if err := s.CompleteScan(scan.ID); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := n.ScanDetails(scan.ID)
+	if err != nil || len(latest.Hosts) != 1 || latest.Hosts[0].Critical != 2 || len(latest.History) != 2 {
+		t.Fatalf("wrong results %+v: %v", latest, err)
+	}
+	previous, err := n.ScanHistoryDetails(scan.ID, latest.History[0].HistoryID)
+	if err != nil || len(previous.Hosts) != 2 {
+		t.Errorf("wrong previous run %+v: %v", previous, err)
+	}
+
+	exportID, err := n.ExportScan(scan.ID, 0, nessie.ExportNessus)
+	if err != nil {
+		t.Fatalf("cannot export scan: %v", err)
+	}
+	if ready, err := n.ExportFinished(scan.ID, exportID); err != nil || ready {
+		t.Errorf("export should still be loading: %v", err)
+	}
+	if ready, err := n.ExportFinished(scan.ID, exportID); err != nil || !ready {
+		t.Errorf("export should be ready: %v", err)
+	}
+	report, err := n.DownloadExport(scan.ID, exportID)
+	if err != nil || !strings.Contains(string(report), `<ReportHost name="192.0.2.10">`) {
+		t.Errorf("wrong export %s: %v", report, err)
+	}
+
+	// Exports of a previous run can be imported as a new scan.
+	exportID, err = n.ExportScanWithOptions(scan.ID, nessie.ExportOptions{Format: nessie.ExportNessus, HistoryID: latest.History[0].HistoryID})
+	if err != nil {
+		t.Fatalf("cannot export previous run: %v", err)
+	}
+	n.ExportFinished(scan.ID, exportID)
+	previousReport, err := n.DownloadExport(scan.ID, exportID)
+	if err != nil || !strings.Contains(string(previousReport), `<ReportHost name="192.0.2.11">`) {
+		t.Errorf("wrong export of previous run %s: %v", previousReport, err)
+	}
+	imported, err := n.ImportScan("weekly.nessus", previousReport, 0)
+	if err != nil || imported.Name != "weekly" || imported.Status != "imported" || imported.FolderID != MyScansFolderID {
+		t.Fatalf("wrong imported scan %+v: %v", imported, err)
+	}
+	if details, err := n.ScanDetails(imported.ID); err != nil || len(details.Hosts) != 2 || len(details.History) != 1 {
+		t.Errorf("wrong imported results %+v: %v", details, err)
+	}
+	if _, err := n.ImportScan("bad.nessus", []byte("<NessusClientData_v2/>"), 0); err == nil {
+		t.Error("importing a file without report should fail")
+	}
+
+	if list, err := n.ScansInFolder(TrashFolderID); err != nil || len(list.Scans) != 0 {
+		t.Errorf("trash should be empty, got %+v: %v", list, err)
+	}
+	if err := n.DeleteScan(scan.ID); err != nil {
+		t.Errorf("cannot delete scan: %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	n := s.Client(t)
+
+	s.InjectError("POST", "/scans/*/launch", http.StatusInternalServerError, 1)
+	scan, err := n.NewScan(BasicTemplateUUID, "daily", MyScansFolderID, 0, 1, nessie.LaunchDaily, []string{"192.0.2.10"})
+	if err != nil {
+		t.Fatalf("cannot create scan: %v", err)
+	}
+	if _, err := n.StartScan(scan.ID); err == nil {
+		t.Error("injected error should fail the launch")
+	}
+	if _, err := n.StartScan(scan.ID); err != nil {
+		t.Errorf("injected error should only fail once: %v", err)
+	}
+
+	s.SetLoading(3)
+	var progress []int64
+	for i := 0; i < 3; i++ {
+		status, err := n.ServerStatus()
+		if err != nil || status.Status != nessie.ServerStatusLoading || !status.MustDestroySession {
+			t.Fatalf("server should be loading, got %+v: %v", status, err)
+		}
+		progress = append(progress, status.Progress)
+	}
+	if progress[0] != 0 || progress[2] <= progress[1] {
+		t.Errorf("wrong loading progress %v", progress)
+	}
+	if status, err := n.ServerStatus(); err != nil || status.Status != nessie.ServerStatusReady {
+		t.Errorf("server should be ready, got %+v: %v", status, err)
+	}
+
+	s.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := n.ServerProperties(); err != nil {
+		t.Fatalf("cannot get properties: %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("response should be delayed")
+	}
+}
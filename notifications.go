@@ -0,0 +1,162 @@
+package nessie
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	FilterTypeAnd = "and"
+	FilterTypeOr  = "or"
+)
+
+// Fields and operators commonly used in scan notification filters.
+const (
+	NotificationFieldSeverity  = "severity"
+	NotificationFieldPluginID  = "plugin_id"
+	NotificationFieldHostname  = "hostname"
+	NotificationFieldCVSSScore = "cvss_base_score"
+
+	NotificationOpEqual       = "eq"
+	NotificationOpNotEqual    = "neq"
+	NotificationOpGreaterThan = "gt"
+	NotificationOpLessThan    = "lt"
+	NotificationOpMatch       = "match"
+	NotificationOpNotMatch    = "nmatch"
+)
+
+// NotificationFilter is a rule deciding whether a finding triggers a scan notification.
+// Nessus serializes it as a [field, operator, value] triplet.
+type NotificationFilter struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// SeverityFilter returns a notification filter on the severity index (0 for info to 4 for critical).
+func SeverityFilter(operator string, severity int64) NotificationFilter {
+	return NotificationFilter{Field: NotificationFieldSeverity, Operator: operator, Value: strconv.FormatInt(severity, 10)}
+}
+
+// PluginIDFilter returns a notification filter on the plugin ID.
+func PluginIDFilter(operator string, pluginID int64) NotificationFilter {
+	return NotificationFilter{Field: NotificationFieldPluginID, Operator: operator, Value: strconv.FormatInt(pluginID, 10)}
+}
+
+func (f NotificationFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{f.Field, f.Operator, f.Value})
+}
+
+func (f *NotificationFilter) UnmarshalJSON(data []byte) error {
+	var triplet []interface{}
+	if err := json.Unmarshal(data, &triplet); err != nil {
+		return err
+	}
+	if len(triplet) != 3 {
+		return fmt.Errorf("notification filter must have 3 elements, got %d", len(triplet))
+	}
+	values := make([]string, len(triplet))
+	for i, v := range triplet {
+		switch v := v.(type) {
+		case string:
+			values[i] = v
+		case float64:
+			values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("unexpected notification filter element %v", v)
+		}
+	}
+	f.Field, f.Operator, f.Value = values[0], values[1], values[2]
+	return nil
+}
+
+// ScanNotifications describes who gets emailed when a scan completes and with what.
+type ScanNotifications struct {
+	// Recipients are the email addresses to notify.
+	Recipients []string
+	// FilterType is FilterTypeAnd or FilterTypeOr, it defaults to FilterTypeAnd.
+	FilterType string
+	// Filters restrict notifications to scans with matching findings, no filter means always notify.
+	Filters []NotificationFilter
+	// AttachReport attaches the scan report to the notification email.
+	AttachReport bool
+	// AttachmentFormat is one of ExportPDF, ExportHTML or ExportCSV.
+	AttachmentFormat string
+	// AttachmentMaxSize is the maximum attachment size in MB, larger reports are not attached.
+	AttachmentMaxSize int64
+}
+
+// Validate checks the notification settings are consistent.
+func (s ScanNotifications) Validate() error {
+	for _, r := range s.Recipients {
+		if !strings.Contains(r, "@") {
+			return fmt.Errorf("invalid notification recipient %q", r)
+		}
+	}
+	switch s.FilterType {
+	case "", FilterTypeAnd, FilterTypeOr:
+	default:
+		return fmt.Errorf("invalid filter type %q", s.FilterType)
+	}
+	for _, f := range s.Filters {
+		if f.Field == "" || f.Operator == "" {
+			return fmt.Errorf("notification filter %v must have a field and an operator", f)
+		}
+	}
+	if !s.AttachReport {
+		return nil
+	}
+	if len(s.Recipients) == 0 {
+		return fmt.Errorf("cannot attach a report without recipients")
+	}
+	switch s.AttachmentFormat {
+	case ExportPDF, ExportHTML, ExportCSV:
+	default:
+		return fmt.Errorf("unsupported attachment format %q", s.AttachmentFormat)
+	}
+	if s.AttachmentMaxSize <= 0 {
+		return fmt.Errorf("attachment max size must be positive, got %d", s.AttachmentMaxSize)
+	}
+	return nil
+}
+
+// SetNotifications validates and applies the notification settings to the scan settings.
+func (s *ScanSettingsRequest) SetNotifications(notifications ScanNotifications) error {
+	if err := notifications.Validate(); err != nil {
+		return err
+	}
+	s.Emails = strings.Join(notifications.Recipients, ",")
+	s.FilterType = notifications.FilterType
+	if s.FilterType == "" {
+		s.FilterType = FilterTypeAnd
+	}
+	s.Filters = notifications.Filters
+	s.AttachReport = 0
+	s.AttachedReportType = ""
+	s.AttachedReportMaximumSize = 0
+	if notifications.AttachReport {
+		s.AttachReport = 1
+		s.AttachedReportType = notifications.AttachmentFormat
+		s.AttachedReportMaximumSize = notifications.AttachmentMaxSize
+	}
+	return nil
+}
+
+// Notifications returns the notification settings of the scan.
+func (s *Scan) Notifications() ScanNotifications {
+	notifications := ScanNotifications{
+		FilterType:        s.FilterType,
+		Filters:           s.NotificationFilters,
+		AttachReport:      s.AttachReport != 0,
+		AttachmentFormat:  s.AttachedReportType,
+		AttachmentMaxSize: int64(s.AttachedReportMaximumSize),
+	}
+	for _, r := range strings.Split(s.Emails, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			notifications.Recipients = append(notifications.Recipients, r)
+		}
+	}
+	return notifications
+}
@@ -0,0 +1,88 @@
+package nessie
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNotificationFilterJSON(t *testing.T) {
+	f := SeverityFilter(NotificationOpGreaterThan, 2)
+	j, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("cannot serialize filter: %v", err)
+	}
+	if got, want := string(j), `["severity","gt","2"]`; got != want {
+		t.Errorf("wrong serialization, got=%s, want=%s", got, want)
+	}
+
+	var scan Scan
+	payload := `{"notification_filters": [["plugin_id", "eq", 19506], ["severity", "gt", "3"]]}`
+	if err := json.Unmarshal([]byte(payload), &scan); err != nil {
+		t.Fatalf("cannot deserialize scan: %v", err)
+	}
+	want := []NotificationFilter{PluginIDFilter(NotificationOpEqual, 19506), SeverityFilter(NotificationOpGreaterThan, 3)}
+	if len(scan.NotificationFilters) != len(want) {
+		t.Fatalf("wrong filters, got=%v, want=%v", scan.NotificationFilters, want)
+	}
+	for i := range want {
+		if scan.NotificationFilters[i] != want[i] {
+			t.Errorf("wrong filter %d, got=%v, want=%v", i, scan.NotificationFilters[i], want[i])
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`["severity", "gt"]`), &f); err == nil {
+		t.Error("got no error for a filter with missing elements")
+	}
+}
+
+func TestSetNotifications(t *testing.T) {
+	var tests = []struct {
+		notifications ScanNotifications
+		wantError     bool
+	}{
+		{ScanNotifications{}, false},
+		{ScanNotifications{Recipients: []string{"sec@example.com"}, Filters: []NotificationFilter{SeverityFilter(NotificationOpEqual, 4)}}, false},
+		{ScanNotifications{Recipients: []string{"sec@example.com"}, AttachReport: true, AttachmentFormat: ExportPDF, AttachmentMaxSize: 25}, false},
+		{ScanNotifications{Recipients: []string{"sec@example.com"}, FilterType: FilterTypeOr, Filters: []NotificationFilter{SeverityFilter(NotificationOpEqual, 4), PluginIDFilter(NotificationOpEqual, 19506)}}, false},
+		// Not an email address.
+		{ScanNotifications{Recipients: []string{"sec"}}, true},
+		// Unknown filter type.
+		{ScanNotifications{FilterType: "xor"}, true},
+		// Filter without operator.
+		{ScanNotifications{Filters: []NotificationFilter{{Field: "severity", Value: "4"}}}, true},
+		// Attachment without recipients.
+		{ScanNotifications{AttachReport: true, AttachmentFormat: ExportPDF, AttachmentMaxSize: 25}, true},
+		// Attachment in a format nessus cannot mail.
+		{ScanNotifications{Recipients: []string{"sec@example.com"}, AttachReport: true, AttachmentFormat: ExportNessus, AttachmentMaxSize: 25}, true},
+		// Attachment without size limit.
+		{ScanNotifications{Recipients: []string{"sec@example.com"}, AttachReport: true, AttachmentFormat: ExportCSV}, true},
+	}
+	for _, tt := range tests {
+		var settings ScanSettingsRequest
+		err := settings.SetNotifications(tt.notifications)
+		if tt.wantError {
+			if err == nil {
+				t.Errorf("got no error, expected one (%+v)", tt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("error setting notifications: %v (%+v)", err, tt)
+			continue
+		}
+		scan := Scan{
+			Emails:                    settings.Emails,
+			NotificationFilters:       settings.Filters,
+			FilterType:                settings.FilterType,
+			AttachReport:              settings.AttachReport,
+			AttachedReportType:        settings.AttachedReportType,
+			AttachedReportMaximumSize: int(settings.AttachedReportMaximumSize),
+		}
+		got := scan.Notifications()
+		if len(got.Recipients) != len(tt.notifications.Recipients) || got.AttachReport != tt.notifications.AttachReport ||
+			got.AttachmentFormat != tt.notifications.AttachmentFormat || got.AttachmentMaxSize != tt.notifications.AttachmentMaxSize ||
+			len(got.Filters) != len(tt.notifications.Filters) || got.FilterType != settings.FilterType {
+			t.Errorf("notifications did not round trip, got=%+v, want=%+v", got, tt.notifications)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// Apply applies the changes of a plan in order, and stops at the first
+// failure. Changes applied before the failure are kept, planning again shows
+// what is left.
+func (r *Reconciler) Apply(p *Plan) error {
+	for i := range p.Changes {
+		c := &p.Changes[i]
+		if err := r.apply(p, c); err != nil {
+			return fmt.Errorf("%s: %v", c, err)
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) apply(p *Plan, c *Change) error {
+	switch c.Kind {
+	case KindFolder:
+		return r.applyFolder(p, c)
+	case KindPolicy:
+		return r.applyPolicy(p, c)
+	case KindScan:
+		return r.applyScan(p, c)
+	}
+	return fmt.Errorf("unknown kind %q", c.Kind)
+}
+
+func (r *Reconciler) applyFolder(p *Plan, c *Change) error {
+	if c.Action == ActionDelete {
+		return r.nessus.DeleteFolder(c.ID)
+	}
+	id, err := nessie.CreateFolderID(r.nessus, c.Name)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	p.folders[c.Name] = id
+	return nil
+}
+
+func (r *Reconciler) applyPolicy(p *Plan, c *Change) error {
+	switch c.Action {
+	case ActionDelete:
+		return r.nessus.DeletePolicy(c.ID)
+	case ActionCreate:
+		resp, err := r.nessus.CreatePolicy(policyRequest(c.policy))
+		if err != nil {
+			return err
+		}
+		c.ID = resp.PolicyID
+		p.policies[c.Name] = resp.PolicyID
+		if c.policy.Permissions != nil {
+			return r.nessus.SetPermissions("policy", c.ID, permissions(c.policy.Permissions))
+		}
+		return nil
+	}
+	if c.settings {
+		if err := r.nessus.ConfigurePolicy(c.ID, policyRequest(c.policy)); err != nil {
+			return err
+		}
+	}
+	if c.acls {
+		return r.nessus.SetPermissions("policy", c.ID, permissions(c.policy.Permissions))
+	}
+	return nil
+}
+
+func (r *Reconciler) applyScan(p *Plan, c *Change) error {
+	switch c.Action {
+	case ActionDelete:
+		return r.nessus.DeleteScan(c.ID)
+	case ActionCreate:
+		req, err := p.scanRequest(c.scan)
+		if err != nil {
+			return err
+		}
+		sc, err := r.nessus.CreateScan(req)
+		if err != nil {
+			return err
+		}
+		c.ID = sc.ID
+		if c.scan.Permissions != nil {
+			return r.nessus.SetPermissions("scan", c.ID, permissions(c.scan.Permissions))
+		}
+		return nil
+	}
+	if c.settings {
+		req, err := p.scanRequest(c.scan)
+		if err != nil {
+			return err
+		}
+		if _, err := r.nessus.ConfigureScan(c.ID, req); err != nil {
+			return err
+		}
+	}
+	if c.acls {
+		return r.nessus.SetPermissions("scan", c.ID, permissions(c.scan.Permissions))
+	}
+	return nil
+}
+
+func policyRequest(spec *PolicySpec) nessie.CreatePolicyRequest {
+	settings := spec.Settings
+	settings.Name = spec.Name
+	settings.Description = spec.Description
+	return nessie.CreatePolicyRequest{UUID: spec.Template, Settings: settings}
+}
+
+// scanRequest resolves the folder and policy of the scan, which exist once
+// the previous changes of the plan are applied.
+func (p *Plan) scanRequest(spec *ScanSpec) (nessie.NewScanRequest, error) {
+	folder := p.mainFolder
+	if spec.Folder != "" {
+		id, ok := p.folders[spec.Folder]
+		if !ok {
+			return nessie.NewScanRequest{}, fmt.Errorf("unknown folder %q", spec.Folder)
+		}
+		folder = id
+	}
+	var policy int64
+	if spec.Policy != "" {
+		id, ok := p.policies[spec.Policy]
+		if !ok {
+			return nessie.NewScanRequest{}, fmt.Errorf("unknown policy %q", spec.Policy)
+		}
+		policy = id
+	}
+	return nessie.NewScanRequest{
+		UUID: spec.Template,
+		Settings: nessie.ScanSettingsRequest{
+			Name:        spec.Name,
+			Description: spec.Description,
+			FolderID:    folder,
+			PolicyID:    policy,
+			ScannerID:   spec.ScannerID,
+			TextTargets: strings.Join(spec.Targets, ","),
+			Enabled:     spec.enabled(),
+			Launch:      nessie.LaunchFromRRules(spec.RRules),
+			RRules:      spec.RRules,
+			StartTime:   spec.StartTime,
+			TimeZone:    spec.TimeZone,
+			Emails:      spec.Emails,
+		},
+	}, nil
+}
+
+func permissions(acls []ACL) []nessie.Permission {
+	perms := make([]nessie.Permission, 0, len(acls))
+	for _, a := range acls {
+		perms = append(perms, nessie.Permission{Type: a.Type, Name: a.Name, ID: a.ID, Permissions: a.Permissions})
+	}
+	return perms
+}
@@ -0,0 +1,419 @@
+package reconcile
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// Action is what a change does to an object.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Kind is the kind of object a change applies to.
+type Kind string
+
+const (
+	KindFolder Kind = "folder"
+	KindPolicy Kind = "policy"
+	KindScan   Kind = "scan"
+)
+
+// Change is a single create, update or delete of an object.
+type Change struct {
+	Action Action
+	Kind   Kind
+	Name   string
+	// ID is the ID of the object on the server, 0 for creations.
+	ID int64
+	// Diffs describe the updated fields, e.g. `description: "a" -> "b"`.
+	Diffs []string
+
+	policy *PolicySpec
+	scan   *ScanSpec
+	// settings is whether the object itself is updated, and acls whether
+	// its permissions are.
+	settings bool
+	acls     bool
+}
+
+// String returns the change in the format of the plan, without its diffs.
+func (c *Change) String() string {
+	sign := map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}[c.Action]
+	if c.ID == 0 {
+		return fmt.Sprintf("%s %s %q", sign, c.Kind, c.Name)
+	}
+	return fmt.Sprintf("%s %s %q (%d)", sign, c.Kind, c.Name, c.ID)
+}
+
+// Plan is the ordered list of changes bringing a server to a spec: creations
+// and updates of folders, policies then scans, followed by the deletions in
+// the reverse order.
+type Plan struct {
+	Changes []Change
+
+	// folders and policies map the names of the objects on the server to
+	// their IDs, completed by Apply as it creates them.
+	folders    map[string]int64
+	mainFolder int64
+	policies   map[string]int64
+}
+
+// Empty returns whether the server already matches the spec.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Count returns the number of changes of an action.
+func (p *Plan) Count(action Action) int {
+	var n int
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// String returns the plan in a human readable form, one change per line
+// followed by its diffs, and a summary.
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "No changes.\n"
+	}
+	var b strings.Builder
+	for _, c := range p.Changes {
+		fmt.Fprintln(&b, c.String())
+		for _, d := range c.Diffs {
+			fmt.Fprintf(&b, "    %s\n", d)
+		}
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to delete.\n",
+		p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionDelete))
+	return b.String()
+}
+
+// Nessus is the part of the client used to reconcile a server.
+type Nessus interface {
+	nessie.FolderService
+	nessie.PolicyService
+	nessie.ScanService
+	nessie.UserService
+}
+
+// Reconciler plans and applies the changes bringing a server to a spec.
+type Reconciler struct {
+	// Prune deletes the custom folders, policies and scans missing from the
+	// spec. Without it, the objects not in the spec are left alone.
+	Prune bool
+
+	nessus Nessus
+}
+
+// NewReconciler returns a reconciler of the server of n, which must be logged in.
+func NewReconciler(n Nessus) *Reconciler {
+	return &Reconciler{nessus: n}
+}
+
+// Plan compares the spec with the server and returns the changes to apply,
+// without changing anything.
+func (r *Reconciler) Plan(spec *Spec) (*Plan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Plan{folders: map[string]int64{}, policies: map[string]int64{}}
+	var deletes []Change
+
+	folders, err := r.nessus.Folders()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list folders: %v", err)
+	}
+	wantFolders := map[string]bool{}
+	for _, f := range spec.Folders {
+		wantFolders[f.Name] = true
+	}
+	// Pruning keeps the folders and policies used by the scans of the spec.
+	usedFolders, usedPolicies := map[string]bool{}, map[string]bool{}
+	for _, sc := range spec.Scans {
+		usedFolders[sc.Folder] = true
+		usedPolicies[sc.Policy] = true
+	}
+	for _, f := range folders {
+		if f.Type == "main" {
+			p.mainFolder = f.ID
+		}
+		if f.Custom == 0 {
+			continue
+		}
+		if _, dup := p.folders[f.Name]; dup {
+			return nil, fmt.Errorf("several folders named %q", f.Name)
+		}
+		p.folders[f.Name] = f.ID
+		if !wantFolders[f.Name] && !usedFolders[f.Name] && r.Prune {
+			deletes = append(deletes, Change{Action: ActionDelete, Kind: KindFolder, Name: f.Name, ID: f.ID})
+		}
+	}
+	for _, f := range spec.Folders {
+		if _, ok := p.folders[f.Name]; !ok {
+			p.Changes = append(p.Changes, Change{Action: ActionCreate, Kind: KindFolder, Name: f.Name})
+		}
+	}
+
+	policies, err := r.nessus.Policies()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list policies: %v", err)
+	}
+	current := map[string]nessie.Policy{}
+	for _, pol := range policies {
+		if _, dup := current[pol.Name]; dup {
+			return nil, fmt.Errorf("several policies named %q", pol.Name)
+		}
+		current[pol.Name] = pol
+		p.policies[pol.Name] = pol.ID
+	}
+	wantPolicies := map[string]bool{}
+	for i := range spec.Policies {
+		want := &spec.Policies[i]
+		wantPolicies[want.Name] = true
+		pol, ok := current[want.Name]
+		if !ok {
+			p.Changes = append(p.Changes, Change{Action: ActionCreate, Kind: KindPolicy, Name: want.Name, policy: want})
+			continue
+		}
+		c := Change{Action: ActionUpdate, Kind: KindPolicy, Name: want.Name, ID: pol.ID, policy: want}
+		c.diff("description", pol.Desc, want.Description)
+		c.diff("template", pol.TemplateUUID, want.Template)
+		c.settings = len(c.Diffs) > 0
+		if err := r.diffACLs(&c, "policy", pol.ID, want.Permissions); err != nil {
+			return nil, err
+		}
+		if len(c.Diffs) > 0 {
+			p.Changes = append(p.Changes, c)
+		}
+	}
+	if r.Prune {
+		for _, pol := range policies {
+			if !wantPolicies[pol.Name] && !usedPolicies[pol.Name] {
+				deletes = append(deletes, Change{Action: ActionDelete, Kind: KindPolicy, Name: pol.Name, ID: pol.ID})
+			}
+		}
+	}
+
+	list, err := r.nessus.Scans()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list scans: %v", err)
+	}
+	scans := map[string]nessie.Scan{}
+	for _, sc := range list.Scans {
+		if sc.FolderID == trashFolder(folders) {
+			continue
+		}
+		if _, dup := scans[sc.Name]; dup {
+			return nil, fmt.Errorf("several scans named %q", sc.Name)
+		}
+		scans[sc.Name] = sc
+	}
+	wantScans := map[string]bool{}
+	for i := range spec.Scans {
+		want := &spec.Scans[i]
+		wantScans[want.Name] = true
+		if want.Folder != "" && !wantFolders[want.Folder] {
+			if _, ok := p.folders[want.Folder]; !ok {
+				return nil, fmt.Errorf("scan %q: unknown folder %q", want.Name, want.Folder)
+			}
+		}
+		if want.Policy != "" && !wantPolicies[want.Policy] {
+			if _, ok := p.policies[want.Policy]; !ok {
+				return nil, fmt.Errorf("scan %q: unknown policy %q", want.Name, want.Policy)
+			}
+		}
+		sc, ok := scans[want.Name]
+		if !ok {
+			p.Changes = append(p.Changes, Change{Action: ActionCreate, Kind: KindScan, Name: want.Name, scan: want})
+			continue
+		}
+		c := Change{Action: ActionUpdate, Kind: KindScan, Name: want.Name, ID: sc.ID, scan: want}
+		if err := r.diffScan(&c, p, &sc, want); err != nil {
+			return nil, err
+		}
+		c.settings = len(c.Diffs) > 0
+		if err := r.diffACLs(&c, "scan", sc.ID, want.Permissions); err != nil {
+			return nil, err
+		}
+		if len(c.Diffs) > 0 {
+			p.Changes = append(p.Changes, c)
+		}
+	}
+	if r.Prune {
+		var names []string
+		for name := range scans {
+			if !wantScans[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var scanDeletes []Change
+		for _, name := range names {
+			scanDeletes = append(scanDeletes, Change{Action: ActionDelete, Kind: KindScan, Name: name, ID: scans[name].ID})
+		}
+		// Scans go before the policies and folders they use.
+		deletes = append(scanDeletes, reverse(deletes)...)
+	}
+	p.Changes = append(p.Changes, deletes...)
+	return p, nil
+}
+
+func (r *Reconciler) diffScan(c *Change, p *Plan, sc *nessie.Scan, want *ScanSpec) error {
+	details, err := r.nessus.ScanDetails(sc.ID)
+	if err != nil {
+		return fmt.Errorf("cannot get details of scan %q: %v", sc.Name, err)
+	}
+	c.diff("description", sc.Description, want.Description)
+
+	folder := p.mainFolder
+	if want.Folder != "" {
+		folder = p.folders[want.Folder]
+	}
+	if folder == 0 || sc.FolderID != folder {
+		c.diff("folder", p.folderName(sc.FolderID), folderLabel(want.Folder))
+	}
+	var policy int64
+	if want.Policy != "" {
+		policy = p.policies[want.Policy]
+	}
+	if (want.Policy != "" && policy == 0) || int64(sc.PolicyID) != policy {
+		c.diff("policy", p.policyName(int64(sc.PolicyID)), want.Policy)
+	}
+	if want.ScannerID != 0 && int64(sc.ScannerID) != want.ScannerID {
+		c.diff("scanner_id", strconv.Itoa(sc.ScannerID), strconv.FormatInt(want.ScannerID, 10))
+	}
+	c.diff("targets", normalizeTargets(splitTargets(details.Info.Targets)), normalizeTargets(want.Targets))
+	c.diff("enabled", strconv.FormatBool(sc.Enabled != 0), strconv.FormatBool(want.enabled()))
+	c.diff("rrules", sc.RRules, want.RRules)
+	c.diff("timezone", sc.TimeZone, want.TimeZone)
+	c.diff("starttime", string(sc.StartTime), want.StartTime)
+	c.diff("emails", sc.Emails, want.Emails)
+	return nil
+}
+
+// diffACLs compares the permissions of an object when the spec sets them.
+func (r *Reconciler) diffACLs(c *Change, objectType string, id int64, want []ACL) error {
+	if want == nil {
+		return nil
+	}
+	current, err := r.nessus.Permissions(objectType, id)
+	if err != nil {
+		return fmt.Errorf("cannot get permissions of %s %q: %v", objectType, c.Name, err)
+	}
+	var have []ACL
+	for _, perm := range current {
+		// The owner keeps its permissions whatever the ACLs.
+		if perm.Owner == 1 {
+			continue
+		}
+		have = append(have, ACL{Type: perm.Type, Name: perm.Name, ID: perm.ID, Permissions: perm.Permissions})
+	}
+	before, after := formatACLs(have), formatACLs(want)
+	if before != after {
+		c.Diffs = append(c.Diffs, fmt.Sprintf("permissions: %s -> %s", before, after))
+		c.acls = true
+	}
+	return nil
+}
+
+func (c *Change) diff(field, before, after string) {
+	if before != after {
+		c.Diffs = append(c.Diffs, fmt.Sprintf("%s: %q -> %q", field, before, after))
+	}
+}
+
+func (p *Plan) folderName(id int64) string {
+	if id == p.mainFolder {
+		return folderLabel("")
+	}
+	for name, fid := range p.folders {
+		if fid == id {
+			return name
+		}
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+func (p *Plan) policyName(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	for name, pid := range p.policies {
+		if pid == id {
+			return name
+		}
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+func folderLabel(name string) string {
+	if name == "" {
+		return "My Scans"
+	}
+	return name
+}
+
+func trashFolder(folders []nessie.Folder) int64 {
+	for _, f := range folders {
+		if f.Type == "trash" {
+			return f.ID
+		}
+	}
+	return -1
+}
+
+func (s *ScanSpec) enabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// splitTargets splits the targets of a scan, separated by commas or lines.
+func splitTargets(targets string) []string {
+	return strings.FieldsFunc(targets, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' '
+	})
+}
+
+// normalizeTargets returns the sorted targets separated by commas, so that
+// their order and spacing do not matter.
+func normalizeTargets(targets []string) string {
+	var clean []string
+	for _, t := range targets {
+		clean = append(clean, splitTargets(t)...)
+	}
+	sort.Strings(clean)
+	return strings.Join(clean, ",")
+}
+
+func formatACLs(acls []ACL) string {
+	var parts []string
+	for _, a := range acls {
+		who := a.Type
+		if a.Type != "default" {
+			who += ":" + a.Name
+		}
+		parts = append(parts, fmt.Sprintf("%s=%d", who, a.Permissions))
+	}
+	sort.Strings(parts)
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func reverse(changes []Change) []Change {
+	reversed := make([]Change, len(changes))
+	for i, c := range changes {
+		reversed[len(changes)-1-i] = c
+	}
+	return reversed
+}
@@ -0,0 +1,203 @@
+package reconcile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/nessietest"
+)
+
+const specYAML = `
+folders:
+  - name: prod
+policies:
+  - name: fast
+    description: Fast discovery
+    template: ` + nessietest.BasicTemplateUUID + `
+scans:
+  - name: weekly
+    template: ` + nessietest.BasicTemplateUUID + `
+    folder: prod
+    policy: fast
+    targets: [10.0.0.1, 10.0.0.2]
+    rrules: FREQ=WEEKLY;INTERVAL=1;BYDAY=MO
+    starttime: 20210104T020000
+    timezone: Europe/Paris
+    permissions:
+      - type: default
+        permissions: 16
+`
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec([]byte(specYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Scans) != 1 || spec.Scans[0].Policy != "fast" || len(spec.Scans[0].Targets) != 2 || spec.Scans[0].Permissions[0].Permissions != 16 {
+		t.Errorf("wrong spec %+v", spec)
+	}
+	// JSON is YAML.
+	if _, err := ParseSpec([]byte(`{"folders": [{"name": "prod"}]}`)); err != nil {
+		t.Errorf("cannot parse JSON spec: %v", err)
+	}
+
+	var invalid = []string{
+		`folders: [{name: a}, {name: a}]`,
+		`policies: [{name: p}]`,
+		`scans: [{name: s, template: t}]`,
+		`scans: [{name: s, template: t, targets: [a], starttime: tomorrow}]`,
+		`scans: [{name: s, template: t, targets: [a], permissions: [{type: user, permissions: 16}]}]`,
+		`scans: [{name: s, template: t, targets: [a], target: b}]`,
+		`folders: {name: a}`,
+	}
+	for _, in := range invalid {
+		if _, err := ParseSpec([]byte(in)); err == nil {
+			t.Errorf("spec %q should be invalid", in)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	s := nessietest.NewServer()
+	defer s.Close()
+	n := s.Client(t)
+	if err := n.CreateFolder("old"); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := ParseSpec([]byte(specYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReconciler(n)
+	r.Prune = true
+
+	plan, err := r.Plan(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `+ folder "prod"
++ policy "fast"
++ scan "weekly"
+- folder "old" (5)
+Plan: 3 to create, 0 to update, 1 to delete.
+`
+	if plan.String() != want {
+		t.Errorf("wrong plan, got:\n%swant:\n%s", plan, want)
+	}
+	if err := r.Apply(plan); err != nil {
+		t.Fatalf("cannot apply: %v", err)
+	}
+
+	// The server matches the spec now.
+	plan, err = r.Plan(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("plan should be empty after apply, got:\n%s", plan)
+	}
+	scans, err := n.Scans()
+	if err != nil || len(scans.Scans) != 1 {
+		t.Fatalf("wrong scans %+v, err=%v", scans, err)
+	}
+	weekly := scans.Scans[0]
+	if weekly.RRules != spec.Scans[0].RRules || weekly.Enabled != 1 || weekly.PolicyID == 0 {
+		t.Errorf("wrong created scan %+v", weekly)
+	}
+	if acls, err := n.Permissions("scan", weekly.ID); err != nil || len(acls) != 1 || acls[0].Type != "default" {
+		t.Errorf("wrong permissions %+v, err=%v", acls, err)
+	}
+
+	// Targets are compared whatever their order, other changes are updates.
+	spec.Scans[0].Targets = []string{"10.0.0.2", "10.0.0.1", "10.0.0.3"}
+	spec.Scans[0].Permissions = append(spec.Scans[0].Permissions, ACL{Type: "user", Name: "alice", ID: 7, Permissions: 64})
+	spec.Policies[0].Description = "Faster discovery"
+	plan, err = r.Plan(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `~ policy "fast" (7)
+    description: "Fast discovery" -> "Faster discovery"
+~ scan "weekly" (8)
+    targets: "10.0.0.1,10.0.0.2" -> "10.0.0.1,10.0.0.2,10.0.0.3"
+    permissions: [default=16] -> [default=16 user:alice=64]
+Plan: 0 to create, 2 to update, 0 to delete.
+`
+	if plan.String() != want {
+		t.Errorf("wrong plan, got:\n%swant:\n%s", plan, want)
+	}
+	if err := r.Apply(plan); err != nil {
+		t.Fatalf("cannot apply: %v", err)
+	}
+	if plan, err := r.Plan(spec); err != nil || !plan.Empty() {
+		t.Errorf("plan should be empty after update, got:\n%s err=%v", plan, err)
+	}
+
+	// Pruning deletes the scans before the policies and folders they use.
+	plan, err = r.Plan(&Spec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `- scan "weekly" (8)
+- policy "fast" (7)
+- folder "prod" (6)
+Plan: 0 to create, 0 to update, 3 to delete.
+`
+	if plan.String() != want {
+		t.Errorf("wrong plan, got:\n%swant:\n%s", plan, want)
+	}
+	if err := r.Apply(plan); err != nil {
+		t.Fatalf("cannot apply: %v", err)
+	}
+	if policies, err := n.Policies(); err != nil || len(policies) != 0 {
+		t.Errorf("policies should be deleted, got %+v err=%v", policies, err)
+	}
+
+	// Without prune, objects missing from the spec are left alone.
+	if err := n.CreateFolder("manual"); err != nil {
+		t.Fatal(err)
+	}
+	r.Prune = false
+	if plan, err := r.Plan(&Spec{}); err != nil || !plan.Empty() {
+		t.Errorf("plan without prune should be empty, got:\n%s err=%v", plan, err)
+	}
+}
+
+func TestPlanUnknownReference(t *testing.T) {
+	s := nessietest.NewServer()
+	defer s.Close()
+	r := NewReconciler(s.Client(t))
+	spec := &Spec{Scans: []ScanSpec{{Name: "s", Template: nessietest.BasicTemplateUUID, Targets: []string{"a"}, Policy: "missing"}}}
+	if _, err := r.Plan(spec); err == nil || !strings.Contains(err.Error(), `unknown policy "missing"`) {
+		t.Errorf("unknown policy should fail the plan, got %v", err)
+	}
+}
+
+func TestPlanPruneKeepsReferences(t *testing.T) {
+	s := nessietest.NewServer()
+	defer s.Close()
+	n := s.Client(t)
+	if err := n.CreateFolder("legacy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.CreatePolicy(nessie.CreatePolicyRequest{
+		UUID:     nessietest.BasicTemplateUUID,
+		Settings: nessie.PolicySettings{Name: "fast"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReconciler(n)
+	r.Prune = true
+	spec := &Spec{Scans: []ScanSpec{{Name: "s", Template: nessietest.BasicTemplateUUID, Targets: []string{"a"}, Folder: "legacy", Policy: "fast"}}}
+	plan, err := r.Plan(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `+ scan "s"
+Plan: 1 to create, 0 to update, 0 to delete.
+`
+	if plan.String() != want {
+		t.Errorf("wrong plan, got:\n%swant:\n%s", plan, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Package reconcile brings the folders, policies and scans of a server, and
+// their permissions, to a declarative spec kept as code. A Reconciler plans
+// the changes by comparing the spec with the server, then applies them.
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/JerusJ/nessie"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec declares the folders, policies and scans expected on a server.
+// Objects are matched by name, which must be unique per kind.
+type Spec struct {
+	Folders  []FolderSpec `json:"folders,omitempty"`
+	Policies []PolicySpec `json:"policies,omitempty"`
+	Scans    []ScanSpec   `json:"scans,omitempty"`
+}
+
+// FolderSpec declares a custom folder.
+type FolderSpec struct {
+	Name string `json:"name"`
+}
+
+// PolicySpec declares a scan policy.
+type PolicySpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Template is the UUID of the policy template.
+	Template string `json:"template"`
+	// Settings are sent when the policy is created or updated, but nessus
+	// does not list them so changing them alone does not update the policy.
+	// Their name and description are replaced by the ones above.
+	Settings nessie.PolicySettings `json:"settings,omitempty"`
+	// Permissions replace the permissions of the policy, nil leaves them as
+	// they are.
+	Permissions []ACL `json:"permissions,omitempty"`
+}
+
+// ScanSpec declares a scan.
+type ScanSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Template is the UUID of the scan template.
+	Template string `json:"template"`
+	// Folder is the name of the folder of the scan, My Scans when empty.
+	Folder string `json:"folder,omitempty"`
+	// Policy is the name of the policy of the scan, none when empty.
+	Policy string `json:"policy,omitempty"`
+	// ScannerID is the scanner running the scan, the default one when 0.
+	ScannerID int64    `json:"scanner_id,omitempty"`
+	Targets   []string `json:"targets"`
+	// Enabled enables the schedule of the scan, true when missing.
+	Enabled   *bool  `json:"enabled,omitempty"`
+	RRules    string `json:"rrules,omitempty"`
+	StartTime string `json:"starttime,omitempty"`
+	TimeZone  string `json:"timezone,omitempty"`
+	Emails    string `json:"emails,omitempty"`
+	// Permissions replace the permissions of the scan, nil leaves them as they
+	// are.
+	Permissions []ACL `json:"permissions,omitempty"`
+}
+
+// ACL grants permissions on a policy or a scan to a user, a group, or to
+// everyone else with the default type.
+type ACL struct {
+	// Type is "user", "group" or "default".
+	Type string `json:"type"`
+	// Name is the name of the user or group, empty for the default type.
+	Name string `json:"name,omitempty"`
+	// ID is the ID of the user or group, sent along the name when set.
+	ID int64 `json:"id,omitempty"`
+	// Permissions is a level such as 16 (can view) or 64 (can configure).
+	Permissions int64 `json:"permissions"`
+}
+
+// ParseSpec parses a YAML or JSON spec and validates it. Unknown fields are
+// rejected to catch typos.
+func ParseSpec(data []byte) (*Spec, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse spec: %v", err)
+	}
+	// Going through JSON shares the field names and types of the API.
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse spec: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	spec := &Spec{}
+	if err := dec.Decode(spec); err != nil {
+		return nil, fmt.Errorf("cannot parse spec: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// LoadSpec reads and parses a YAML or JSON spec file.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return spec, nil
+}
+
+// Validate checks that the objects of the spec are named, unique and
+// complete. References to folders and policies are checked by Plan since they
+// may exist on the server only.
+func (s *Spec) Validate() error {
+	folders := map[string]bool{}
+	for _, f := range s.Folders {
+		if f.Name == "" {
+			return fmt.Errorf("folder without name")
+		}
+		if folders[f.Name] {
+			return fmt.Errorf("duplicate folder %q", f.Name)
+		}
+		folders[f.Name] = true
+	}
+	policies := map[string]bool{}
+	for _, p := range s.Policies {
+		if p.Name == "" {
+			return fmt.Errorf("policy without name")
+		}
+		if policies[p.Name] {
+			return fmt.Errorf("duplicate policy %q", p.Name)
+		}
+		policies[p.Name] = true
+		if p.Template == "" {
+			return fmt.Errorf("policy %q has no template", p.Name)
+		}
+		if err := validateACLs(p.Permissions); err != nil {
+			return fmt.Errorf("policy %q: %v", p.Name, err)
+		}
+	}
+	scans := map[string]bool{}
+	for _, sc := range s.Scans {
+		if sc.Name == "" {
+			return fmt.Errorf("scan without name")
+		}
+		if scans[sc.Name] {
+			return fmt.Errorf("duplicate scan %q", sc.Name)
+		}
+		scans[sc.Name] = true
+		if sc.Template == "" {
+			return fmt.Errorf("scan %q has no template", sc.Name)
+		}
+		if len(sc.Targets) == 0 {
+			return fmt.Errorf("scan %q has no targets", sc.Name)
+		}
+		if sc.StartTime != "" {
+			if _, err := nessie.ScanStartTime(sc.StartTime).In(sc.TimeZone); err != nil {
+				return fmt.Errorf("scan %q: %v", sc.Name, err)
+			}
+		}
+		if err := validateACLs(sc.Permissions); err != nil {
+			return fmt.Errorf("scan %q: %v", sc.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateACLs(acls []ACL) error {
+	for _, a := range acls {
+		switch a.Type {
+		case "user", "group":
+			if a.Name == "" {
+				return fmt.Errorf("%s permission without name", a.Type)
+			}
+		case "default":
+		default:
+			return fmt.Errorf("unknown permission type %q", a.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// CSV columns, the set of columns varies with the nessus version and the export options.
+const (
+	csvPluginID     = "plugin id"
+	csvCVE          = "cve"
+	csvCVSS         = "cvss"
+	csvCVSS2        = "cvss v2.0 base score"
+	csvCVSS3        = "cvss v3.0 base score"
+	csvRisk         = "risk"
+	csvHost         = "host"
+	csvProtocol     = "protocol"
+	csvPort         = "port"
+	csvName         = "name"
+	csvSynopsis     = "synopsis"
+	csvDescription  = "description"
+	csvSolution     = "solution"
+	csvSeeAlso      = "see also"
+	csvPluginOutput = "plugin output"
+	csvFamily       = "plugin family"
+)
+
+// CSVReader reads the findings of a nessus CSV export.
+//
+// Nessus writes one row per CVE of a finding, consecutive rows of the same
+// finding are merged into a single Finding.
+type CSVReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	line    int
+	pending *Finding
+}
+
+// NewCSVReader returns a reader of the CSV export read from r.
+func NewCSVReader(r io.Reader) *CSVReader {
+	cr := csv.NewReader(r)
+	// Rows are not all the same length when optional columns are empty at the end.
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	return &CSVReader{r: cr}
+}
+
+// ReadFinding returns the next finding of the export, or io.EOF when there are no more findings.
+func (r *CSVReader) ReadFinding() (*Finding, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+	f := r.pending
+	r.pending = nil
+	if f == nil {
+		var err error
+		if f, err = r.readRow(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		next, err := r.readRow()
+		if err == io.EOF {
+			return f, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !sameFinding(f, next) {
+			r.pending = next
+			return f, nil
+		}
+		for _, cve := range next.CVEs {
+			if !contains(f.CVEs, cve) {
+				f.CVEs = append(f.CVEs, cve)
+			}
+		}
+	}
+}
+
+func (r *CSVReader) readHeader() error {
+	header, err := r.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("empty csv export")
+		}
+		return err
+	}
+	r.line++
+	r.columns = map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		r.columns[name] = i
+	}
+	for _, required := range []string{csvPluginID, csvHost} {
+		if _, ok := r.columns[required]; !ok {
+			return fmt.Errorf("csv export has no %q column", required)
+		}
+	}
+	return nil
+}
+
+func (r *CSVReader) readRow() (*Finding, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.line++
+	get := func(column string) string {
+		i, ok := r.columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	f := &Finding{
+		Host:         get(csvHost),
+		Protocol:     get(csvProtocol),
+		PluginName:   get(csvName),
+		PluginFamily: get(csvFamily),
+		RiskFactor:   get(csvRisk),
+		Synopsis:     get(csvSynopsis),
+		Description:  get(csvDescription),
+		Solution:     get(csvSolution),
+		PluginOutput: get(csvPluginOutput),
+	}
+	if f.PluginID, err = strconv.ParseInt(get(csvPluginID), 10, 64); err != nil {
+		return nil, fmt.Errorf("line %d: invalid plugin id: %v", r.line, err)
+	}
+	if port := get(csvPort); port != "" {
+		if f.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("line %d: invalid port: %v", r.line, err)
+		}
+	}
+	if sev, err := nessie.ParseSeverity(f.RiskFactor); err == nil {
+		f.Severity = sev
+	}
+	if cve := get(csvCVE); cve != "" {
+		f.CVEs = []string{cve}
+	}
+	cvss := get(csvCVSS2)
+	if cvss == "" {
+		cvss = get(csvCVSS)
+	}
+	if f.CVSSBaseScore, err = parseScore(cvss); err != nil {
+		return nil, fmt.Errorf("line %d: invalid cvss score: %v", r.line, err)
+	}
+	if f.CVSS3BaseScore, err = parseScore(get(csvCVSS3)); err != nil {
+		return nil, fmt.Errorf("line %d: invalid cvss v3 score: %v", r.line, err)
+	}
+	for _, s := range strings.Split(get(csvSeeAlso), "\n") {
+		if s = strings.TrimSpace(s); s != "" {
+			f.SeeAlso = append(f.SeeAlso, s)
+		}
+	}
+	return f, nil
+}
+
+func sameFinding(a, b *Finding) bool {
+	return a.PluginID == b.PluginID && a.Host == b.Host && a.Protocol == b.Protocol && a.Port == b.Port
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
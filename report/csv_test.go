@@ -0,0 +1,97 @@
+package report
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFindings(t *testing.T, r FindingReader) []*Finding {
+	var findings []*Finding
+	for {
+		f, err := r.ReadFinding()
+		if err == io.EOF {
+			return findings
+		}
+		if err != nil {
+			t.Fatalf("cannot read finding: %v", err)
+		}
+		findings = append(findings, f)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	f, err := os.Open("testdata/sample.csv")
+	if err != nil {
+		t.Fatalf("cannot open export: %v", err)
+	}
+	defer f.Close()
+
+	findings := readFindings(t, NewCSVReader(f))
+	if len(findings) != 4 {
+		t.Fatalf("wrong number of findings, got=%d, want=4", len(findings))
+	}
+	apache := findings[1]
+	if apache.PluginID != 142960 || apache.Host != "192.0.2.10" || apache.Port != 443 || apache.Protocol != "tcp" ||
+		apache.Severity != 4 || apache.CVSSBaseScore != 7.5 || apache.CVSS3BaseScore != 9.8 {
+		t.Errorf("wrong finding, got=%+v", apache)
+	}
+	if len(apache.CVEs) != 2 || apache.CVEs[1] != "CVE-2020-11984" {
+		t.Errorf("rows of the same finding should be merged, got cves=%v", apache.CVEs)
+	}
+	if len(apache.SeeAlso) != 2 || !strings.Contains(apache.PluginOutput, "Fixed version     : 2.4.46") {
+		t.Errorf("multi-line fields not parsed, got see_also=%v output=%q", apache.SeeAlso, apache.PluginOutput)
+	}
+	// The last row is missing its trailing optional column.
+	if rdp := findings[3]; rdp.PluginID != 18405 || rdp.Severity != 2 || rdp.CVSS3BaseScore != 0 {
+		t.Errorf("wrong finding, got=%+v", rdp)
+	}
+}
+
+// Both readers return the same findings for the same scan.
+func TestCSVReaderMatchesNessusReader(t *testing.T) {
+	csvFile, err := os.Open("testdata/sample.csv")
+	if err != nil {
+		t.Fatalf("cannot open export: %v", err)
+	}
+	defer csvFile.Close()
+	nessusFile, err := os.Open("testdata/sample.nessus")
+	if err != nil {
+		t.Fatalf("cannot open report: %v", err)
+	}
+	defer nessusFile.Close()
+
+	fromCSV := readFindings(t, NewCSVReader(csvFile))
+	fromNessus := readFindings(t, NewReader(nessusFile))
+	if len(fromCSV) != len(fromNessus) {
+		t.Fatalf("different number of findings, csv=%d, nessus=%d", len(fromCSV), len(fromNessus))
+	}
+	for i := range fromCSV {
+		c, n := fromCSV[i], fromNessus[i]
+		if c.Host != n.Host || c.PluginID != n.PluginID || c.Port != n.Port || len(c.CVEs) != len(n.CVEs) {
+			t.Errorf("finding %d differs, csv=%+v, nessus=%+v", i, c, n)
+		}
+	}
+}
+
+func TestCSVReaderOlderColumns(t *testing.T) {
+	export := "Plugin ID,CVE,CVSS,Risk,Host,Protocol,Port,Name\n" +
+		"70658,CVE-2008-5161,2.6,Low,192.0.2.10,tcp,22,SSH Server CBC Mode Ciphers Enabled\n"
+	findings := readFindings(t, NewCSVReader(strings.NewReader(export)))
+	if len(findings) != 1 || findings[0].CVSSBaseScore != 2.6 || findings[0].Severity != 1 {
+		t.Errorf("wrong findings, got=%+v", findings)
+	}
+
+	var tests = []string{
+		"",
+		"CVE,Host\nCVE-2008-5161,192.0.2.10\n",
+		"Plugin ID,Host,Port\nabc,192.0.2.10,22\n",
+		"Plugin ID,Host,Port\n70658,192.0.2.10,ssh\n",
+	}
+	for _, tt := range tests {
+		if _, err := NewCSVReader(strings.NewReader(tt)).ReadFinding(); err == nil || err == io.EOF {
+			t.Errorf("expected an error for %q, got=%v", tt, err)
+		}
+	}
+}
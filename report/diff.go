@@ -0,0 +1,206 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// FindingKey identifies a finding on a host across runs of a scan.
+type FindingKey struct {
+	PluginID int64
+	Port     int
+	Protocol string
+}
+
+// Key returns the key identifying the finding on its host.
+func (f *Finding) Key() FindingKey {
+	return FindingKey{PluginID: f.PluginID, Port: f.Port, Protocol: f.Protocol}
+}
+
+// HostDiff lists the findings of a host that changed between two runs.
+type HostDiff struct {
+	Host string
+	// Added findings are only in the new run.
+	Added []*Finding
+	// Resolved findings are only in the old run.
+	Resolved []*Finding
+	// Persisting findings are in both runs, as found in the new run.
+	Persisting []*Finding
+}
+
+// Diff is the difference between the findings of two runs of a scan.
+type Diff struct {
+	// Hosts are sorted by name. Every host with findings in either run is listed,
+	// including hosts whose findings all persist.
+	Hosts      []HostDiff
+	Added      nessie.SeverityCounts
+	Resolved   nessie.SeverityCounts
+	Persisting nessie.SeverityCounts
+}
+
+// DiffFindings compares the findings of two runs, matching hosts by name and
+// findings by plugin ID, port and protocol (see Finding.Key).
+func DiffFindings(oldFindings, newFindings []*Finding) *Diff {
+	type hostFindings map[FindingKey]*Finding
+	index := func(findings []*Finding) map[string]hostFindings {
+		byHost := map[string]hostFindings{}
+		for _, f := range findings {
+			if byHost[f.Host] == nil {
+				byHost[f.Host] = hostFindings{}
+			}
+			byHost[f.Host][f.Key()] = f
+		}
+		return byHost
+	}
+	oldByHost, newByHost := index(oldFindings), index(newFindings)
+
+	var hosts []string
+	for host := range oldByHost {
+		hosts = append(hosts, host)
+	}
+	for host := range newByHost {
+		if _, ok := oldByHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+
+	d := &Diff{}
+	for _, host := range hosts {
+		hd := HostDiff{Host: host}
+		for key, f := range newByHost[host] {
+			if _, ok := oldByHost[host][key]; ok {
+				hd.Persisting = append(hd.Persisting, f)
+				d.Persisting.Add(f.Severity, 1)
+			} else {
+				hd.Added = append(hd.Added, f)
+				d.Added.Add(f.Severity, 1)
+			}
+		}
+		for key, f := range oldByHost[host] {
+			if _, ok := newByHost[host][key]; !ok {
+				hd.Resolved = append(hd.Resolved, f)
+				d.Resolved.Add(f.Severity, 1)
+			}
+		}
+		sortFindings(hd.Added)
+		sortFindings(hd.Resolved)
+		sortFindings(hd.Persisting)
+		d.Hosts = append(d.Hosts, hd)
+	}
+	return d
+}
+
+// DiffReports compares the findings of two parsed reports of the same scan.
+func DiffReports(oldReport, newReport FindingReader) (*Diff, error) {
+	oldFindings, err := ReadAll(oldReport)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read old report: %v", err)
+	}
+	newFindings, err := ReadAll(newReport)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read new report: %v", err)
+	}
+	return DiffFindings(oldFindings, newFindings), nil
+}
+
+// DiffScanHistories compares two completed runs of a scan, as listed in its History.
+//
+// The ports of the findings are resolved with one PluginOutput call per host and
+// plugin, diffing large scans is slow; exporting both runs with ExportScanWithOptions
+// and using DiffReports is cheaper.
+func DiffScanHistories(n nessie.ScanService, scanID, oldHistoryID, newHistoryID int64) (*Diff, error) {
+	details, err := n.ScanDetails(scanID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range []int64{oldHistoryID, newHistoryID} {
+		if err := checkHistory(details.History, id); err != nil {
+			return nil, fmt.Errorf("scan %d: %v", scanID, err)
+		}
+	}
+	oldFindings, err := historyFindings(n, scanID, oldHistoryID)
+	if err != nil {
+		return nil, err
+	}
+	newFindings, err := historyFindings(n, scanID, newHistoryID)
+	if err != nil {
+		return nil, err
+	}
+	return DiffFindings(oldFindings, newFindings), nil
+}
+
+func checkHistory(history []nessie.History, id int64) error {
+	for _, h := range history {
+		if h.HistoryID != id {
+			continue
+		}
+		if strings.ToLower(h.Status) != "completed" {
+			return fmt.Errorf("history %d is %s, not completed", id, h.Status)
+		}
+		return nil
+	}
+	return fmt.Errorf("no history %d", id)
+}
+
+// historyFindings fetches the findings of a run of the scan, one per plugin and port.
+func historyFindings(n nessie.ScanService, scanID, historyID int64) ([]*Finding, error) {
+	details, err := n.ScanHistoryDetails(scanID, historyID)
+	if err != nil {
+		return nil, err
+	}
+	var findings []*Finding
+	for _, host := range details.Hosts {
+		hostDetails, err := n.HostHistoryDetails(scanID, host.HostID, historyID)
+		if err != nil {
+			return nil, err
+		}
+		for _, vuln := range hostDetails.Vulnerabilities {
+			base := Finding{
+				Host:         host.Hostname,
+				PluginID:     vuln.PluginID,
+				PluginName:   vuln.PluginName,
+				PluginFamily: vuln.PluginFamily,
+				Severity:     vuln.Severity,
+			}
+			output, err := n.PluginOutput(scanID, host.HostID, vuln.PluginID, historyID)
+			if err != nil {
+				return nil, err
+			}
+			var ports int
+			for _, o := range output.Outputs {
+				for _, port := range o.PortKeys() {
+					f := base
+					f.Port, f.Protocol, f.ServiceName = port.Port, port.Protocol, port.Service
+					f.PluginOutput = o.PluginOutput
+					findings = append(findings, &f)
+					ports++
+				}
+			}
+			if ports == 0 {
+				f := base
+				findings = append(findings, &f)
+			}
+		}
+	}
+	return findings, nil
+}
+
+func sortFindings(findings []*Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.PluginID != b.PluginID {
+			return a.PluginID < b.PluginID
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Protocol < b.Protocol
+	})
+}
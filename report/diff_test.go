@@ -0,0 +1,100 @@
+package report
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+)
+
+func TestDiffReports(t *testing.T) {
+	f, err := os.Open("testdata/sample.nessus")
+	if err != nil {
+		t.Fatalf("cannot open report: %v", err)
+	}
+	defer f.Close()
+	// The next run: apache got patched, ssh now listens on 2222 and rdp is unchanged.
+	next := `
+Plugin ID,CVE,Risk,Host,Protocol,Port,Name
+19506,,None,192.0.2.10,tcp,0,Nessus Scan Information
+70658,CVE-2008-5161,Medium,192.0.2.10,tcp,2222,SSH Server CBC Mode Ciphers Enabled
+18405,CVE-2005-1794,Medium,192.0.2.11,tcp,3389,Microsoft Windows Remote Desktop Protocol Server Man-in-the-Middle Weakness
+10863,,None,192.0.2.13,tcp,443,SSL Certificate Information
+`
+	d, err := DiffReports(NewReader(f), NewCSVReader(strings.NewReader(strings.TrimSpace(next))))
+	if err != nil {
+		t.Fatalf("cannot diff reports: %v", err)
+	}
+	if len(d.Hosts) != 3 {
+		t.Fatalf("wrong hosts, got=%+v", d.Hosts)
+	}
+	web := d.Hosts[0]
+	if web.Host != "192.0.2.10" || len(web.Added) != 1 || len(web.Resolved) != 2 || len(web.Persisting) != 1 {
+		t.Fatalf("wrong web diff, got=%+v", web)
+	}
+	if web.Resolved[0].PluginID != 142960 || web.Resolved[1].Port != 22 || web.Added[0].Port != 2222 {
+		t.Errorf("wrong web findings, resolved=%+v added=%+v", web.Resolved, web.Added)
+	}
+	if db := d.Hosts[1]; len(db.Persisting) != 1 || len(db.Added)+len(db.Resolved) != 0 {
+		t.Errorf("wrong db diff, got=%+v", db)
+	}
+	if newHost := d.Hosts[2]; newHost.Host != "192.0.2.13" || len(newHost.Added) != 1 {
+		t.Errorf("wrong new host diff, got=%+v", newHost)
+	}
+	if d.Added != (nessie.SeverityCounts{Info: 1, Medium: 1}) || d.Resolved != (nessie.SeverityCounts{Medium: 1, Critical: 1}) || d.Persisting.Total() != 2 {
+		t.Errorf("wrong summary, added=%v resolved=%v persisting=%v", d.Added, d.Resolved, d.Persisting)
+	}
+}
+
+func TestDiffScanHistories(t *testing.T) {
+	details := &nessie.ScanDetailsResp{History: []nessie.History{
+		{HistoryID: 10, Status: "completed"},
+		{HistoryID: 11, Status: "completed"},
+		{HistoryID: 12, Status: "running"},
+	}}
+	runs := map[string]interface{}{
+		"/scans/42?history_id=10":         &nessie.ScanDetailsResp{Hosts: []nessie.Host{{HostID: 1, Hostname: "web"}}},
+		"/scans/42?history_id=11":         &nessie.ScanDetailsResp{Hosts: []nessie.Host{{HostID: 7, Hostname: "web"}}},
+		"/scans/42/hosts/1?history_id=10": &nessie.HostDetailsResp{Vulnerabilities: []nessie.HostVulnerability{{PluginID: 51192, Severity: 2}}},
+		"/scans/42/hosts/7?history_id=11": &nessie.HostDetailsResp{Vulnerabilities: []nessie.HostVulnerability{{PluginID: 51192, Severity: 2}}},
+		"/scans/42/hosts/1/plugins/51192?history_id=10": &nessie.PluginOutputResp{Outputs: []nessie.PluginOutput{
+			{Ports: []string{"443 / tcp / www", "8443 / tcp / www"}},
+		}},
+		"/scans/42/hosts/7/plugins/51192?history_id=11": &nessie.PluginOutputResp{Outputs: []nessie.PluginOutput{
+			{Ports: []string{"443 / tcp / www"}},
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/scans/42" && r.URL.RawQuery == "" {
+			json.NewEncoder(w).Encode(details)
+			return
+		}
+		if resp, ok := runs[r.URL.Path+"?"+r.URL.RawQuery]; ok {
+			json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+
+	d, err := DiffScanHistories(n, 42, 10, 11)
+	if err != nil {
+		t.Fatalf("cannot diff scan histories: %v", err)
+	}
+	if len(d.Hosts) != 1 || len(d.Hosts[0].Persisting) != 1 || len(d.Hosts[0].Resolved) != 1 || d.Hosts[0].Resolved[0].Port != 8443 {
+		t.Errorf("wrong diff, got=%+v", d.Hosts)
+	}
+
+	if _, err := DiffScanHistories(n, 42, 10, 12); err == nil {
+		t.Error("got no error for a running history")
+	}
+	if _, err := DiffScanHistories(n, 42, 10, 99); err == nil {
+		t.Error("got no error for an unknown history")
+	}
+}
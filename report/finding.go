@@ -0,0 +1,55 @@
+package report
+
+import "github.com/JerusJ/nessie"
+
+// Finding is a plugin result on a host port, whatever the format of the report it was read from.
+type Finding struct {
+	Host           string
+	Protocol       string
+	Port           int
+	ServiceName    string
+	PluginID       int64
+	PluginName     string
+	PluginFamily   string
+	Severity       nessie.Severity
+	RiskFactor     string
+	CVEs           []string
+	CPEs           []string
+	CVSSBaseScore  float64
+	CVSS3BaseScore float64
+	Synopsis       string
+	Description    string
+	Solution       string
+	SeeAlso        []string
+	PluginOutput   string
+}
+
+// FindingReader is implemented by the readers of every report format.
+type FindingReader interface {
+	// ReadFinding returns the next finding of the report, or io.EOF when there are no more findings.
+	ReadFinding() (*Finding, error)
+}
+
+// Finding returns the item as a finding on the given host.
+func (i *ReportItem) Finding(host *ReportHost) *Finding {
+	return &Finding{
+		Host:           host.Name,
+		Protocol:       i.Protocol,
+		Port:           i.Port,
+		ServiceName:    i.ServiceName,
+		PluginID:       i.PluginID,
+		PluginName:     i.PluginName,
+		PluginFamily:   i.PluginFamily,
+		Severity:       i.Severity,
+		RiskFactor:     i.RiskFactor,
+		CVEs:           i.CVEs,
+		CPEs:           i.CPEs,
+		CVSSBaseScore:  i.CVSSBaseScore,
+		CVSS3BaseScore: i.CVSS3BaseScore,
+		Synopsis:       i.Synopsis,
+		Description:    i.Description,
+		Solution:       i.Solution,
+		SeeAlso:        i.SeeAlso,
+		PluginOutput:   i.PluginOutput,
+	}
+}
@@ -0,0 +1,421 @@
+// Package report parses the reports exported by Nessus.
+//
+// The .nessus (NessusClientData_v2) parser streams the XML so that reports of
+// several gigabytes can be read host by host, finding by finding, in bounded memory.
+// The CSV parser reads the same findings back from CSV exports, both readers
+// implement FindingReader.
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// Policy is the scan policy embedded at the top of a .nessus report.
+type Policy struct {
+	Name              string             `xml:"policyName"`
+	Comments          string             `xml:"policyComments"`
+	ServerPreferences []Preference       `xml:"Preferences>ServerPreferences>preference"`
+	PluginPreferences []PluginPreference `xml:"Preferences>PluginsPreferences>item"`
+	FamilySelection   []FamilySelection  `xml:"FamilySelection>FamilyItem"`
+	PluginSelection   []PluginSelection  `xml:"IndividualPluginSelection>PluginItem"`
+}
+
+// Preference is a scanner preference of a policy.
+type Preference struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+}
+
+// PluginPreference is a plugin preference of a policy.
+type PluginPreference struct {
+	PluginName      string `xml:"pluginName"`
+	PluginID        int64  `xml:"pluginId"`
+	FullName        string `xml:"fullName"`
+	PreferenceName  string `xml:"preferenceName"`
+	PreferenceType  string `xml:"preferenceType"`
+	PreferenceValue string `xml:"preferenceValues"`
+	SelectedValue   string `xml:"selectedValue"`
+}
+
+// FamilySelection tells whether a plugin family is enabled in a policy.
+type FamilySelection struct {
+	FamilyName string `xml:"FamilyName"`
+	Status     string `xml:"Status"`
+}
+
+// PluginSelection tells whether a single plugin is enabled in a policy.
+type PluginSelection struct {
+	PluginID   int64  `xml:"PluginId"`
+	PluginName string `xml:"PluginName"`
+	Family     string `xml:"Family"`
+	Status     string `xml:"Status"`
+}
+
+// ReportHost is a scanned host, its findings are read with Reader.NextItem.
+type ReportHost struct {
+	Name       string
+	Properties HostProperties
+}
+
+// HostProperties are the tags nessus collected about a host, e.g. host-ip or operating-system.
+type HostProperties map[string]string
+
+// IP returns the IP address of the host.
+func (p HostProperties) IP() string { return p["host-ip"] }
+
+// FQDN returns the fully qualified domain name of the host.
+func (p HostProperties) FQDN() string { return p["host-fqdn"] }
+
+// NetBIOSName returns the NetBIOS name of the host.
+func (p HostProperties) NetBIOSName() string { return p["netbios-name"] }
+
+// OS returns the detected operating system of the host.
+func (p HostProperties) OS() string { return p["operating-system"] }
+
+// MACAddress returns the MAC address of the host.
+func (p HostProperties) MACAddress() string { return p["mac-address"] }
+
+// Start returns when the scan of the host started, as written by nessus.
+func (p HostProperties) Start() string { return p["HOST_START"] }
+
+// End returns when the scan of the host ended, as written by nessus.
+func (p HostProperties) End() string { return p["HOST_END"] }
+
+// ReportItem is a finding of a plugin on a host port.
+type ReportItem struct {
+	Port                   int
+	ServiceName            string
+	Protocol               string
+	Severity               nessie.Severity
+	PluginID               int64
+	PluginName             string
+	PluginFamily           string
+	PluginType             string
+	RiskFactor             string
+	Synopsis               string
+	Description            string
+	Solution               string
+	PluginOutput           string
+	SeeAlso                []string
+	CVEs                   []string
+	BIDs                   []string
+	XRefs                  []string
+	CPEs                   []string
+	CVSSBaseScore          float64
+	CVSSVector             string
+	CVSS3BaseScore         float64
+	CVSS3Vector            string
+	ExploitAvailable       bool
+	PluginPublicationDate  string
+	PluginModificationDate string
+}
+
+type reportItemXML struct {
+	Port                   int             `xml:"port,attr"`
+	ServiceName            string          `xml:"svc_name,attr"`
+	Protocol               string          `xml:"protocol,attr"`
+	Severity               nessie.Severity `xml:"severity,attr"`
+	PluginID               int64           `xml:"pluginID,attr"`
+	PluginName             string          `xml:"pluginName,attr"`
+	PluginFamily           string          `xml:"pluginFamily,attr"`
+	PluginType             string          `xml:"plugin_type"`
+	RiskFactor             string          `xml:"risk_factor"`
+	Synopsis               string          `xml:"synopsis"`
+	Description            string          `xml:"description"`
+	Solution               string          `xml:"solution"`
+	PluginOutput           string          `xml:"plugin_output"`
+	SeeAlso                string          `xml:"see_also"`
+	CVEs                   []string        `xml:"cve"`
+	BIDs                   []string        `xml:"bid"`
+	XRefs                  []string        `xml:"xref"`
+	CPEs                   []string        `xml:"cpe"`
+	CVSSBaseScore          string          `xml:"cvss_base_score"`
+	CVSSVector             string          `xml:"cvss_vector"`
+	CVSS3BaseScore         string          `xml:"cvss3_base_score"`
+	CVSS3Vector            string          `xml:"cvss3_vector"`
+	ExploitAvailable       string          `xml:"exploit_available"`
+	PluginPublicationDate  string          `xml:"plugin_publication_date"`
+	PluginModificationDate string          `xml:"plugin_modification_date"`
+}
+
+func (x *reportItemXML) item() (*ReportItem, error) {
+	item := &ReportItem{
+		Port:                   x.Port,
+		ServiceName:            x.ServiceName,
+		Protocol:               x.Protocol,
+		Severity:               x.Severity,
+		PluginID:               x.PluginID,
+		PluginName:             x.PluginName,
+		PluginFamily:           x.PluginFamily,
+		PluginType:             x.PluginType,
+		RiskFactor:             x.RiskFactor,
+		Synopsis:               x.Synopsis,
+		Description:            x.Description,
+		Solution:               x.Solution,
+		PluginOutput:           x.PluginOutput,
+		CVEs:                   x.CVEs,
+		BIDs:                   x.BIDs,
+		XRefs:                  x.XRefs,
+		CPEs:                   x.CPEs,
+		CVSSVector:             x.CVSSVector,
+		CVSS3Vector:            x.CVSS3Vector,
+		ExploitAvailable:       x.ExploitAvailable == "true",
+		PluginPublicationDate:  x.PluginPublicationDate,
+		PluginModificationDate: x.PluginModificationDate,
+	}
+	for _, s := range strings.Split(x.SeeAlso, "\n") {
+		if s = strings.TrimSpace(s); s != "" {
+			item.SeeAlso = append(item.SeeAlso, s)
+		}
+	}
+	var err error
+	if item.CVSSBaseScore, err = parseScore(x.CVSSBaseScore); err != nil {
+		return nil, fmt.Errorf("plugin %d: invalid cvss_base_score: %v", x.PluginID, err)
+	}
+	if item.CVSS3BaseScore, err = parseScore(x.CVSS3BaseScore); err != nil {
+		return nil, fmt.Errorf("plugin %d: invalid cvss3_base_score: %v", x.PluginID, err)
+	}
+	return item, nil
+}
+
+func parseScore(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+type hostPropertiesXML struct {
+	Tags []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"tag"`
+}
+
+// Reader reads a .nessus report one host and one finding at a time.
+//
+// NextHost returns the next host of the report, NextItem then returns the
+// findings of that host until io.EOF. Calling NextHost before all the findings
+// of the current host have been read skips them.
+type Reader struct {
+	dec        *xml.Decoder
+	policy     *Policy
+	reportName string
+	inHost     bool
+	pending    *ReportItem
+	// host is the host returned by the last call to NextHost.
+	host *ReportHost
+}
+
+// NewReader returns a reader of the .nessus report read from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: xml.NewDecoder(r)}
+}
+
+// Policy returns the policy of the report, it is available once the first host has been read.
+func (r *Reader) Policy() *Policy {
+	return r.policy
+}
+
+// ReportName returns the name of the report, it is available once the first host has been read.
+func (r *Reader) ReportName() string {
+	return r.reportName
+}
+
+// NextHost returns the next host of the report, or io.EOF when there are no more hosts.
+func (r *Reader) NextHost() (*ReportHost, error) {
+	for r.inHost {
+		if _, err := r.NextItem(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "NessusClientData_v2":
+		case "Policy":
+			r.policy = &Policy{}
+			if err := r.dec.DecodeElement(r.policy, &start); err != nil {
+				return nil, err
+			}
+		case "Report":
+			r.reportName = attr(start, "name")
+		case "ReportHost":
+			host, err := r.readHost(start)
+			r.host = host
+			return host, err
+		default:
+			if err := r.dec.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readHost reads the host properties, which come before the findings of the host.
+func (r *Reader) readHost(start xml.StartElement) (*ReportHost, error) {
+	host := &ReportHost{Name: attr(start, "name"), Properties: HostProperties{}}
+	r.inHost = true
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "HostProperties":
+				var props hostPropertiesXML
+				if err := r.dec.DecodeElement(&props, &t); err != nil {
+					return nil, err
+				}
+				for _, tag := range props.Tags {
+					host.Properties[tag.Name] = tag.Value
+				}
+				return host, nil
+			case "ReportItem":
+				// No properties, keep the finding for the next call to NextItem.
+				item, err := r.decodeItem(t)
+				if err != nil {
+					return nil, err
+				}
+				r.pending = item
+				return host, nil
+			default:
+				if err := r.dec.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "ReportHost" {
+				r.inHost = false
+				return host, nil
+			}
+		}
+	}
+}
+
+// NextItem returns the next finding of the current host, or io.EOF when the host has no more findings.
+func (r *Reader) NextItem() (*ReportItem, error) {
+	if r.pending != nil {
+		item := r.pending
+		r.pending = nil
+		return item, nil
+	}
+	if !r.inHost {
+		return nil, io.EOF
+	}
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "ReportItem" {
+				return r.decodeItem(t)
+			}
+			if err := r.dec.Skip(); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			if t.Name.Local == "ReportHost" {
+				r.inHost = false
+				return nil, io.EOF
+			}
+		}
+	}
+}
+
+func (r *Reader) decodeItem(start xml.StartElement) (*ReportItem, error) {
+	var x reportItemXML
+	if err := r.dec.DecodeElement(&x, &start); err != nil {
+		return nil, err
+	}
+	return x.item()
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, for reports truncated in the middle of a host.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadFinding returns the next finding of the report, or io.EOF when there are no more findings.
+// It reads the report host by host, it must not be mixed with calls to NextHost and NextItem.
+func (r *Reader) ReadFinding() (*Finding, error) {
+	for {
+		if r.host != nil {
+			item, err := r.NextItem()
+			if err == nil {
+				return item.Finding(r.host), nil
+			}
+			if err != io.EOF {
+				return nil, err
+			}
+		}
+		if _, err := r.NextHost(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Walk calls fn for every finding of every host of the report, stopping at the first error.
+// Hosts without findings are passed once with a nil item.
+func (r *Reader) Walk(fn func(host *ReportHost, item *ReportItem) error) error {
+	for {
+		host, err := r.NextHost()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var seen bool
+		for {
+			item, err := r.NextItem()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			seen = true
+			if err := fn(host, item); err != nil {
+				return err
+			}
+		}
+		if !seen {
+			if err := fn(host, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	f, err := os.Open("testdata/sample.nessus")
+	if err != nil {
+		t.Fatalf("cannot open report: %v", err)
+	}
+	defer f.Close()
+	r := NewReader(f)
+
+	host, err := r.NextHost()
+	if err != nil {
+		t.Fatalf("cannot read first host: %v", err)
+	}
+	if r.ReportName() != "Weekly staging" {
+		t.Errorf("wrong report name, got=%q", r.ReportName())
+	}
+	policy := r.Policy()
+	if policy == nil || policy.Name != "Basic Network Scan" || len(policy.ServerPreferences) != 2 ||
+		len(policy.PluginPreferences) != 1 || len(policy.FamilySelection) != 1 || len(policy.PluginSelection) != 1 {
+		t.Fatalf("wrong policy, got=%+v", policy)
+	}
+	if host.Name != "192.0.2.10" || host.Properties.IP() != "192.0.2.10" || host.Properties.FQDN() != "web.staging.example.com" ||
+		host.Properties.OS() != "Linux Kernel 5.4 on Ubuntu 20.04" || host.Properties.Start() == "" || host.Properties.End() == "" {
+		t.Errorf("wrong host, got=%+v", host)
+	}
+
+	if _, err := r.NextItem(); err != nil {
+		t.Fatalf("cannot read first item: %v", err)
+	}
+	item, err := r.NextItem()
+	if err != nil {
+		t.Fatalf("cannot read second item: %v", err)
+	}
+	if item.PluginID != 142960 || item.Port != 443 || item.Protocol != "tcp" || item.ServiceName != "www" || item.Severity != 4 ||
+		item.PluginFamily != "Web Servers" || item.RiskFactor != "High" || !item.ExploitAvailable {
+		t.Errorf("wrong item, got=%+v", item)
+	}
+	if item.CVSSBaseScore != 7.5 || item.CVSS3BaseScore != 9.8 || !strings.HasPrefix(item.CVSS3Vector, "CVSS:3.0/") {
+		t.Errorf("wrong cvss, got=%v %v %q", item.CVSSBaseScore, item.CVSS3BaseScore, item.CVSS3Vector)
+	}
+	if len(item.CVEs) != 2 || item.CVEs[0] != "CVE-2020-9490" || len(item.SeeAlso) != 2 || len(item.XRefs) != 1 {
+		t.Errorf("wrong references, got cves=%v see_also=%v xrefs=%v", item.CVEs, item.SeeAlso, item.XRefs)
+	}
+	if !strings.Contains(item.PluginOutput, "Fixed version     : 2.4.46") {
+		t.Errorf("wrong plugin output, got=%q", item.PluginOutput)
+	}
+
+	// Skip the last finding of the first host.
+	host, err = r.NextHost()
+	if err != nil || host.Name != "192.0.2.11" || host.Properties.NetBIOSName() != "DB01" {
+		t.Fatalf("wrong second host, got=%+v, err=%v", host, err)
+	}
+	if item, err := r.NextItem(); err != nil || item.PluginID != 18405 {
+		t.Fatalf("wrong item, got=%+v, err=%v", item, err)
+	}
+	if _, err := r.NextItem(); err != io.EOF {
+		t.Fatalf("expected end of host, got=%v", err)
+	}
+
+	host, err = r.NextHost()
+	if err != nil || host.Name != "192.0.2.12" {
+		t.Fatalf("wrong third host, got=%+v, err=%v", host, err)
+	}
+	if _, err := r.NextItem(); err != io.EOF {
+		t.Fatalf("expected host without findings, got=%v", err)
+	}
+	if _, err := r.NextHost(); err != io.EOF {
+		t.Fatalf("expected end of report, got=%v", err)
+	}
+}
+
+func TestReaderWalk(t *testing.T) {
+	f, err := os.Open("testdata/sample.nessus")
+	if err != nil {
+		t.Fatalf("cannot open report: %v", err)
+	}
+	defer f.Close()
+
+	var items, empty int
+	err = NewReader(f).Walk(func(host *ReportHost, item *ReportItem) error {
+		if item == nil {
+			empty++
+			return nil
+		}
+		items++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot walk report: %v", err)
+	}
+	if items != 4 || empty != 1 {
+		t.Errorf("wrong walk, got items=%d empty hosts=%d", items, empty)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	truncated := `<NessusClientData_v2><Report name="x"><ReportHost name="h"><HostProperties></HostProperties><ReportItem port="0" pluginID="1">`
+	r := NewReader(strings.NewReader(truncated))
+	if _, err := r.NextHost(); err != nil {
+		t.Fatalf("cannot read host: %v", err)
+	}
+	if _, err := r.NextItem(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for a truncated report, got=%v", err)
+	}
+
+	bad := `<NessusClientData_v2><Report name="x"><ReportHost name="h"><ReportItem pluginID="1"><cvss_base_score>high</cvss_base_score></ReportItem></ReportHost></Report></NessusClientData_v2>`
+	r = NewReader(strings.NewReader(bad))
+	if _, err := r.NextHost(); err == nil {
+		t.Error("expected an error for an invalid cvss score")
+	}
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/JerusJ/nessie"
+)
+
+// ScanFindings returns the findings of a scan fetched through the API.
+//
+// hosts are the HostDetails of the scan hosts keyed by host ID, hosts without
+// details are skipped. plugins are optional PluginDetails keyed by plugin ID,
+// used to fill the descriptions, scores and CVEs of the findings.
+// The host details do not tell on which ports a plugin fired, Port is always
+// 0. Diff fetches the PluginOutput of each finding for its ports.
+func ScanFindings(details *nessie.ScanDetailsResp, hosts map[int64]*nessie.HostDetailsResp, plugins map[int64]*nessie.PluginDetails) []*Finding {
+	var findings []*Finding
+	for _, host := range details.Hosts {
+		hostDetails, ok := hosts[host.HostID]
+		if !ok {
+			continue
+		}
+		for _, vuln := range hostDetails.Vulnerabilities {
+			f := &Finding{
+				Host:         host.Hostname,
+				PluginID:     vuln.PluginID,
+				PluginName:   vuln.PluginName,
+				PluginFamily: vuln.PluginFamily,
+				Severity:     vuln.Severity,
+			}
+			if plugin, ok := plugins[vuln.PluginID]; ok {
+				applyPluginDetails(f, plugin)
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func applyPluginDetails(f *Finding, plugin *nessie.PluginDetails) {
+	if f.PluginFamily == "" {
+		f.PluginFamily = plugin.FamilyName
+	}
+	for _, attr := range plugin.Attrs {
+		switch attr.Name {
+		case "synopsis":
+			f.Synopsis = attr.Val
+		case "description":
+			f.Description = attr.Val
+		case "solution":
+			f.Solution = attr.Val
+		case "risk_factor":
+			f.RiskFactor = attr.Val
+		case "cve":
+			f.CVEs = append(f.CVEs, attr.Val)
+		case "cpe":
+			f.CPEs = append(f.CPEs, attr.Val)
+		case "see_also":
+			for _, s := range strings.Split(attr.Val, "\n") {
+				if s = strings.TrimSpace(s); s != "" {
+					f.SeeAlso = append(f.SeeAlso, s)
+				}
+			}
+		case "cvss_base_score":
+			f.CVSSBaseScore, _ = strconv.ParseFloat(attr.Val, 64)
+		case "cvss3_base_score":
+			f.CVSS3BaseScore, _ = strconv.ParseFloat(attr.Val, 64)
+		}
+	}
+}
+
+// ReadAll reads all the remaining findings of r.
+func ReadAll(r FindingReader) ([]*Finding, error) {
+	var findings []*Finding
+	for {
+		f, err := r.ReadFinding()
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+}
@@ -1,10 +1,16 @@
 package nessie
 
+import "encoding/json"
+
 type loginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+type uploadPluginArchiveRequest struct {
+	FileName string `json:"filename"`
+}
+
 type createUserRequest struct {
 	Username    string `json:"username"`
 	Password    string `json:"password"`
@@ -29,26 +35,33 @@ type NewScanRequest struct {
 	Settings ScanSettingsRequest `json:"settings"`
 }
 type ScanSettingsRequest struct {
-	Acls           []Acls        `json:"acls"`
-	Emails         string        `json:"emails"`
-	FilterType     string        `json:"filter_type"`
-	Filters        []interface{} `json:"filters"`
-	Launch         string        `json:"launch"`
-	LaunchNow      bool          `json:"launch_now"`
-	Enabled        bool          `json:"enabled"`
-	UseDashboard   string        `json:"use_dashboard"`
-	Name           string        `json:"name"`
-	Description    string        `json:"description"`
-	FolderID       int64         `json:"folder_id"`
-	ScannerID      int64         `json:"scanner_id"`
-	AgentGroupID   []string      `json:"agent_group_id"`
-	ScanTimeWindow int64         `json:"scan_time_window"`
-	PolicyID       int64         `json:"policy_id"`
-	TextTargets    string        `json:"text_targets"`
-	FileTargets    string        `json:"file_targets"`
-	RRules         string        `json:"rrules"`
-	TimeZone       string        `json:"timezone"`
-	StartTime      string        `json:"starttime"`
+	Acls                      []Acls               `json:"acls"`
+	Emails                    string               `json:"emails"`
+	FilterType                string               `json:"filter_type"`
+	Filters                   []NotificationFilter `json:"filters"`
+	AttachReport              int                  `json:"attach_report,omitempty"`
+	AttachedReportType        string               `json:"attached_report_type,omitempty"`
+	AttachedReportMaximumSize int64                `json:"attached_report_maximum_size,omitempty"`
+	Launch                    string               `json:"launch"`
+	LaunchNow                 bool                 `json:"launch_now"`
+	Enabled                   bool                 `json:"enabled"`
+	UseDashboard              string               `json:"use_dashboard"`
+	Name                      string               `json:"name"`
+	Description               string               `json:"description"`
+	FolderID                  int64                `json:"folder_id"`
+	ScannerID                 int64                `json:"scanner_id"`
+	AgentGroupID              []string             `json:"agent_group_id"`
+	ScanTimeWindow            int64                `json:"scan_time_window"`
+	PolicyID                  int64                `json:"policy_id"`
+	TextTargets               string               `json:"text_targets"`
+	FileTargets               string               `json:"file_targets"`
+	RRules                    string               `json:"rrules"`
+	TimeZone                  string               `json:"timezone"`
+	StartTime                 string               `json:"starttime"`
+}
+
+type testEmailRequest struct {
+	To string `json:"to"`
 }
 
 type createFolderRequest struct {
@@ -60,8 +73,57 @@ type editFolderRequest struct {
 }
 
 type exportScanRequest struct {
-	Format     string `json:"format"`
-	TemplateID int64  `json:"template_id"`
+	Format         string                `json:"format"`
+	TemplateID     int64                 `json:"template_id"`
+	Chapters       string                `json:"chapters,omitempty"`
+	Password       string                `json:"password,omitempty"`
+	ReportContents *exportReportContents `json:"reportContents,omitempty"`
+	// Filters are flattened into the request as filter.<idx>.* keys.
+	Filters *FilterSet `json:"-"`
+}
+
+type exportReportContents struct {
+	CSVColumns map[string]bool `json:"csvColumns"`
+}
+
+func (r exportScanRequest) MarshalJSON() ([]byte, error) {
+	type plain exportScanRequest
+	j, err := json.Marshal(plain(r))
+	if err != nil {
+		return nil, err
+	}
+	params := r.Filters.Params()
+	if len(params) == 0 {
+		return j, nil
+	}
+	merged := map[string]interface{}{}
+	if err := json.Unmarshal(j, &merged); err != nil {
+		return nil, err
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	return json.Marshal(merged)
+}
+
+type setPermissionsRequest struct {
+	Acls []Permission `json:"acls"`
+}
+
+type importPolicyRequest struct {
+	File string `json:"file"`
+}
+
+type importScanRequest struct {
+	File     string `json:"file"`
+	FolderID int64  `json:"folder_id,omitempty"`
+}
+
+type createPluginRuleRequest struct {
+	PluginID int64  `json:"plugin_id"`
+	Type     string `json:"type"`
+	Host     string `json:"host"`
+	Date     string `json:"date,omitempty"`
 }
 
 type createGroupRequest struct {
@@ -1,5 +1,14 @@
 package nessie
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 // Editor resources.
 
 // Template is used to create scans or policies with predefined parameters.
@@ -136,18 +145,18 @@ type Rule struct {
 // Policies resources.
 
 type Policy struct {
-	ID                   int64  `json:"id"`
-	TemplateUUID         string `json:"template_uuid"`
-	Name                 string `json:"name"`
-	Desc                 string `json:"description"`
-	OwnerID              int64  `json:"owner_id"`
-	Owner                string `json:"owner"`
-	Shared               int64  `json:"shared"`
-	UserPerms            int64  `json:"user_permissions"`
-	CreationDate         int64  `json:"creation_date"`
-	LastModificationDate int64  `json:"last_modification_date"`
-	Visibility           string `json:"visibility"`
-	NoTarget             string `json:"no_target"`
+	ID                   int64     `json:"id"`
+	TemplateUUID         string    `json:"template_uuid"`
+	Name                 string    `json:"name"`
+	Desc                 string    `json:"description"`
+	OwnerID              int64     `json:"owner_id"`
+	Owner                string    `json:"owner"`
+	Shared               int64     `json:"shared"`
+	UserPerms            int64     `json:"user_permissions"`
+	CreationDate         Timestamp `json:"creation_date"`
+	LastModificationDate Timestamp `json:"last_modification_date"`
+	Visibility           string    `json:"visibility"`
+	NoTarget             string    `json:"no_target"`
 }
 
 // Scanners resources.
@@ -168,39 +177,42 @@ type Scanner struct {
 
 // Scan resource.
 type Scan struct {
-	ID                        int64       `json:"id"`
-	UUID                      string      `json:"uuid"`
-	Name                      string      `json:"name"`
-	Owner                     string      `json:"owner"`
-	Shared                    int         `json:"shared"`
-	UserPermissions           int64       `json:"user_permissions"`
-	CreationDate              int64       `json:"creation_date"`
-	LastModificationDate      int64       `json:"last_modification_date"`
-	StartTime                 string      `json:"starttime"`
-	TimeZone                  string      `json:"timezone"`
-	RRules                    string      `json:"rrules"`
-	ContainerID               int         `json:"container_id"`
-	Description               string      `json:"description"`
-	PolicyID                  int         `json:"policy_id"`
-	ScannerID                 int         `json:"scanner_id"`
-	Emails                    string      `json:"emails"`
-	AttachReport              int         `json:"attach_report"`
-	AttachedReportMaximumSize int         `json:"attached_report_maximum_size"`
-	AttachedReportType        interface{} `json:"attached_report_type"`
-	Sms                       interface{} `json:"sms"`
-	Enabled                   int         `json:"enabled"`
-	UseDashboard              int         `json:"use_dashboard"`
-	DashboardFile             interface{} `json:"dashboard_file"`
-	LiveResults               int         `json:"live_results"`
-	ScanTimeWindow            int         `json:"scan_time_window"`
-	CustomTargets             string      `json:"custom_targets"`
-	Migrated                  int         `json:"migrated"`
-	LastScheduledRun          string      `json:"last_scheduled_run"`
-	NotificationFilters       interface{} `json:"notification_filters"`
-	TagID                     int         `json:"tag_id"`
-	DefaultPermisssions       int         `json:"default_permisssions"`
-	OwnerID                   int         `json:"owner_id"`
-	Type                      string      `json:"type"`
+	ID                        int64                `json:"id"`
+	UUID                      string               `json:"uuid"`
+	Name                      string               `json:"name"`
+	Status                    string               `json:"status"`
+	Owner                     string               `json:"owner"`
+	Shared                    int                  `json:"shared"`
+	UserPermissions           int64                `json:"user_permissions"`
+	CreationDate              Timestamp            `json:"creation_date"`
+	LastModificationDate      Timestamp            `json:"last_modification_date"`
+	StartTime                 ScanStartTime        `json:"starttime"`
+	TimeZone                  string               `json:"timezone"`
+	RRules                    string               `json:"rrules"`
+	ContainerID               int                  `json:"container_id"`
+	FolderID                  int64                `json:"folder_id"`
+	Description               string               `json:"description"`
+	PolicyID                  int                  `json:"policy_id"`
+	ScannerID                 int                  `json:"scanner_id"`
+	Emails                    string               `json:"emails"`
+	AttachReport              int                  `json:"attach_report"`
+	AttachedReportMaximumSize int                  `json:"attached_report_maximum_size"`
+	AttachedReportType        string               `json:"attached_report_type"`
+	Sms                       interface{}          `json:"sms"`
+	Enabled                   int                  `json:"enabled"`
+	UseDashboard              int                  `json:"use_dashboard"`
+	DashboardFile             interface{}          `json:"dashboard_file"`
+	LiveResults               int                  `json:"live_results"`
+	ScanTimeWindow            int                  `json:"scan_time_window"`
+	CustomTargets             string               `json:"custom_targets"`
+	Migrated                  int                  `json:"migrated"`
+	LastScheduledRun          string               `json:"last_scheduled_run"`
+	NotificationFilters       []NotificationFilter `json:"notification_filters"`
+	FilterType                string               `json:"filter_type"`
+	TagID                     int                  `json:"tag_id"`
+	DefaultPermisssions       int                  `json:"default_permisssions"`
+	OwnerID                   int                  `json:"owner_id"`
+	Type                      string               `json:"type"`
 }
 
 type Host struct {
@@ -220,47 +232,48 @@ type Host struct {
 	Score                 int64  `json:"score"`
 }
 
-// type Note struct {
-// 	Title    string `json:"title"`
-// 	Message  string `json:"message"`
-// 	Severity int64  `json:"severity"`
-// }
+type Note struct {
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Severity FlexInt64 `json:"severity"`
+}
 
 type Remediation struct {
-	Value       string `json:"value"`
-	Remediation string `json:"remediation"`
-	NumHosts    int64  `json:"hosts"`
-	NumVulns    string `json:"vulns"`
+	Value       string    `json:"value"`
+	Remediation string    `json:"remediation"`
+	NumHosts    int64     `json:"hosts"`
+	NumVulns    FlexInt64 `json:"vulns"`
 }
 
 type History struct {
-	HistoryID            int64  `json:"history_id"`
-	UUID                 string `json:"uuid"`
-	OwnerID              int64  `json:"owner_id"`
-	Status               string `json:"status"`
-	CreationDate         int64  `json:"creation_date"`
-	LastModificationDate int64  `json:"last_modification_date"`
+	HistoryID            int64     `json:"history_id"`
+	UUID                 string    `json:"uuid"`
+	OwnerID              int64     `json:"owner_id"`
+	Status               string    `json:"status"`
+	CreationDate         Timestamp `json:"creation_date"`
+	LastModificationDate Timestamp `json:"last_modification_date"`
 }
 
 type Vulnerability struct {
-	PluginID     int64  `json:"plugin_id"`
-	PluginName   string `json:"plugin_name"`
-	PluginFamily string `json:"plugin_family"`
-	Count        int64  `json:"count"`
-	VulnIdx      int64  `json:"vuln_index"`
-	SeverityIdx  int64  `json:"severity_index"`
+	PluginID     int64    `json:"plugin_id"`
+	PluginName   string   `json:"plugin_name"`
+	PluginFamily string   `json:"plugin_family"`
+	Count        int64    `json:"count"`
+	VulnIdx      int64    `json:"vuln_index"`
+	SeverityIdx  int64    `json:"severity_index"`
+	Severity     Severity `json:"severity"`
 }
 
 type HostVulnerability struct {
-	HostID       int64  `json:"host_id"`
-	Hostname     string `json:"hostname"`
-	PluginID     int64  `json:"plugin_id"`
-	PluginName   string `json:"plugin_name"`
-	PluginFamily string `json:"plugin_family"`
-	Count        int64  `json:"count"`
-	VulnIdx      int64  `json:"vuln_index"`
-	SeverityIdx  int64  `json:"severity_index"`
-	Severity     int64  `json:"severity"`
+	HostID       int64    `json:"host_id"`
+	Hostname     string   `json:"hostname"`
+	PluginID     int64    `json:"plugin_id"`
+	PluginName   string   `json:"plugin_name"`
+	PluginFamily string   `json:"plugin_family"`
+	Count        int64    `json:"count"`
+	VulnIdx      int64    `json:"vuln_index"`
+	SeverityIdx  int64    `json:"severity_index"`
+	Severity     Severity `json:"severity"`
 }
 
 type HostCompliance struct {
@@ -277,8 +290,77 @@ type HostCompliance struct {
 type PluginOutput struct {
 	PluginOutput string   `json:"plugin_output"`
 	Hosts        string   `json:"hosts"`
-	Severity     int64    `json:"severity"`
-	Ports        []string `json:"ports"`
+	Severity     Severity `json:"severity"`
+	// Ports are "<port> / <protocol> / <service>" keys, e.g. "443 / tcp / www".
+	Ports []string `json:"ports"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Nessus sends the ports as the
+// keys of an object, they are decoded sorted.
+func (o *PluginOutput) UnmarshalJSON(data []byte) error {
+	type plain PluginOutput
+	var out struct {
+		plain
+		Ports json.RawMessage `json:"ports"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+	*o = PluginOutput(out.plain)
+	ports := bytes.TrimSpace(out.Ports)
+	if len(ports) == 0 || bytes.Equal(ports, jsonNull) {
+		return nil
+	}
+	if ports[0] != '{' {
+		return json.Unmarshal(ports, &o.Ports)
+	}
+	var byKey map[string]json.RawMessage
+	if err := json.Unmarshal(ports, &byKey); err != nil {
+		return err
+	}
+	for k := range byKey {
+		o.Ports = append(o.Ports, k)
+	}
+	sort.Strings(o.Ports)
+	return nil
+}
+
+// PortKeys returns the parsed ports the output was found on.
+func (o PluginOutput) PortKeys() []PortKey {
+	var keys []PortKey
+	for _, k := range o.Ports {
+		keys = append(keys, ParsePortKey(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+// PortKey identifies a port a plugin output was found on.
+type PortKey struct {
+	Port     int
+	Protocol string
+	Service  string
+}
+
+// ParsePortKey parses a "<port> / <protocol> / <service>" port key.
+// Malformed parts are left empty.
+func ParsePortKey(s string) PortKey {
+	var k PortKey
+	parts := strings.Split(s, "/")
+	if len(parts) > 0 {
+		k.Port, _ = strconv.Atoi(strings.TrimSpace(parts[0]))
+	}
+	if len(parts) > 1 {
+		k.Protocol = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		k.Service = strings.TrimSpace(parts[2])
+	}
+	return k
+}
+
+func (k PortKey) String() string {
+	return fmt.Sprintf("%d / %s / %s", k.Port, k.Protocol, k.Service)
 }
 
 type TimeZone struct {
@@ -286,38 +368,82 @@ type TimeZone struct {
 	Val  string `json:"value"`
 }
 
+// Settings resources.
+
+// AdvancedSetting is a single global scanner preference.
+type AdvancedSetting struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+// ProxySettings is the proxy used by nessus for plugin updates and remote scanners.
+type ProxySettings struct {
+	Proxy         string `json:"proxy"`
+	ProxyPort     int64  `json:"proxy_port"`
+	ProxyUsername string `json:"proxy_username"`
+	ProxyPassword string `json:"proxy_password"`
+	ProxyAuth     string `json:"proxy_auth"`
+	UserAgent     string `json:"user_agent"`
+}
+
+// MailSettings is the SMTP server used by nessus to send scan reports.
+type MailSettings struct {
+	Host       string `json:"smtp_host"`
+	Port       int64  `json:"smtp_port"`
+	From       string `json:"smtp_from"`
+	WWWHost    string `json:"smtp_www_host"`
+	Auth       string `json:"smtp_auth"`
+	User       string `json:"smtp_user"`
+	Password   string `json:"smtp_pass"`
+	Encryption string `json:"smtp_enc"`
+}
+
+// LDAPSettings is the LDAP server used by nessus to authenticate users.
+type LDAPSettings struct {
+	Host          string `json:"host"`
+	Port          int64  `json:"port"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	BaseDN        string `json:"base"`
+	Encryption    string `json:"encryption"`
+	UserAttribute string `json:"user_attribute,omitempty"`
+	CACert        string `json:"ca_cert,omitempty"`
+}
+
 // Sessions resources.
 
 type Session struct {
-	ID          int64    `json:"id"`
-	Username    string   `json:"username"`
-	Email       string   `json:"email"`
-	Name        string   `json:"name"`
-	Type        string   `json:"type"`
-	Perms       int64    `json:"permissions"`
-	LastLogin   int64    `json:"last_login"`
-	ContainerID int64    `json:"container_id"`
-	Groups      []string `json:"groups"`
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Perms       int64     `json:"permissions"`
+	LastLogin   Timestamp `json:"last_login"`
+	ContainerID int64     `json:"container_id"`
+	Groups      []string  `json:"groups"`
 }
 
 type User struct {
-	ID          int    `json:"id"`
-	Username    string `json:"username"`
-	Name        string `json:"name"`
-	Email       string `json:"email"`
-	Permissions int    `json:"permissions"`
-	LastLogin   int    `json:"lastlogin"`
-	Type        string `json:"type"`
+	ID          int       `json:"id"`
+	Username    string    `json:"username"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Permissions int       `json:"permissions"`
+	LastLogin   Timestamp `json:"lastlogin"`
+	Type        string    `json:"type"`
 }
 
 // AgentGroup The details of an agent group.
 type AgentGroup struct {
-	ID                   int64  `json:"id"`
-	Name                 string `json:"name"`
-	OwnerID              int64  `json:"owner_id"`
-	Owner                string `json:"owner"`
-	Shared               int    `json:"shared"`
-	UserPerms            int64  `json:"user_permissions"`
-	CreationDate         int64  `json:"creation_date"`
-	LastModificationDate int64  `json:"last_modification_date"`
+	ID                   int64     `json:"id"`
+	Name                 string    `json:"name"`
+	OwnerID              int64     `json:"owner_id"`
+	Owner                string    `json:"owner"`
+	Shared               int       `json:"shared"`
+	UserPerms            int64     `json:"user_permissions"`
+	CreationDate         Timestamp `json:"creation_date"`
+	LastModificationDate Timestamp `json:"last_modification_date"`
 }
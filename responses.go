@@ -1,5 +1,7 @@
 package nessie
 
+import "time"
+
 // loginResp is the internal response to login attemps.
 type loginResp struct {
 	Token string `json:"token"`
@@ -7,28 +9,52 @@ type loginResp struct {
 
 // ServerProperties is the structure returned by the ServerProperties() method.
 type ServerProperties struct {
-	Token           string `json:"token"`
-	NessusType      string `json:"nessus_type"`
-	NessusUIVersion string `json:"nessus_ui_version"`
-	ServerVersion   string `json:"server_version"`
-	Feed            string `json:"feed"`
-	Enterprise      bool   `json:"enterprise"`
-	LoadedPluginSet string `json:"loaded_plugin_set"`
-	ServerUUID      string `json:"server_uuid"`
-	Expiration      int64  `json:"expiration"`
+	Token           string    `json:"token"`
+	NessusType      string    `json:"nessus_type"`
+	NessusUIVersion string    `json:"nessus_ui_version"`
+	ServerVersion   string    `json:"server_version"`
+	Feed            string    `json:"feed"`
+	Enterprise      bool      `json:"enterprise"`
+	LoadedPluginSet string    `json:"loaded_plugin_set"`
+	ServerUUID      string    `json:"server_uuid"`
+	Expiration      Timestamp `json:"expiration"`
 	Notifications   []struct {
 		Type string `json:"type"`
 		Msg  string `json:"message"`
 	} `json:"notifications"`
+	// ExpirationTime is the number of days left before the license expires.
 	ExpirationTime int64 `json:"expiration_time"`
 	Capabilities   struct {
 		MultiScanner      bool `json:"multi_scanner"`
 		ReportEmailConfig bool `json:"report_email_config"`
 	} `json:"capabilities"`
-	PluginSet       string `json:"plugin_set"`
-	IdleTImeout     int64  `json:"idle_timeout"`
-	ScannerBoottime int64  `json:"scanner_boottime"`
-	LoginBanner     bool   `json:"login_banner"`
+	PluginSet       string    `json:"plugin_set"`
+	IdleTImeout     int64     `json:"idle_timeout"`
+	ScannerBoottime Timestamp `json:"scanner_boottime"`
+	LoginBanner     bool      `json:"login_banner"`
+}
+
+// pluginSetLayout is the format of the plugin set version, e.g. 202101021504.
+const pluginSetLayout = "200601021504"
+
+// LicenseExpired returns whether the license has expired at the given time.
+func (p *ServerProperties) LicenseExpired(now time.Time) bool {
+	return !p.Expiration.IsZero() && !now.Before(p.Expiration.Time)
+}
+
+// PluginSetTime returns the publication time of the loaded plugin set.
+func (p *ServerProperties) PluginSetTime() (time.Time, error) {
+	return time.Parse(pluginSetLayout, p.LoadedPluginSet)
+}
+
+// FeedStale returns whether the loaded plugin set is older than maxAge at the given time.
+// A plugin set that cannot be parsed (e.g. no feed was ever loaded) is considered stale.
+func (p *ServerProperties) FeedStale(now time.Time, maxAge time.Duration) bool {
+	published, err := p.PluginSetTime()
+	if err != nil {
+		return true
+	}
+	return now.Sub(published) > maxAge
 }
 
 // ServerStatus is the stucture returned  by the ServerStatus() method.
@@ -38,6 +64,10 @@ type ServerStatus struct {
 	MustDestroySession bool
 }
 
+type listAdvancedSettingsResp struct {
+	Preferences []AdvancedSetting `json:"preferences"`
+}
+
 type listUsersResp struct {
 	Users []User `json:"users"`
 }
@@ -59,9 +89,9 @@ type listPoliciesResp struct {
 }
 
 type ListScansResponse struct {
-	Folders   []Folder `json:"folders"`
-	Scans     []Scan   `json:"scans"`
-	Timestamp int64    `json:"timestamp"`
+	Folders   []Folder  `json:"folders"`
+	Scans     []Scan    `json:"scans"`
+	Timestamp Timestamp `json:"timestamp"`
 }
 
 type listTemplatesResp struct {
@@ -75,30 +105,30 @@ type startScanResp struct {
 type ScanDetailsResp struct {
 	UUID string `json:"scan_uuid"`
 	Info struct {
-		EditAllowed   bool   `json:"edit_allowed"`
-		Status        string `json:"status"`
-		Policy        string `json:"policy"`
-		PCICanUpload  bool   `json:"pci-can-upload"`
-		HasAuditTrail bool   `json:"hasaudittrail"`
-		ScanStart     int64  `json:"scan_start"`
-		FolderID      int64  `json:"folder_id"`
-		Targets       string `json:"targets"`
-		Timestamp     int64  `json:"timestamp"`
-		ObjectID      int64  `json:"object_id"`
-		ScannerName   string `json:"scanner_name"`
-		HasKB         bool   `json:"haskb"`
-		UUID          string `json:"uuid"`
-		HostCount     int64  `json:"hostcount"`
-		// TODO: why does this flip between string/int64?
-		// ScanEnd       int64  `json:"scan_end"`
-		Name      string `json:"name"`
-		UserPerms int64  `json:"user_permissions"`
-		Control   bool   `json:"control"`
+		EditAllowed   bool      `json:"edit_allowed"`
+		Status        string    `json:"status"`
+		Policy        string    `json:"policy"`
+		PCICanUpload  bool      `json:"pci-can-upload"`
+		HasAuditTrail bool      `json:"hasaudittrail"`
+		ScanStart     Timestamp `json:"scan_start"`
+		FolderID      int64     `json:"folder_id"`
+		Targets       string    `json:"targets"`
+		Timestamp     Timestamp `json:"timestamp"`
+		ObjectID      int64     `json:"object_id"`
+		ScannerName   string    `json:"scanner_name"`
+		HasKB         bool      `json:"haskb"`
+		UUID          string    `json:"uuid"`
+		HostCount     int64     `json:"hostcount"`
+		// ScanEnd is an epoch number or string, empty while the scan runs.
+		ScanEnd   FlexTime `json:"scan_end"`
+		Name      string   `json:"name"`
+		UserPerms int64    `json:"user_permissions"`
+		Control   bool     `json:"control"`
 	} `json:"info"`
 	Hosts     []Host `json:"hosts"`
 	CompHosts []Host `json:"comphosts"`
-	// TODO: this does not always return the same data!
-	// Notes        []Note `json:"notes"`
+	// Notes is an array, a single note or an object wrapping the array depending on the version.
+	Notes        FlexList[Note] `json:"notes"`
 	Remediations struct {
 		Remediation Remediation `json:"remediation"`
 	} `json:"remediations"`
@@ -112,6 +142,36 @@ type ScanDetailsResp struct {
 	Filters           []Filter        `json:"filters"`
 }
 
+// HostDetailsResp is the structure returned by the HostDetails() method.
+type HostDetailsResp struct {
+	Info struct {
+		HostStart   FlexTime `json:"host_start"`
+		HostEnd     FlexTime `json:"host_end"`
+		HostIP      string   `json:"host-ip"`
+		HostFQDN    string   `json:"host-fqdn"`
+		NetBIOSName string   `json:"netbios-name"`
+		MACAddress  string   `json:"mac-address"`
+		// OperatingSystem is a string or a list of strings depending on the version.
+		OperatingSystem FlexList[string] `json:"operating-system"`
+	} `json:"info"`
+	Vulnerabilities []HostVulnerability `json:"vulnerabilities"`
+	Compliance      []HostCompliance    `json:"compliance"`
+}
+
+// PluginOutputResp is the structure returned by the PluginOutput() method.
+type PluginOutputResp struct {
+	Info struct {
+		PluginDescription struct {
+			Severity         Severity    `json:"severity"`
+			PluginName       string      `json:"pluginname"`
+			PluginFamily     string      `json:"pluginfamily"`
+			PluginID         string      `json:"pluginid"`
+			PluginAttributes interface{} `json:"pluginattributes"`
+		} `json:"plugindescription"`
+	} `json:"info"`
+	Outputs []PluginOutput `json:"outputs"`
+}
+
 type tzResp struct {
 	Timezones []TimeZone `json:"timezones"`
 }
@@ -132,6 +192,10 @@ type listGroupsResp struct {
 	Groups []Group `json:"groups"`
 }
 
+type listPluginRulesResp struct {
+	PluginRules []Rule `json:"plugin_rules"`
+}
+
 type listAgentGroupsResp struct {
 	Groups []AgentGroup `json:"groups"`
 }
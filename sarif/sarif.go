@@ -0,0 +1,248 @@
+// Package sarif converts nessus findings to SARIF 2.1.0 logs, so that
+// infrastructure scans can be shown by code scanning tools.
+//
+// Plugins are mapped to rules, findings to results located on the host:port
+// logical location they were found on, or on the host without a port.
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/report"
+)
+
+const (
+	Version   = "2.1.0"
+	SchemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	toolName = "Nessus"
+	toolURI  = "https://www.tenable.com/products/nessus"
+)
+
+// Levels of a SARIF result.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+	LevelNone    = "none"
+)
+
+// Log is a SARIF log file.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is a single invocation of the tool.
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+	Version        string `json:"version,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule describes a nessus plugin.
+type Rule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name,omitempty"`
+	ShortDescription     *Message           `json:"shortDescription,omitempty"`
+	FullDescription      *Message           `json:"fullDescription,omitempty"`
+	Help                 *Message           `json:"help,omitempty"`
+	HelpURI              string             `json:"helpUri,omitempty"`
+	DefaultConfiguration *RuleConfiguration `json:"defaultConfiguration,omitempty"`
+	Properties           *PropertyBag       `json:"properties,omitempty"`
+}
+
+type RuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+// PropertyBag holds the properties understood by code scanning tools.
+type PropertyBag struct {
+	Tags             []string `json:"tags,omitempty"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is a finding of a plugin on a host port.
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type Location struct {
+	LogicalLocations []LogicalLocation `json:"logicalLocations"`
+}
+
+type LogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// Options tune the conversion.
+type Options struct {
+	// ToolVersion is the version of nessus, e.g. ServerProperties.ServerVersion.
+	ToolVersion string
+	// MinSeverity drops the less severe findings, nessie.SeverityLow drops the informational ones.
+	MinSeverity nessie.Severity
+}
+
+// Level returns the SARIF level of a nessus severity.
+func Level(severity nessie.Severity) string {
+	switch {
+	case severity.AtLeast(nessie.SeverityHigh):
+		return LevelError
+	case severity == nessie.SeverityMedium:
+		return LevelWarning
+	case severity == nessie.SeverityLow:
+		return LevelNote
+	default:
+		return LevelNone
+	}
+}
+
+// FromFindings converts findings to a SARIF log with a single run.
+func FromFindings(findings []*report.Finding, opts Options) *Log {
+	run := Run{
+		Tool: Tool{Driver: Driver{
+			Name:           toolName,
+			InformationURI: toolURI,
+			Version:        opts.ToolVersion,
+			Rules:          []Rule{},
+		}},
+		Results: []Result{},
+	}
+	ruleIndex := map[int64]int{}
+	for _, f := range findings {
+		if f.Severity < opts.MinSeverity {
+			continue
+		}
+		idx, ok := ruleIndex[f.PluginID]
+		if !ok {
+			idx = len(run.Tool.Driver.Rules)
+			ruleIndex[f.PluginID] = idx
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule(f))
+		}
+		run.Results = append(run.Results, result(f, idx))
+	}
+	return &Log{Schema: SchemaURI, Version: Version, Runs: []Run{run}}
+}
+
+// FromReport converts the findings of a parsed report to a SARIF log.
+func FromReport(r report.FindingReader, opts Options) (*Log, error) {
+	findings, err := report.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return FromFindings(findings, opts), nil
+}
+
+// FromScan converts a scan fetched through the API to a SARIF log, see report.ScanFindings.
+func FromScan(details *nessie.ScanDetailsResp, hosts map[int64]*nessie.HostDetailsResp, plugins map[int64]*nessie.PluginDetails, opts Options) *Log {
+	return FromFindings(report.ScanFindings(details, hosts, plugins), opts)
+}
+
+// Write writes the log as indented JSON.
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+func rule(f *report.Finding) Rule {
+	r := Rule{
+		ID:                   strconv.FormatInt(f.PluginID, 10),
+		Name:                 f.PluginName,
+		ShortDescription:     &Message{Text: f.PluginName},
+		DefaultConfiguration: &RuleConfiguration{Level: Level(f.Severity)},
+		Properties:           &PropertyBag{Tags: []string{"security"}},
+	}
+	if f.Synopsis != "" {
+		r.ShortDescription = &Message{Text: f.Synopsis}
+	}
+	if f.Description != "" {
+		r.FullDescription = &Message{Text: f.Description}
+	}
+	if f.Solution != "" {
+		r.Help = &Message{Text: f.Solution}
+	}
+	if len(f.SeeAlso) > 0 {
+		r.HelpURI = f.SeeAlso[0]
+	}
+	if f.PluginFamily != "" {
+		r.Properties.Tags = append(r.Properties.Tags, f.PluginFamily)
+	}
+	r.Properties.Tags = append(r.Properties.Tags, f.CVEs...)
+	if score := securitySeverity(f); score > 0 {
+		r.Properties.SecuritySeverity = strconv.FormatFloat(score, 'f', 1, 64)
+	}
+	return r
+}
+
+// securitySeverity returns the CVSS score of the finding, falling back to the
+// middle of the CVSS range of its severity when nessus did not score it.
+func securitySeverity(f *report.Finding) float64 {
+	if f.CVSS3BaseScore > 0 {
+		return f.CVSS3BaseScore
+	}
+	if f.CVSSBaseScore > 0 {
+		return f.CVSSBaseScore
+	}
+	if !f.Severity.Valid() {
+		return 0
+	}
+	return []float64{0, 2.0, 5.5, 8.0, 9.5}[f.Severity]
+}
+
+func result(f *report.Finding, ruleIndex int) Result {
+	// Findings without a port, e.g. general ones or the ones fetched through
+	// the API, are located on the host.
+	name, fqn := f.Host, f.Host
+	if f.Port != 0 {
+		name = fmt.Sprintf("%s:%d", f.Host, f.Port)
+		fqn = name
+		if f.Protocol != "" {
+			fqn += "/" + f.Protocol
+		}
+	}
+	text := f.PluginName
+	if f.PluginOutput != "" {
+		text += "\n\n" + f.PluginOutput
+	}
+	return Result{
+		RuleID:    strconv.FormatInt(f.PluginID, 10),
+		RuleIndex: ruleIndex,
+		Level:     Level(f.Severity),
+		Message:   Message{Text: text},
+		Locations: []Location{{LogicalLocations: []LogicalLocation{{
+			Name:               name,
+			FullyQualifiedName: fqn,
+			Kind:               "resource",
+		}}}},
+		PartialFingerprints: map[string]string{
+			"nessusFinding/v1": fmt.Sprintf("%d:%s", f.PluginID, fqn),
+		},
+	}
+}
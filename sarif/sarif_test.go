@@ -0,0 +1,95 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/JerusJ/nessie"
+	"github.com/JerusJ/nessie/report"
+)
+
+func TestFromReport(t *testing.T) {
+	f, err := os.Open("../report/testdata/sample.nessus")
+	if err != nil {
+		t.Fatalf("cannot open report: %v", err)
+	}
+	defer f.Close()
+
+	log, err := FromReport(report.NewReader(f), Options{ToolVersion: "8.13.1", MinSeverity: nessie.SeverityLow})
+	if err != nil {
+		t.Fatalf("cannot convert report: %v", err)
+	}
+	if log.Version != Version || len(log.Runs) != 1 {
+		t.Fatalf("wrong log, got=%+v", log)
+	}
+	run := log.Runs[0]
+	// The informational scan information finding is dropped.
+	if len(run.Tool.Driver.Rules) != 3 || len(run.Results) != 3 {
+		t.Fatalf("wrong number of rules or results, got rules=%d results=%d", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+	apache := run.Results[0]
+	if apache.RuleID != "142960" || apache.Level != LevelError || apache.RuleIndex != 0 {
+		t.Errorf("wrong result, got=%+v", apache)
+	}
+	if loc := apache.Locations[0].LogicalLocations[0]; loc.Name != "192.0.2.10:443" || loc.FullyQualifiedName != "192.0.2.10:443/tcp" {
+		t.Errorf("wrong location, got=%+v", loc)
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.Properties.SecuritySeverity != "9.8" || rule.HelpURI != "https://httpd.apache.org/security/vulnerabilities_24.html" || rule.Help == nil {
+		t.Errorf("wrong rule, got=%+v", rule)
+	}
+	if ssh := run.Results[1]; ssh.Level != LevelWarning {
+		t.Errorf("wrong level for a medium finding, got=%s", ssh.Level)
+	}
+
+	var buf bytes.Buffer
+	if err := log.Write(&buf); err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("log is not valid json: %v", err)
+	}
+	if decoded["$schema"] != SchemaURI {
+		t.Errorf("wrong schema, got=%v", decoded["$schema"])
+	}
+}
+
+func TestFromScan(t *testing.T) {
+	details := &nessie.ScanDetailsResp{Hosts: []nessie.Host{{HostID: 2, Hostname: "192.0.2.10"}, {HostID: 3, Hostname: "192.0.2.11"}}}
+	hosts := map[int64]*nessie.HostDetailsResp{2: {Vulnerabilities: []nessie.HostVulnerability{
+		{PluginID: 142960, PluginName: "Apache 2.4.x < 2.4.46 Multiple Vulnerabilities", Severity: 4},
+		{PluginID: 70658, PluginName: "SSH Server CBC Mode Ciphers Enabled", Severity: 1},
+	}}}
+	plugins := map[int64]*nessie.PluginDetails{142960: {
+		FamilyName: "Web Servers",
+		Attrs: []nessie.PluginAttr{
+			{Name: "cvss_base_score", Val: "7.5"},
+			{Name: "cve", Val: "CVE-2020-9490"},
+			{Name: "solution", Val: "Upgrade to Apache version 2.4.46 or later."},
+		},
+	}}
+
+	log := FromScan(details, hosts, plugins, Options{})
+	run := log.Runs[0]
+	if len(run.Results) != 2 || len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("wrong number of rules or results, got rules=%d results=%d", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.Properties.SecuritySeverity != "7.5" || rule.Help.Text != "Upgrade to Apache version 2.4.46 or later." {
+		t.Errorf("plugin details not applied, got=%+v", rule)
+	}
+	if got := run.Results[1]; got.Level != LevelNote || got.Locations[0].LogicalLocations[0].Name != "192.0.2.10" {
+		t.Errorf("wrong result, got=%+v", got)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	for severity, want := range []string{LevelNone, LevelNote, LevelWarning, LevelError, LevelError} {
+		if got := Level(nessie.Severity(severity)); got != want {
+			t.Errorf("wrong level for severity %d, got=%s, want=%s", severity, got, want)
+		}
+	}
+}
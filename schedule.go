@@ -0,0 +1,350 @@
+package nessie
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StartTimeLayout is the format of the starttime scan setting, a wall clock
+// time in the time zone of the scan.
+const StartTimeLayout = "20060102T150405"
+
+// rruleDays are the weekdays of rrules, indexed by time.Weekday.
+var rruleDays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Schedule is the recurring launch of a scan. It converts to the launch,
+// rrules, starttime and timezone scan settings, and previews the next runs.
+//
+//	s := nessie.Weekly(start, time.Monday, time.Thursday).Every(2)
+type Schedule struct {
+	// Frequency is LaunchOnce, LaunchDaily, LaunchWeekly, LaunchMonthly or
+	// LaunchYearly.
+	Frequency string
+	// Interval is the number of days, weeks, months or years between runs, 1
+	// when 0.
+	Interval int
+	// Start is the first run. Its location is the time zone of the scan, so it
+	// must be loaded by name, e.g. with time.LoadLocation("Europe/Paris").
+	Start time.Time
+	// Weekdays are the days of weekly schedules, the day of Start when empty.
+	Weekdays []time.Weekday
+	// ByWeekOfMonth runs monthly schedules on the weekday of Start in the same
+	// week of the month, e.g. every second Tuesday, instead of on the day of
+	// the month of Start.
+	ByWeekOfMonth bool
+}
+
+// Once returns a schedule running once at start.
+func Once(start time.Time) Schedule {
+	return Schedule{Frequency: LaunchOnce, Start: start}
+}
+
+// Daily returns a schedule running every day from start.
+func Daily(start time.Time) Schedule {
+	return Schedule{Frequency: LaunchDaily, Start: start}
+}
+
+// Weekly returns a schedule running every week on the given days, the day of
+// start when none, from start.
+func Weekly(start time.Time, days ...time.Weekday) Schedule {
+	return Schedule{Frequency: LaunchWeekly, Start: start, Weekdays: days}
+}
+
+// Monthly returns a schedule running every month on the day of start, from
+// start.
+func Monthly(start time.Time) Schedule {
+	return Schedule{Frequency: LaunchMonthly, Start: start}
+}
+
+// Yearly returns a schedule running every year on the date of start, from
+// start.
+func Yearly(start time.Time) Schedule {
+	return Schedule{Frequency: LaunchYearly, Start: start}
+}
+
+// Every returns the schedule running every n periods.
+func (s Schedule) Every(n int) Schedule {
+	s.Interval = n
+	return s
+}
+
+// OnWeekOfMonth returns the monthly schedule running on the weekday of Start
+// in the same week of the month.
+func (s Schedule) OnWeekOfMonth() Schedule {
+	s.ByWeekOfMonth = true
+	return s
+}
+
+func (s Schedule) interval() int {
+	if s.Interval == 0 {
+		return 1
+	}
+	return s.Interval
+}
+
+// TimeZone returns the name of the time zone of the schedule.
+func (s Schedule) TimeZone() string {
+	return s.Start.Location().String()
+}
+
+// Validate checks the schedule is consistent.
+func (s Schedule) Validate() error {
+	switch s.Frequency {
+	case LaunchOnce, LaunchDaily, LaunchWeekly, LaunchMonthly, LaunchYearly:
+	case LaunchOnDemand:
+		return fmt.Errorf("on demand scans have no schedule")
+	default:
+		return fmt.Errorf("invalid schedule frequency %q", s.Frequency)
+	}
+	if s.Interval < 0 {
+		return fmt.Errorf("schedule interval must be positive, got %d", s.Interval)
+	}
+	if s.Start.IsZero() {
+		return fmt.Errorf("schedule has no start")
+	}
+	if s.Start.Location() == time.Local {
+		return fmt.Errorf("schedule start must be in a named location, not Local")
+	}
+	if len(s.Weekdays) > 0 && s.Frequency != LaunchWeekly {
+		return fmt.Errorf("weekdays are only supported by %s schedules", LaunchWeekly)
+	}
+	for _, d := range s.Weekdays {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", d)
+		}
+	}
+	if s.ByWeekOfMonth && s.Frequency != LaunchMonthly {
+		return fmt.Errorf("week of the month is only supported by %s schedules", LaunchMonthly)
+	}
+	return nil
+}
+
+// ValidateTimeZone checks the time zone of the schedule is one of the
+// available time zones, as returned by Timezones.
+func (s Schedule) ValidateTimeZone(available []TimeZone) error {
+	tz := s.TimeZone()
+	for _, a := range available {
+		if a.Val == tz {
+			return nil
+		}
+	}
+	return fmt.Errorf("time zone %q is not supported by the server", tz)
+}
+
+// weekdays returns the sorted days of a weekly schedule.
+func (s Schedule) weekdays() []time.Weekday {
+	if len(s.Weekdays) == 0 {
+		return []time.Weekday{s.Start.Weekday()}
+	}
+	days := append([]time.Weekday(nil), s.Weekdays...)
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days
+}
+
+// RRules returns the rrules setting of the schedule, e.g.
+// "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,TH".
+func (s Schedule) RRules() string {
+	rrules := fmt.Sprintf("FREQ=%s;INTERVAL=%d", s.Frequency, s.interval())
+	switch {
+	case s.Frequency == LaunchWeekly:
+		var days []string
+		for _, d := range s.weekdays() {
+			days = append(days, rruleDays[d])
+		}
+		rrules += ";BYDAY=" + strings.Join(days, ",")
+	case s.Frequency == LaunchMonthly && s.ByWeekOfMonth:
+		rrules += fmt.Sprintf(";BYDAY=%d%s", weekOfMonth(s.Start), rruleDays[s.Start.Weekday()])
+	case s.Frequency == LaunchMonthly:
+		rrules += fmt.Sprintf(";BYMONTHDAY=%d", s.Start.Day())
+	}
+	return rrules
+}
+
+// StartTime returns the starttime setting of the schedule.
+func (s Schedule) StartTime() string {
+	return s.Start.Format(StartTimeLayout)
+}
+
+// SetSchedule validates and applies the schedule to the scan settings, and
+// enables it.
+func (s *ScanSettingsRequest) SetSchedule(schedule Schedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	s.Launch = schedule.Frequency
+	s.RRules = schedule.RRules()
+	s.StartTime = schedule.StartTime()
+	s.TimeZone = schedule.TimeZone()
+	s.Enabled = true
+	return nil
+}
+
+// Schedule returns the schedule of the scan, see ParseSchedule.
+func (s *Scan) Schedule() (Schedule, error) {
+	return ParseSchedule(s.RRules, string(s.StartTime), s.TimeZone)
+}
+
+// LaunchFromRRules returns the launch setting matching the frequency of the
+// rrules scan setting, LaunchOnDemand without one.
+func LaunchFromRRules(rrules string) string {
+	for _, part := range strings.Split(rrules, ";") {
+		if strings.HasPrefix(part, "FREQ=") {
+			return strings.TrimPrefix(part, "FREQ=")
+		}
+	}
+	return LaunchOnDemand
+}
+
+// ParseSchedule parses the rrules, starttime and timezone scan settings. The
+// time zone must be known to the local time zone database, UTC when empty.
+// On demand scans, without rrules or starttime, have no schedule.
+func ParseSchedule(rrules, startTime, timeZone string) (Schedule, error) {
+	var s Schedule
+	if strings.TrimSpace(rrules) == "" || strings.TrimSpace(startTime) == "" {
+		return s, fmt.Errorf("scan has no schedule")
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return s, fmt.Errorf("invalid schedule time zone: %v", err)
+	}
+	if s.Start, err = time.ParseInLocation(StartTimeLayout, startTime, loc); err != nil {
+		return s, fmt.Errorf("invalid schedule start time %q", startTime)
+	}
+	for _, part := range strings.Split(rrules, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "FREQ":
+			s.Frequency = value
+		case "INTERVAL":
+			if s.Interval, err = strconv.Atoi(value); err != nil {
+				return s, fmt.Errorf("invalid schedule interval %q", value)
+			}
+		case "BYDAY":
+			if err := s.parseByDay(value); err != nil {
+				return s, err
+			}
+		case "BYMONTHDAY":
+			if value != strconv.Itoa(s.Start.Day()) {
+				return s, fmt.Errorf("day of the month %s differs from the start %s", value, startTime)
+			}
+		default:
+			return s, fmt.Errorf("unsupported schedule rule %q", part)
+		}
+	}
+	return s, s.Validate()
+}
+
+// parseByDay parses weekdays, e.g. "MO,TH", or a weekday of a week of the
+// month, e.g. "2TU", which must be the one of Start.
+func (s *Schedule) parseByDay(value string) error {
+	for _, day := range strings.Split(value, ",") {
+		week := strings.TrimRight(day, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		d := weekdayOf(day[len(week):])
+		if d < 0 {
+			return fmt.Errorf("invalid schedule day %q", day)
+		}
+		if week == "" {
+			s.Weekdays = append(s.Weekdays, d)
+			continue
+		}
+		if week != strconv.Itoa(weekOfMonth(s.Start)) || d != s.Start.Weekday() {
+			return fmt.Errorf("day %q differs from the start %s", day, s.Start.Format(StartTimeLayout))
+		}
+		s.ByWeekOfMonth = true
+	}
+	return nil
+}
+
+func weekdayOf(name string) time.Weekday {
+	for d, n := range rruleDays {
+		if n == name {
+			return time.Weekday(d)
+		}
+	}
+	return -1
+}
+
+// weekOfMonth returns the week of the month of t, from 1 to 5, e.g. 2 for the
+// second Tuesday.
+func weekOfMonth(t time.Time) int {
+	return (t.Day()-1)/7 + 1
+}
+
+// maxSchedulePeriods bounds the periods scanned by Next, e.g. a daily
+// schedule started 270 years earlier.
+const maxSchedulePeriods = 100000
+
+// Next returns the next n runs of the schedule after the given time, in the
+// location of Start. Days missing from a period are skipped, e.g. the 31st in
+// April or the fifth Monday of most months.
+func (s Schedule) Next(after time.Time, n int) []time.Time {
+	var runs []time.Time
+	if n <= 0 {
+		return runs
+	}
+	for k := 0; k < maxSchedulePeriods; k++ {
+		for _, run := range s.period(k) {
+			if run.Before(s.Start) || !run.After(after) {
+				continue
+			}
+			runs = append(runs, run)
+			if len(runs) == n {
+				return runs
+			}
+		}
+		if s.Frequency == LaunchOnce {
+			break
+		}
+	}
+	return runs
+}
+
+// period returns the sorted runs of the k-th period of the schedule.
+func (s Schedule) period(k int) []time.Time {
+	start := s.Start
+	y, m, d := start.Date()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	step := k * s.interval()
+	switch s.Frequency {
+	case LaunchOnce:
+		return []time.Time{start}
+	case LaunchDaily:
+		return []time.Time{at(y, m, d+step)}
+	case LaunchWeekly:
+		// Weeks start on Monday.
+		monday := d - (int(start.Weekday())+6)%7 + 7*step
+		var runs []time.Time
+		for _, day := range s.weekdays() {
+			runs = append(runs, at(y, m, monday+(int(day)+6)%7))
+		}
+		sort.Slice(runs, func(i, j int) bool { return runs[i].Before(runs[j]) })
+		return runs
+	case LaunchMonthly:
+		first := at(y, m+time.Month(step), 1)
+		day := d
+		if s.ByWeekOfMonth {
+			offset := (int(start.Weekday()) - int(first.Weekday()) + 7) % 7
+			day = 1 + offset + 7*(weekOfMonth(start)-1)
+		}
+		run := at(first.Year(), first.Month(), day)
+		if run.Month() != first.Month() {
+			return nil
+		}
+		return []time.Time{run}
+	case LaunchYearly:
+		run := at(y+step, m, d)
+		if run.Month() != m {
+			return nil
+		}
+		return []time.Time{run}
+	}
+	return nil
+}
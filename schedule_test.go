@@ -0,0 +1,154 @@
+package nessie
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	return loc
+}
+
+func formatRuns(runs []time.Time) []string {
+	var out []string
+	for _, r := range runs {
+		out = append(out, r.Format("Mon 2006-01-02 15:04 MST"))
+	}
+	return out
+}
+
+func TestScheduleRRules(t *testing.T) {
+	paris := loadLocation(t, "Europe/Paris")
+	// Tuesday January 12, 2021.
+	start := time.Date(2021, 1, 12, 2, 30, 0, 0, paris)
+	tests := []struct {
+		schedule Schedule
+		rrules   string
+	}{
+		{Once(start), "FREQ=ONETIME;INTERVAL=1"},
+		{Daily(start).Every(3), "FREQ=DAILY;INTERVAL=3"},
+		{Weekly(start), "FREQ=WEEKLY;INTERVAL=1;BYDAY=TU"},
+		{Weekly(start, time.Thursday, time.Monday).Every(2), "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{Monthly(start), "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=12"},
+		{Monthly(start).OnWeekOfMonth(), "FREQ=MONTHLY;INTERVAL=1;BYDAY=2TU"},
+		{Yearly(start), "FREQ=YEARLY;INTERVAL=1"},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.RRules(); got != tt.rrules {
+			t.Errorf("got rrules %q, want %q", got, tt.rrules)
+		}
+		var settings ScanSettingsRequest
+		if err := settings.SetSchedule(tt.schedule); err != nil {
+			t.Fatalf("cannot set schedule %q: %v", tt.rrules, err)
+		}
+		if got := LaunchFromRRules(settings.RRules); got != tt.schedule.Frequency {
+			t.Errorf("got launch %q from rrules %q", got, settings.RRules)
+		}
+		if settings.Launch != tt.schedule.Frequency || settings.StartTime != "20210112T023000" || settings.TimeZone != "Europe/Paris" || !settings.Enabled {
+			t.Errorf("wrong settings %+v", settings)
+		}
+		// The settings parse back to the same schedule.
+		parsed, err := ParseSchedule(settings.RRules, settings.StartTime, settings.TimeZone)
+		if err != nil || parsed.RRules() != tt.rrules || !parsed.Start.Equal(start) {
+			t.Errorf("wrong parsed schedule %+v: %v", parsed, err)
+		}
+	}
+	if got := LaunchFromRRules(""); got != LaunchOnDemand {
+		t.Errorf("scans without rrules should be on demand, got %q", got)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	start := time.Date(2021, 1, 12, 2, 30, 0, 0, time.UTC)
+	invalid := []Schedule{
+		{Frequency: LaunchOnDemand, Start: start},
+		{Frequency: "HOURLY", Start: start},
+		{Frequency: LaunchDaily},
+		Daily(start).Every(-1),
+		Daily(time.Date(2021, 1, 12, 2, 30, 0, 0, time.Local)),
+		{Frequency: LaunchDaily, Start: start, Weekdays: []time.Weekday{time.Monday}},
+		Weekly(start, 9),
+		Daily(start).OnWeekOfMonth(),
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("schedule %+v should be invalid", s)
+		}
+	}
+
+	zones := []TimeZone{{Name: "UTC", Val: "UTC"}, {Name: "Europe/Paris", Val: "Europe/Paris"}}
+	if err := Daily(start).ValidateTimeZone(zones); err != nil {
+		t.Errorf("UTC should be supported: %v", err)
+	}
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	if err := Daily(start.In(tokyo)).ValidateTimeZone(zones); err == nil {
+		t.Error("Asia/Tokyo should not be supported")
+	}
+
+	// A trailing separator is ignored.
+	if s, err := ParseSchedule("FREQ=DAILY;INTERVAL=1;", "20210112T023000", "UTC"); err != nil || s.RRules() != "FREQ=DAILY;INTERVAL=1" {
+		t.Errorf("wrong schedule %+v: %v", s, err)
+	}
+	// On demand scans have no schedule.
+	for _, settings := range [][2]string{{"", ""}, {"", "20210112T023000"}, {"FREQ=DAILY;INTERVAL=1", ""}} {
+		if _, err := ParseSchedule(settings[0], settings[1], "UTC"); err == nil || err.Error() != "scan has no schedule" {
+			t.Errorf("settings %q should have no schedule, got %v", settings, err)
+		}
+	}
+	if _, err := (&Scan{}).Schedule(); err == nil || err.Error() != "scan has no schedule" {
+		t.Errorf("on demand scan should have no schedule, got %v", err)
+	}
+
+	for _, rrules := range []string{"FREQ=WEEKLY;INTERVAL=1;BYDAY=XX", "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=3", "FREQ=MONTHLY;INTERVAL=1;BYDAY=3TU", "FREQ=DAILY;COUNT=3"} {
+		if _, err := ParseSchedule(rrules, "20210112T023000", "UTC"); err == nil {
+			t.Errorf("rrules %q should be invalid", rrules)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	paris := loadLocation(t, "Europe/Paris")
+	start := time.Date(2021, 1, 12, 2, 30, 0, 0, paris)
+	after := time.Date(2021, 3, 25, 0, 0, 0, 0, paris)
+	tests := []struct {
+		schedule Schedule
+		want     []string
+	}{
+		{Once(start), nil},
+		{Once(start.AddDate(0, 6, 0)), []string{"Mon 2021-07-12 02:30 CEST"}},
+		// Daylight saving time starts on March 28, the runs keep their wall clock.
+		{Daily(start).Every(2), []string{"Thu 2021-03-25 02:30 CET", "Sat 2021-03-27 02:30 CET", "Mon 2021-03-29 02:30 CEST"}},
+		{Weekly(start, time.Monday, time.Friday).Every(2), []string{"Fri 2021-03-26 02:30 CET", "Mon 2021-04-05 02:30 CEST", "Fri 2021-04-09 02:30 CEST"}},
+		{Monthly(time.Date(2021, 1, 31, 8, 0, 0, 0, paris)), []string{"Wed 2021-03-31 08:00 CEST", "Mon 2021-05-31 08:00 CEST", "Sat 2021-07-31 08:00 CEST"}},
+		{Monthly(start).OnWeekOfMonth(), []string{"Tue 2021-04-13 02:30 CEST", "Tue 2021-05-11 02:30 CEST", "Tue 2021-06-08 02:30 CEST"}},
+		{Yearly(time.Date(2020, 2, 29, 8, 0, 0, 0, paris)), []string{"Thu 2024-02-29 08:00 CET", "Tue 2028-02-29 08:00 CET", "Sun 2032-02-29 08:00 CET"}},
+	}
+	for _, tt := range tests {
+		got := formatRuns(tt.schedule.Next(after, 3))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got runs %q, want %q", tt.schedule.RRules(), got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got runs %q, want %q", tt.schedule.RRules(), got, tt.want)
+				break
+			}
+		}
+	}
+
+	// Scans parse their own schedule.
+	sc := Scan{RRules: "FREQ=WEEKLY;INTERVAL=1;BYDAY=TU", TimeZone: "Europe/Paris", StartTime: "20210112T023000"}
+	s, err := sc.Schedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatRuns(s.Next(after, 1)); len(got) != 1 || got[0] != "Tue 2021-03-30 02:30 CEST" {
+		t.Errorf("wrong next run of the scan %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package nessie
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Severity is the severity of a finding, from SeverityInfo to SeverityCritical,
+// as sent by nessus in the severity fields.
+type Severity int64
+
+// Severities of the findings, in increasing order.
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+// Severities returns every severity, in increasing order.
+func Severities() []Severity {
+	return []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+}
+
+// String returns the lower case name of the severity, e.g. "critical".
+func (s Severity) String() string {
+	if !s.Valid() {
+		return fmt.Sprintf("severity(%d)", int64(s))
+	}
+	return severityNames[s]
+}
+
+// Valid returns whether s is one of the severities nessus knows.
+func (s Severity) Valid() bool {
+	return s >= SeverityInfo && s <= SeverityCritical
+}
+
+// AtLeast returns whether s is as severe as min or more.
+func (s Severity) AtLeast(min Severity) bool {
+	return s >= min
+}
+
+// Compare returns -1, 0 or 1 when s is less, as or more severe than o.
+func (s Severity) Compare(o Severity) int {
+	switch {
+	case s < o:
+		return -1
+	case s > o:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseSeverity parses a risk factor, e.g. "High" or "None" as written in
+// .nessus files and plugin attributes, a severity name or a severity index.
+func ParseSeverity(s string) (Severity, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	switch name {
+	case "none", "informational":
+		return SeverityInfo, nil
+	}
+	for i, n := range severityNames {
+		if name == n {
+			return Severity(i), nil
+		}
+	}
+	if i, err := strconv.ParseInt(name, 10, 64); err == nil && Severity(i).Valid() {
+		return Severity(i), nil
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q", s)
+}
+
+// SeverityFromCVSS returns the severity of a CVSS base score, with the ranges
+// of CVSS v3: 0 is info, up to 3.9 is low, up to 6.9 is medium, up to 8.9 is
+// high and 9.0 or more is critical.
+func SeverityFromCVSS(score float64) Severity {
+	switch {
+	case math.IsNaN(score) || score <= 0:
+		return SeverityInfo
+	case score < 4:
+		return SeverityLow
+	case score < 7:
+		return SeverityMedium
+	case score < 9:
+		return SeverityHigh
+	default:
+		return SeverityCritical
+	}
+}
+
+// SeverityCounts counts findings by severity.
+type SeverityCounts struct {
+	Info     int64 `json:"info"`
+	Low      int64 `json:"low"`
+	Medium   int64 `json:"medium"`
+	High     int64 `json:"high"`
+	Critical int64 `json:"critical"`
+}
+
+// CountHostSeverities sums the severity counters of hosts, e.g. the hosts of
+// the scan details.
+func CountHostSeverities(hosts []Host) SeverityCounts {
+	var c SeverityCounts
+	for _, h := range hosts {
+		c.Info += h.Info
+		c.Low += h.Low
+		c.Medium += h.Medium
+		c.High += h.High
+		c.Critical += h.Critical
+	}
+	return c
+}
+
+// Get returns the count of a severity, 0 for an invalid one.
+func (c SeverityCounts) Get(s Severity) int64 {
+	switch s {
+	case SeverityInfo:
+		return c.Info
+	case SeverityLow:
+		return c.Low
+	case SeverityMedium:
+		return c.Medium
+	case SeverityHigh:
+		return c.High
+	case SeverityCritical:
+		return c.Critical
+	}
+	return 0
+}
+
+// Add adds n findings of a severity, invalid severities are ignored.
+func (c *SeverityCounts) Add(s Severity, n int64) {
+	switch s {
+	case SeverityInfo:
+		c.Info += n
+	case SeverityLow:
+		c.Low += n
+	case SeverityMedium:
+		c.Medium += n
+	case SeverityHigh:
+		c.High += n
+	case SeverityCritical:
+		c.Critical += n
+	}
+}
+
+// Total returns the number of findings of every severity.
+func (c SeverityCounts) Total() int64 {
+	return c.Info + c.Low + c.Medium + c.High + c.Critical
+}
+
+// AtLeast returns the number of findings as severe as min or more.
+func (c SeverityCounts) AtLeast(min Severity) int64 {
+	var n int64
+	for _, s := range Severities() {
+		if s.AtLeast(min) {
+			n += c.Get(s)
+		}
+	}
+	return n
+}
+
+// Highest returns the highest severity with findings, false without findings.
+func (c SeverityCounts) Highest() (Severity, bool) {
+	for s := SeverityCritical; s >= SeverityInfo; s-- {
+		if c.Get(s) > 0 {
+			return s, true
+		}
+	}
+	return SeverityInfo, false
+}
+
+// SeverityCounts returns the severity counters of the host.
+func (h Host) SeverityCounts() SeverityCounts {
+	return CountHostSeverities([]Host{h})
+}
+
+// SeverityCounts sums the severity counters of the hosts of the scan.
+func (r *ScanDetailsResp) SeverityCounts() SeverityCounts {
+	return CountHostSeverities(r.Hosts)
+}
+
+// SeverityCounts counts the vulnerabilities of the host by severity, each
+// plugin once.
+func (r *HostDetailsResp) SeverityCounts() SeverityCounts {
+	var c SeverityCounts
+	for _, v := range r.Vulnerabilities {
+		c.Add(v.Severity, 1)
+	}
+	return c
+}
@@ -0,0 +1,105 @@
+package nessie
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	var tests = []struct {
+		in      string
+		want    Severity
+		wantErr bool
+	}{
+		{"None", SeverityInfo, false},
+		{"Informational", SeverityInfo, false},
+		{"info", SeverityInfo, false},
+		{"Low", SeverityLow, false},
+		{" MEDIUM ", SeverityMedium, false},
+		{"High", SeverityHigh, false},
+		{"Critical", SeverityCritical, false},
+		{"4", SeverityCritical, false},
+		{"5", SeverityInfo, true},
+		{"", SeverityInfo, true},
+		{"severe", SeverityInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSeverity(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSeverity(%q): got=%v err=%v, want=%v wantErr=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+	for _, s := range Severities() {
+		if got, err := ParseSeverity(s.String()); err != nil || got != s {
+			t.Errorf("%v does not parse back, got=%v err=%v", s, got, err)
+		}
+	}
+	if s := Severity(7).String(); s != "severity(7)" {
+		t.Errorf("wrong name of an invalid severity %q", s)
+	}
+}
+
+func TestSeverityFromCVSS(t *testing.T) {
+	var tests = []struct {
+		score float64
+		want  Severity
+	}{
+		{0, SeverityInfo},
+		{math.NaN(), SeverityInfo},
+		{0.1, SeverityLow},
+		{3.9, SeverityLow},
+		{4.0, SeverityMedium},
+		{6.9, SeverityMedium},
+		{7.0, SeverityHigh},
+		{8.9, SeverityHigh},
+		{9.0, SeverityCritical},
+		{10, SeverityCritical},
+	}
+	for _, tt := range tests {
+		if got := SeverityFromCVSS(tt.score); got != tt.want {
+			t.Errorf("SeverityFromCVSS(%v): got=%v want=%v", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestSeverityOrder(t *testing.T) {
+	if !SeverityCritical.AtLeast(SeverityHigh) || SeverityMedium.AtLeast(SeverityHigh) || !SeverityHigh.AtLeast(SeverityHigh) {
+		t.Error("wrong AtLeast")
+	}
+	if SeverityLow.Compare(SeverityHigh) != -1 || SeverityHigh.Compare(SeverityLow) != 1 || SeverityLow.Compare(SeverityLow) != 0 {
+		t.Error("wrong Compare")
+	}
+}
+
+func TestSeverityCounts(t *testing.T) {
+	details := &ScanDetailsResp{Hosts: []Host{
+		{Info: 10, Low: 1, Medium: 2, High: 0, Critical: 1},
+		{Info: 5, Medium: 1, High: 3},
+	}}
+	c := details.SeverityCounts()
+	want := SeverityCounts{Info: 15, Low: 1, Medium: 3, High: 3, Critical: 1}
+	if c != want {
+		t.Errorf("wrong counts %+v, want %+v", c, want)
+	}
+	if c.Total() != 23 || c.AtLeast(SeverityHigh) != 4 || c.Get(SeverityMedium) != 3 || c.Get(Severity(9)) != 0 {
+		t.Errorf("wrong totals of %+v", c)
+	}
+	if s, ok := c.Highest(); !ok || s != SeverityCritical {
+		t.Errorf("wrong highest severity %v", s)
+	}
+	if _, ok := (SeverityCounts{}).Highest(); ok {
+		t.Error("empty counts should have no highest severity")
+	}
+
+	var host HostDetailsResp
+	if err := json.Unmarshal([]byte(`{"vulnerabilities":[{"plugin_id":1,"severity":4},{"plugin_id":2,"severity":0},{"plugin_id":3,"severity":4}]}`), &host); err != nil {
+		t.Fatal(err)
+	}
+	if c := host.SeverityCounts(); c != (SeverityCounts{Info: 1, Critical: 2}) {
+		t.Errorf("wrong host counts %+v", c)
+	}
+	if host.Vulnerabilities[0].Severity != SeverityCritical {
+		t.Errorf("wrong decoded severity %v", host.Vulnerabilities[0].Severity)
+	}
+}
@@ -0,0 +1,59 @@
+package nessie
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a time that Nessus sends as epoch seconds, e.g. creation and
+// modification dates. A missing, null or 0 value is the zero time, which
+// encodes back to 0.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp returns the timestamp of t, truncated to the second.
+func NewTimestamp(t time.Time) Timestamp {
+	if t.IsZero() {
+		return Timestamp{}
+	}
+	return Timestamp{t.Truncate(time.Second)}
+}
+
+// UnixTimestamp returns the timestamp of epoch seconds, 0 is the zero time.
+func UnixTimestamp(sec int64) Timestamp {
+	if sec == 0 {
+		return Timestamp{}
+	}
+	return Timestamp{time.Unix(sec, 0).UTC()}
+}
+
+// Epoch returns the epoch seconds of the timestamp, 0 for the zero time.
+func (t Timestamp) Epoch() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting numbers and numeric
+// strings.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		*t = Timestamp{}
+		return nil
+	}
+	var epoch FlexInt64
+	if err := epoch.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("cannot parse %s as a timestamp", data)
+	}
+	*t = UnixTimestamp(int64(epoch))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, as epoch seconds.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, t.Epoch(), 10), nil
+}
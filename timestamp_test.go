@@ -0,0 +1,39 @@
+package nessie
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	want := time.Date(2021, 1, 12, 10, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{`1610445600`, want, false},
+		{`"1610445600"`, want, false},
+		{`0`, time.Time{}, false},
+		{`""`, time.Time{}, false},
+		{`null`, time.Time{}, false},
+		{`"Tue Jan 12 10:00:00 2021"`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		var got Timestamp
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("unmarshal %s: got=%v err=%v, want=%v wantErr=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	var scan Scan
+	if err := json.Unmarshal([]byte(`{"id":1}`), &scan); err != nil || !scan.CreationDate.IsZero() || scan.CreationDate.Epoch() != 0 {
+		t.Errorf("missing date should be the zero time, got=%v err=%v", scan.CreationDate, err)
+	}
+	b, _ := json.Marshal(struct{ A, B Timestamp }{NewTimestamp(want.Add(time.Millisecond)), Timestamp{}})
+	if string(b) != `{"A":1610445600,"B":0}` {
+		t.Errorf("wrong marshaled timestamps %s", b)
+	}
+}
@@ -0,0 +1,170 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers set on the webhook requests.
+const (
+	HeaderEvent     = "X-Nessie-Event"
+	HeaderTimestamp = "X-Nessie-Timestamp"
+	HeaderSignature = "X-Nessie-Signature"
+)
+
+// WebhookSink posts events as JSON to an HTTP endpoint. Requests are signed
+// with HMAC-SHA256 when a secret is set, so that the receiver can check them
+// with VerifySignature.
+type WebhookSink struct {
+	URL    string
+	Secret []byte
+	Client *http.Client
+	// MaxRetries is the number of retries after a network error or a 5xx or
+	// 429 response.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled for each retry.
+	Backoff time.Duration
+
+	now func() time.Time
+}
+
+// NewWebhookSink returns a webhook sink retrying 3 times, starting after one
+// second.
+func NewWebhookSink(url string, secret []byte) *WebhookSink {
+	return &WebhookSink{
+		URL:        url,
+		Secret:     secret,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		Backoff:    time.Second,
+		now:        time.Now,
+	}
+}
+
+// Send posts the event, retrying until it is accepted with a 2xx response,
+// rejected with another 4xx response or the retries are exhausted.
+func (s *WebhookSink) Send(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	backoff := s.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, e, body)
+		if err == nil || !retry || attempt >= s.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one request and returns whether a failure can be retried.
+func (s *WebhookSink) post(ctx context.Context, e *Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(e.Type))
+	if len(s.Secret) > 0 {
+		now := time.Now
+		if s.now != nil {
+			now = s.now
+		}
+		ts := strconv.FormatInt(now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, ts)
+		req.Header.Set(HeaderSignature, Sign(s.Secret, ts, body))
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook returned status %s", resp.Status)
+}
+
+// Sign returns the signature of a webhook body sent at the given unix
+// timestamp: the hex encoded HMAC-SHA256 of "timestamp.body", prefixed by
+// "sha256=". The timestamp is signed so that receivers can reject replays.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether the signature header of a webhook request
+// matches its timestamp header and body.
+func VerifySignature(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// JSONLSink writes events as JSON lines.
+type JSONLSink struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+// NewJSONLSink returns a sink writing events to w.
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: w}
+}
+
+// NewFileSink returns a sink appending events to the file at path, created
+// if it does not exist.
+func NewFileSink(path string) (*JSONLSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{w: f, c: f}, nil
+}
+
+// Send writes the event as a single line.
+func (s *JSONLSink) Send(ctx context.Context, e *Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file of a sink created with NewFileSink.
+func (s *JSONLSink) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
@@ -0,0 +1,308 @@
+// Package watch polls a Nessus server for scan lifecycle transitions and
+// delivers them as events to sinks, since Nessus has no outbound webhooks.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+// EventType identifies a scan lifecycle transition.
+type EventType string
+
+const (
+	// EventScanStarted is sent when a new run of a scan starts.
+	EventScanStarted EventType = "scan.started"
+	// EventScanPaused is sent when a running scan is paused.
+	EventScanPaused EventType = "scan.paused"
+	// EventScanResumed is sent when a paused scan runs again.
+	EventScanResumed EventType = "scan.resumed"
+	// EventScanCompleted is sent when a run of a scan completes.
+	EventScanCompleted EventType = "scan.completed"
+	// EventScanAborted is sent when a run of a scan is canceled or aborted.
+	EventScanAborted EventType = "scan.aborted"
+	// EventCriticalFound is sent when the current run of a scan has more
+	// critical findings than at the previous poll.
+	EventCriticalFound EventType = "scan.critical_found"
+)
+
+// Nessus scan statuses the watcher interprets.
+const (
+	statusRunning  = "running"
+	statusPausing  = "pausing"
+	statusPaused   = "paused"
+	statusResuming = "resuming"
+	statusComplete = "completed"
+	statusCanceled = "canceled"
+	statusAborted  = "aborted"
+)
+
+// Event is a scan lifecycle transition.
+type Event struct {
+	Type           EventType `json:"type"`
+	Time           time.Time `json:"time"`
+	ScanID         int64     `json:"scan_id"`
+	ScanUUID       string    `json:"scan_uuid,omitempty"`
+	ScanName       string    `json:"scan_name"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	// Critical is the number of critical findings of the current run, only
+	// set for EventCriticalFound.
+	Critical int64 `json:"critical,omitempty"`
+	// NewCritical is the number of critical findings since the previous poll,
+	// only set for EventCriticalFound.
+	NewCritical int64 `json:"new_critical,omitempty"`
+}
+
+// Sink receives the events of a Watcher.
+type Sink interface {
+	Send(ctx context.Context, e *Event) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, e *Event) error
+
+// Send calls f.
+func (f SinkFunc) Send(ctx context.Context, e *Event) error {
+	return f(ctx, e)
+}
+
+type scanState struct {
+	status string
+	// critical is the number of critical findings of the current run, only
+	// meaningful when criticalKnown.
+	critical      int64
+	criticalKnown bool
+}
+
+// Watcher polls the scans of a Nessus server and sends an event to all its
+// sinks for each transition. The first poll only records the current state
+// of the scans, events are sent from the second poll on.
+//
+// Polls only list the scans modified since the previous one, which does not
+// tell deleted scans. Every FullPollEvery polls, all the scans are listed and
+// the state of the missing ones is forgotten.
+type Watcher struct {
+	// Interval is the time between two polls in Run.
+	Interval time.Duration
+	// FullPollEvery is the number of polls between two listings of all the
+	// scans, 60 when 0.
+	FullPollEvery int
+	// ErrorHandler is called by Run when a poll fails, errors are logged
+	// when nil.
+	ErrorHandler func(error)
+
+	nessus nessie.ScanService
+	sinks  []Sink
+	// since is the server timestamp of the previous poll, scans modified
+	// before it are unchanged.
+	since nessie.Timestamp
+	scans map[int64]*scanState
+	// polls counts the polls since the last full listing.
+	polls int
+	now   func() time.Time
+}
+
+// NewWatcher returns a watcher polling the scans of n every minute.
+func NewWatcher(n nessie.ScanService, sinks ...Sink) *Watcher {
+	return &Watcher{
+		Interval: time.Minute,
+		nessus:   n,
+		sinks:    sinks,
+		now:      time.Now,
+	}
+}
+
+// Run polls the server until the context is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(ctx); err != nil {
+			if w.ErrorHandler != nil {
+				w.ErrorHandler(err)
+			} else {
+				log.Printf("watch: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll lists the scans modified since the previous poll, sends the events
+// of their transitions to the sinks and returns them. All sinks are tried
+// for every event, the first delivery error is returned. The scans whose
+// details cannot be fetched are checked again on the next poll, and the
+// scans whose events are not delivered keep their previous state, so their
+// events are sent again.
+func (w *Watcher) Poll(ctx context.Context) ([]*Event, error) {
+	since := w.since
+	full := w.polls == 0
+	if full {
+		since = nessie.Timestamp{}
+	}
+	list, err := w.nessus.ScansSince(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list scans: %v", err)
+	}
+	if w.polls++; w.polls >= w.fullPollEvery() {
+		w.polls = 0
+	}
+	first := w.scans == nil
+	// The new states are merged after the events are sent, except for the
+	// scans whose events failed.
+	states := make(map[int64]*scanState, len(list.Scans))
+	var events []*Event
+	var pollErr error
+	for i := range list.Scans {
+		scan := &list.Scans[i]
+		prev, seen := w.scans[scan.ID]
+		if first {
+			states[scan.ID] = &scanState{status: scan.Status}
+			continue
+		}
+		// Scans modified in the same second as the previous poll are
+		// checked again, transitions are deduplicated on the state.
+		if seen && scan.LastModificationDate.Before(w.since.Time) && scan.Status == prev.status {
+			continue
+		}
+		if !seen {
+			prev = &scanState{}
+		}
+		state := &scanState{status: scan.Status, critical: prev.critical, criticalKnown: prev.criticalKnown}
+		if scan.Status != prev.status {
+			if e := w.transition(scan, prev.status); e != nil {
+				events = append(events, e)
+				if e.Type == EventScanStarted {
+					state.critical, state.criticalKnown = 0, true
+				}
+			}
+		}
+		if scan.Status == statusRunning || scan.Status == statusComplete {
+			e, err := w.checkCritical(scan, state)
+			if err != nil && pollErr == nil {
+				pollErr = err
+			}
+			if e != nil {
+				events = append(events, e)
+			}
+		}
+		states[scan.ID] = state
+	}
+
+	var sendErr error
+	failed := make(map[int64]bool)
+	for _, e := range events {
+		for _, s := range w.sinks {
+			if err := s.Send(ctx, e); err != nil {
+				failed[e.ScanID] = true
+				if sendErr == nil {
+					sendErr = fmt.Errorf("cannot send %s event of scan %d: %v", e.Type, e.ScanID, err)
+				}
+			}
+		}
+	}
+
+	if first {
+		w.scans = make(map[int64]*scanState)
+	}
+	if full {
+		w.prune(list.Scans)
+	}
+	for id, state := range states {
+		if !failed[id] {
+			w.scans[id] = state
+		}
+	}
+	// Keeping the previous timestamp lists the scans that failed again.
+	if pollErr != nil {
+		return events, pollErr
+	}
+	if sendErr != nil {
+		return events, sendErr
+	}
+	w.since = list.Timestamp
+	return events, nil
+}
+
+func (w *Watcher) fullPollEvery() int {
+	if w.FullPollEvery <= 0 {
+		return 60
+	}
+	return w.FullPollEvery
+}
+
+// prune forgets the scans missing from the listing of all the scans.
+func (w *Watcher) prune(scans []nessie.Scan) {
+	listed := make(map[int64]bool, len(scans))
+	for _, scan := range scans {
+		listed[scan.ID] = true
+	}
+	for id := range w.scans {
+		if !listed[id] {
+			delete(w.scans, id)
+		}
+	}
+}
+
+// transition returns the event for a scan whose status changed from prev, nil
+// when the transition is not an event.
+func (w *Watcher) transition(scan *nessie.Scan, prev string) *Event {
+	var typ EventType
+	switch scan.Status {
+	case statusRunning:
+		typ = EventScanStarted
+		if prev == statusPaused || prev == statusPausing || prev == statusResuming {
+			typ = EventScanResumed
+		}
+	case statusPaused:
+		typ = EventScanPaused
+	case statusComplete:
+		typ = EventScanCompleted
+	case statusCanceled, statusAborted:
+		typ = EventScanAborted
+	default:
+		return nil
+	}
+	return w.event(typ, scan, prev)
+}
+
+// checkCritical updates the number of critical findings of the current run
+// of the scan and returns an event when it increased. The first count of a
+// run the watcher did not see starting is only recorded.
+func (w *Watcher) checkCritical(scan *nessie.Scan, state *scanState) (*Event, error) {
+	details, err := w.nessus.ScanDetails(scan.ID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get details of scan %d: %v", scan.ID, err)
+	}
+	critical := details.SeverityCounts().Critical
+	known, prev := state.criticalKnown, state.critical
+	state.critical, state.criticalKnown = critical, true
+	if !known || critical <= prev {
+		return nil, nil
+	}
+	e := w.event(EventCriticalFound, scan, "")
+	e.Critical = critical
+	e.NewCritical = critical - prev
+	return e, nil
+}
+
+func (w *Watcher) event(typ EventType, scan *nessie.Scan, prev string) *Event {
+	return &Event{
+		Type:           typ,
+		Time:           w.now(),
+		ScanID:         scan.ID,
+		ScanUUID:       scan.UUID,
+		ScanName:       scan.Name,
+		Status:         scan.Status,
+		PreviousStatus: prev,
+	}
+}
@@ -0,0 +1,295 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JerusJ/nessie"
+)
+
+// fakeServer serves the scans list and the critical count of each scan.
+type fakeServer struct {
+	mu       sync.Mutex
+	scans    *nessie.ListScansResponse
+	critical map[int64]int64
+	// broken are the scans whose details cannot be fetched.
+	broken map[int64]bool
+	// queries are the query strings of the scan listings.
+	queries []string
+}
+
+func (f *fakeServer) set(timestamp int64, critical map[int64]int64, scans ...nessie.Scan) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scans = &nessie.ListScansResponse{Scans: scans, Timestamp: nessie.UnixTimestamp(timestamp)}
+	f.critical = critical
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var resp interface{} = f.scans
+	if r.URL.Path == "/scans" {
+		f.queries = append(f.queries, r.URL.RawQuery)
+	} else {
+		var id int64
+		if _, err := fmt.Sscanf(r.URL.Path, "/scans/%d", &id); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if f.broken[id] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp = &nessie.ScanDetailsResp{Hosts: []nessie.Host{{Critical: f.critical[id]}}}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestPoll(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	var out bytes.Buffer
+	var sent []*Event
+	w := NewWatcher(n, NewJSONLSink(&out), SinkFunc(func(ctx context.Context, e *Event) error {
+		sent = append(sent, e)
+		return nil
+	}))
+	now := time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	var tests = []struct {
+		timestamp int64
+		critical  map[int64]int64
+		scans     []nessie.Scan
+		want      []string
+	}{
+		// The first poll only records the state of the scans.
+		{100, map[int64]int64{3: 1}, []nessie.Scan{
+			{ID: 1, Status: "completed", LastModificationDate: nessie.UnixTimestamp(50)},
+			{ID: 2, Status: "empty", LastModificationDate: nessie.UnixTimestamp(50)},
+			{ID: 3, Status: "running", LastModificationDate: nessie.UnixTimestamp(90)},
+		}, nil},
+		// The critical count of a run that started before the watch is
+		// only recorded.
+		{200, map[int64]int64{3: 3}, []nessie.Scan{
+			{ID: 1, Status: "completed", LastModificationDate: nessie.UnixTimestamp(50)},
+			{ID: 2, Status: "running", LastModificationDate: nessie.UnixTimestamp(150)},
+			{ID: 3, Status: "running", LastModificationDate: nessie.UnixTimestamp(190)},
+		}, []string{"2 scan.started"}},
+		{300, map[int64]int64{2: 2, 3: 3}, []nessie.Scan{
+			{ID: 1, Status: "completed", LastModificationDate: nessie.UnixTimestamp(50)},
+			{ID: 2, Status: "running", LastModificationDate: nessie.UnixTimestamp(250)},
+			{ID: 3, Status: "paused", LastModificationDate: nessie.UnixTimestamp(250)},
+			{ID: 4, Status: "canceled", LastModificationDate: nessie.UnixTimestamp(250)},
+		}, []string{"2 scan.critical_found 2 2", "3 scan.paused", "4 scan.aborted"}},
+		{400, map[int64]int64{2: 2, 3: 5}, []nessie.Scan{
+			{ID: 1, Status: "running", LastModificationDate: nessie.UnixTimestamp(350)},
+			{ID: 2, Status: "completed", LastModificationDate: nessie.UnixTimestamp(350)},
+			{ID: 3, Status: "running", LastModificationDate: nessie.UnixTimestamp(350)},
+			{ID: 4, Status: "canceled", LastModificationDate: nessie.UnixTimestamp(250)},
+		}, []string{"1 scan.started", "2 scan.completed", "3 scan.resumed", "3 scan.critical_found 5 2"}},
+	}
+	for i, tt := range tests {
+		fake.set(tt.timestamp, tt.critical, tt.scans...)
+		events, err := w.Poll(context.Background())
+		if err != nil {
+			t.Fatalf("poll %d: %v", i, err)
+		}
+		var got []string
+		for _, e := range events {
+			s := fmt.Sprintf("%d %s", e.ScanID, e.Type)
+			if e.Type == EventCriticalFound {
+				s += fmt.Sprintf(" %d %d", e.Critical, e.NewCritical)
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("poll %d: wrong events, got=%v want=%v", i, got, tt.want)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(sent) || len(sent) != 8 {
+		t.Fatalf("wrong events sent, got %d lines and %d events", len(lines), len(sent))
+	}
+	want := `{"type":"scan.paused","time":"2021-01-11T00:00:00Z","scan_id":3,"scan_name":"","status":"paused","previous_status":"running"}`
+	if lines[2] != want {
+		t.Errorf("wrong event line, got=%s want=%s", lines[2], want)
+	}
+}
+
+func TestPollDetailsError(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	var sent []string
+	w := NewWatcher(n, SinkFunc(func(ctx context.Context, e *Event) error {
+		sent = append(sent, fmt.Sprintf("%d %s", e.ScanID, e.Type))
+		return nil
+	}))
+
+	fake.set(100, nil, nessie.Scan{ID: 1, Status: "empty"}, nessie.Scan{ID: 2, Status: "empty"})
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("first poll: %v", err)
+	}
+	// The details of scan 2 fail, the events of both scans are still sent.
+	running := []nessie.Scan{
+		{ID: 1, Status: "running", LastModificationDate: nessie.UnixTimestamp(150)},
+		{ID: 2, Status: "running", LastModificationDate: nessie.UnixTimestamp(150)},
+	}
+	fake.broken = map[int64]bool{2: true}
+	fake.set(200, nil, running...)
+	if _, err := w.Poll(context.Background()); err == nil {
+		t.Fatal("got no error, expected the details error of scan 2")
+	}
+	// Scan 2 is checked again, without repeating its transition.
+	fake.broken = nil
+	fake.set(300, map[int64]int64{2: 1}, running...)
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("last poll: %v", err)
+	}
+	if got, want := strings.Join(sent, ","), "1 scan.started,2 scan.started,2 scan.critical_found"; got != want {
+		t.Errorf("wrong events sent, got=%q want=%q", got, want)
+	}
+	if got, want := strings.Join(fake.queries, ","), ",last_modification_date=100,last_modification_date=100"; got != want {
+		t.Errorf("wrong listings, got=%q want=%q", got, want)
+	}
+}
+
+func TestPollSendError(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	var sent []string
+	failing := true
+	w := NewWatcher(n, SinkFunc(func(ctx context.Context, e *Event) error {
+		if failing && e.ScanID == 2 {
+			return fmt.Errorf("sink unavailable")
+		}
+		sent = append(sent, fmt.Sprintf("%d %s", e.ScanID, e.Type))
+		return nil
+	}))
+
+	fake.set(100, nil, nessie.Scan{ID: 1, Status: "empty"}, nessie.Scan{ID: 2, Status: "empty"})
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("first poll: %v", err)
+	}
+	// The event of scan 2 is not delivered.
+	running := []nessie.Scan{
+		{ID: 1, Status: "running", LastModificationDate: nessie.UnixTimestamp(150)},
+		{ID: 2, Status: "running", LastModificationDate: nessie.UnixTimestamp(150)},
+	}
+	fake.set(200, nil, running...)
+	if _, err := w.Poll(context.Background()); err == nil {
+		t.Fatal("got no error, expected the delivery error of scan 2")
+	}
+	// The event of scan 2 is sent again, the one of scan 1 is not.
+	failing = false
+	fake.set(300, nil, running...)
+	events, err := w.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("last poll: %v", err)
+	}
+	if len(events) != 1 || events[0].ScanID != 2 || events[0].Type != EventScanStarted {
+		t.Errorf("wrong events of the last poll, got=%v", events)
+	}
+	if got, want := strings.Join(sent, ","), "1 scan.started,2 scan.started"; got != want {
+		t.Errorf("wrong events sent, got=%q want=%q", got, want)
+	}
+	if got, want := strings.Join(fake.queries, ","), ",last_modification_date=100,last_modification_date=100"; got != want {
+		t.Errorf("wrong listings, got=%q want=%q", got, want)
+	}
+}
+
+func TestPollPrune(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	n, err := nessie.NewInsecureNessus(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create nessus instance: %v", err)
+	}
+	w := NewWatcher(n)
+	w.FullPollEvery = 2
+
+	polls := [][]nessie.Scan{
+		{{ID: 1, Status: "completed"}, {ID: 2, Status: "completed"}},
+		// Scan 2 is deleted, the listing of the modified scans does not tell.
+		{{ID: 1, Status: "completed"}},
+		{{ID: 1, Status: "completed"}},
+	}
+	wantScans := []int{2, 2, 1}
+	for i, scans := range polls {
+		fake.set(int64(100*(i+1)), nil, scans...)
+		if _, err := w.Poll(context.Background()); err != nil {
+			t.Fatalf("poll %d: %v", i, err)
+		}
+		if len(w.scans) != wantScans[i] {
+			t.Errorf("poll %d: %d scans known, want %d", i, len(w.scans), wantScans[i])
+		}
+	}
+	if got, want := strings.Join(fake.queries, ","), ",last_modification_date=100,"; got != want {
+		t.Errorf("wrong listings, got=%q want=%q", got, want)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	secret := []byte("s3cr3t")
+	var calls int
+	status := []int{http.StatusServiceUnavailable, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !VerifySignature(secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("wrong signature %q for %s", r.Header.Get(HeaderSignature), body)
+		}
+		if r.Header.Get(HeaderEvent) != string(EventScanCompleted) {
+			t.Errorf("wrong event header %q", r.Header.Get(HeaderEvent))
+		}
+		w.WriteHeader(status[calls%len(status)])
+		calls++
+	}))
+	defer server.Close()
+
+	s := NewWebhookSink(server.URL, secret)
+	s.Backoff = time.Millisecond
+	e := &Event{Type: EventScanCompleted, ScanID: 42, Status: "completed"}
+	if err := s.Send(context.Background(), e); err != nil || calls != 2 {
+		t.Errorf("should succeed after a retry, got calls=%d err=%v", calls, err)
+	}
+
+	// Client errors are not retried.
+	calls = 0
+	status = []int{http.StatusBadRequest}
+	if err := s.Send(context.Background(), e); err == nil || calls != 1 {
+		t.Errorf("should fail without retrying, got calls=%d err=%v", calls, err)
+	}
+
+	calls = 0
+	status = []int{http.StatusInternalServerError}
+	if err := s.Send(context.Background(), e); err == nil || calls != s.MaxRetries+1 {
+		t.Errorf("should fail after retrying, got calls=%d err=%v", calls, err)
+	}
+}